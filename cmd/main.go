@@ -1,33 +1,89 @@
 package main
 
 import (
+	"context"
+	"crypto_price/pkg/app"
 	"crypto_price/pkg/config"
-	"crypto_price/pkg/jobs"
-	"crypto_price/pkg/server"
-	"log"
-	_ "net/http/pprof"
-
-	"github.com/getsentry/sentry-go"
+	"crypto_price/pkg/db"
+	"crypto_price/pkg/logging"
+	"crypto_price/pkg/reporting"
+	"crypto_price/pkg/tracing"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 
 func main(){
-	cfg := config.GetConfigs()
-
-	if cfg.SentryDSN != "" {
-		err := sentry.Init(sentry.ClientOptions{
-			EnableTracing: true,
-			Dsn: cfg.SentryDSN,
-			TracesSampleRate: 1.0,
-		})
-		if err != nil {
-			log.Fatalf("sentry.Init: %s", err)
+	configPath := flag.String("config", "", "path to the YAML config file (default pkg/config/env.yml)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [--config file] [config print]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath, db.ReadSettings)
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+
+	switch args := flag.Args(); {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		if err := cfg.Redacted().Print(os.Stdout); err != nil {
+			fatal("Failed to print configuration", err)
+		}
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := logging.Setup(cfg.Log, os.Stderr); err != nil {
+		fatal("Failed to set up logging", err)
+	}
+
+	if err := reporting.Init(cfg.Sentry); err != nil {
+		fatal("Failed to initialize Sentry", err)
+	}
+	// Send buffered events before exiting, after the application closed.
+	defer reporting.Flush(cfg.Server.ShutdownTimeout)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("Failed to flush spans", "error", err)
 		}
-		log.Println("Sentry initialized successfully")
-	} else {
-		log.Println("Sentry DSN not provided, skipping Sentry initialization")
+	}()
+
+	a, err := app.New(cfg)
+	if err != nil {
+		fatal("Failed to initialize application", err)
 	}
 
-	go jobs.GetData()
-	server.StartHTTPServer()
-}
\ No newline at end of file
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := a.Run(ctx); err != nil {
+		reporting.CaptureError(ctx, err, nil)
+		reporting.Flush(cfg.Server.ShutdownTimeout)
+		fatal("Server stopped", err)
+	}
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	a.Close(closeCtx)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,268 @@
+package api_test
+
+import (
+	"context"
+	"crypto_price/pkg/api"
+	"crypto_price/pkg/app"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newContractApp builds the application over the in-memory store with a
+// MongoDB that cannot be reached, so every handler can be exercised without
+// external services.
+func newContractApp(t *testing.T) *app.App {
+	t.Helper()
+	mr := miniredis.RunT(t)
+
+	cfg := config.Default()
+	cfg.Store.Backend = "memory"
+	cfg.Redis.Addrs = []string{mr.Addr()}
+	cfg.Mongo.URI = "mongodb://127.0.0.1:1"
+	cfg.Mongo.ConnectTimeout = 100 * time.Millisecond
+	cfg.Mongo.ServerSelectionTimeout = 100 * time.Millisecond
+
+	a, err := app.New(cfg)
+	if err != nil {
+		t.Fatalf("app.New: %v", err)
+	}
+	t.Cleanup(func() { a.Close(context.Background()) })
+	return a
+}
+
+func TestV1Contract(t *testing.T) {
+	a := newContractApp(t)
+	ctx := context.Background()
+	now := time.Now()
+	stats := map[string]models.MarketStats{"BTCUSDT": {High: 61000, Low: 59000, Change: 600, ChangePercent: 1.01, Volume: 1234.5, QuoteVolume: 74070000, Bid: 59999, Ask: 60001}}
+	if err := a.Store.PutPrices(ctx, "kucoin", map[string]float64{"BTCUSDT": 60000, "ETHUSDT": 3000}, stats, now); err != nil {
+		t.Fatalf("PutPrices: %v", err)
+	}
+	if err := a.Store.PutUsdtIrr(ctx, models.MarketSourceResult{Source: "nobitex", MarketName: "USDTIRT", WeightedMean: 600000, Trades: 100, ComputedAt: now}); err != nil {
+		t.Fatalf("PutUsdtIrr: %v", err)
+	}
+	handler := a.HTTP.Handler()
+
+	var spec map[string]interface{}
+	if err := json.Unmarshal(api.OpenAPISpec(), &spec); err != nil {
+		t.Fatalf("invalid OpenAPI document: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		accept     string
+		path       string
+		wantStatus int
+	}{
+		{"price", "GET", "/v1/price?base=BTC&source=kucoin", "", "/price", http.StatusOK},
+		{"price with stats", "GET", "/v1/price?base=BTC&source=kucoin&include=stats", "", "/price", http.StatusOK},
+		{"converted price with stats", "GET", "/v1/price?base=BTC&source=kucoin&quote=irt&include=stats", "", "/price", http.StatusOK},
+		{"invalid quote", "GET", "/v1/price?base=BTC&quote=eur", "", "/price", http.StatusBadRequest},
+		{"invalid include", "GET", "/v1/price?base=BTC&include=volume", "", "/price", http.StatusBadRequest},
+		{"missing price", "GET", "/v1/price?base=SOL&source=kucoin", "", "/price", http.StatusNotFound},
+		{"method not allowed", "POST", "/v1/price?base=BTC", "", "/price", http.StatusMethodNotAllowed},
+		{"not acceptable", "GET", "/v1/price?base=BTC", "text/html", "/price", http.StatusNotAcceptable},
+		{"assets without registry", "GET", "/v1/assets", "", "/assets", http.StatusServiceUnavailable},
+		{"health", "GET", "/v1/health", "", "/health", 0},
+		{"liveness", "GET", "/v1/health/live", "", "/health/live", http.StatusOK},
+		{"readiness", "GET", "/v1/health/ready", "", "/health/ready", 0},
+		{"freshness", "GET", "/v1/status/freshness", "", "/status/freshness", 0},
+		{"openapi", "GET", "/v1/openapi.json", "", "/openapi.json", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if tt.wantStatus != 0 && w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, api.ContentTypeJSON) {
+				t.Errorf("Content-Type = %q, want %s", ct, api.ContentTypeJSON)
+			}
+
+			schema, err := responseSchema(spec, tt.path, tt.method, w.Code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON body %q: %v", w.Body, err)
+			}
+			for _, err := range validate(spec, schema, body, "$") {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// responseSchema returns the documented schema of the response of method on
+// path with status. Undocumented status codes are an error.
+func responseSchema(spec map[string]interface{}, path, method string, status int) (interface{}, error) {
+	operation, ok := lookup(spec, "paths", path, strings.ToLower(method)).(map[string]interface{})
+	if !ok {
+		// Methods a route rejects are answered with the error envelope.
+		if status == http.StatusMethodNotAllowed {
+			return resolve(spec, map[string]interface{}{"$ref": "#/components/schemas/ErrorEnvelope"}), nil
+		}
+		return nil, fmt.Errorf("%s %s is not documented", method, path)
+	}
+	response := resolve(spec, lookup(operation, "responses", strconv.Itoa(status)))
+	if response == nil {
+		return nil, fmt.Errorf("status %d of %s %s is not documented", status, method, path)
+	}
+	schema := lookup(response, "content", api.ContentTypeJSON, "schema")
+	if schema == nil {
+		return nil, fmt.Errorf("status %d of %s %s has no JSON schema", status, method, path)
+	}
+	return schema, nil
+}
+
+// lookup walks the nested objects of v along keys, returning nil when a key
+// is missing.
+func lookup(v interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = object[key]
+	}
+	return v
+}
+
+// resolve follows the local $ref of v, if any.
+func resolve(spec map[string]interface{}, v interface{}) interface{} {
+	for {
+		ref, ok := lookup(v, "$ref").(string)
+		if !ok {
+			return v
+		}
+		v = lookup(spec, strings.Split(strings.TrimPrefix(ref, "#/"), "/")...)
+	}
+}
+
+// validate checks value against the subset of JSON Schema used by the
+// OpenAPI document. Properties a schema does not list are reported unless it
+// allows additional properties.
+func validate(spec map[string]interface{}, schema, value interface{}, at string) []error {
+	s, _ := resolve(spec, schema).(map[string]interface{})
+	if s == nil {
+		return []error{fmt.Errorf("%s: unresolvable schema %v", at, schema)}
+	}
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(at+": "+format, args...))
+	}
+
+	switch s["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("got %T, want object", value)
+			return errs
+		}
+		for _, name := range stringsOf(s["required"]) {
+			if _, ok := object[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		properties, _ := s["properties"].(map[string]interface{})
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name]; ok {
+				errs = append(errs, validate(spec, property, object[name], at+"."+name)...)
+				continue
+			}
+			switch additional := s["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					fail("undocumented property %q", name)
+				}
+			case map[string]interface{}:
+				errs = append(errs, validate(spec, additional, object[name], at+"."+name)...)
+			default:
+				if properties != nil {
+					fail("undocumented property %q", name)
+				}
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			fail("got %T, want array", value)
+			return errs
+		}
+		for i, item := range array {
+			errs = append(errs, validate(spec, s["items"], item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("got %T, want string", value)
+			return errs
+		}
+		if s["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				fail("invalid date-time %q", str)
+			}
+		}
+		if enum := stringsOf(s["enum"]); len(enum) > 0 && !contains(enum, str) {
+			fail("%q is not one of %v", str, enum)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail("got %T, want number", value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			fail("got %v, want integer", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("got %T, want boolean", value)
+		}
+	}
+	return errs
+}
+
+func stringsOf(v interface{}) []string {
+	list, _ := v.([]interface{})
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if str, ok := item.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
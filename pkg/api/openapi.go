@@ -0,0 +1,20 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec returns the OpenAPI 3 document describing the /v1 API.
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// HandleOpenAPI serves the OpenAPI document.
+func HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const ContentTypeJSON = "application/json"
+
+// Error codes used in error envelopes.
+const (
+	CodeInvalidArgument  = "invalid_argument"
+	CodeNotFound         = "not_found"
+	CodeAlreadyExists    = "already_exists"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeNotAcceptable    = "not_acceptable"
+	CodeUnauthenticated  = "unauthenticated"
+	CodePermissionDenied = "permission_denied"
+	CodeRateLimited      = "rate_limited"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal"
+)
+
+// Error is the body of every non-2xx response of the versioned API.
+type Error struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// ErrorEnvelope wraps an Error so clients can tell error bodies apart from
+// regular payloads by the presence of the "error" key.
+type ErrorEnvelope struct {
+	Error Error `json:"error"`
+}
+
+// WriteJSON encodes v as the response body with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// WriteError writes a JSON error envelope with the given status code.
+func WriteError(w http.ResponseWriter, status int, code, message string, details map[string]interface{}) {
+	WriteJSON(w, status, ErrorEnvelope{Error: Error{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}})
+}
+
+// AcceptsJSON reports whether the request's Accept header allows a JSON
+// response. A missing header accepts anything. As in RFC 9110, the most
+// specific media range matching JSON decides, and q=0 refuses it.
+func AcceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+
+	// Specificity of the matching ranges: */*, application/*, application/json.
+	specificity := map[string]int{"*/*": 1, "application/*": 2, ContentTypeJSON: 3}
+	best, quality := 0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, q, ok := parseMediaRange(part)
+		if !ok {
+			continue
+		}
+		if rank := specificity[mediaType]; rank > best {
+			best, quality = rank, q
+		}
+	}
+	return quality > 0
+}
+
+// parseMediaRange parses one element of an Accept header into its lower-case
+// media type and quality, 1 unless given. It tolerates whitespace around the
+// separators and reports false for a malformed quality.
+func parseMediaRange(part string) (string, float64, bool) {
+	params := strings.Split(part, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	if mediaType == "" {
+		return "", 0, false
+	}
+
+	quality := 1.0
+	for _, param := range params[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 || q > 1 {
+			return "", 0, false
+		}
+		quality = q
+	}
+	return mediaType, quality, true
+}
+
+// Get restricts a handler to GET/HEAD requests that accept a JSON response,
+// answering anything else with the matching error envelope.
+func Get(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			MethodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+			return
+		}
+		if !AcceptsJSON(r) {
+			WriteError(w, http.StatusNotAcceptable, CodeNotAcceptable,
+				"only "+ContentTypeJSON+" responses are available",
+				map[string]interface{}{"supported": []string{ContentTypeJSON}})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// MethodNotAllowed answers a request whose method is not in allowed.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	WriteError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+		"method "+r.Method+" is not allowed", nil)
+}
+
+// NotFound answers unknown routes with a JSON error envelope.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, http.StatusNotFound, CodeNotFound, "no route for "+r.URL.Path, nil)
+}
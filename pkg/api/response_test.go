@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", true},
+		{"application/json", true},
+		{"application/*", true},
+		{"*/*", true},
+		{"APPLICATION/JSON", true},
+		{"text/html", false},
+		{"text/html, application/json;q=0.5", true},
+		{"application/json;q=0", false},
+		{"application/json;q=0.0", false},
+		{"application/json;q=0.000", false},
+		{"application/json ; q=0", false},
+		{"application/json; q = 0", false},
+		{"application/json;Q=0", false},
+		{"application/json;q=0.001", true},
+		{"application/json;charset=utf-8;q=1", true},
+		{"application/json;q=0, */*;q=0.1", false},
+		{"application/*;q=0, application/json", true},
+		{"*/*;q=0.1, application/*;q=0", false},
+		{"application/json;q=abc", false},
+		{"application/json;q=2", false},
+		{" , text/plain", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/v1/price", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := AcceptsJSON(r); got != tt.want {
+				t.Errorf("AcceptsJSON(%q) = %t, want %t", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
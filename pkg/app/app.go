@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"crypto_price/pkg/assets"
+	"crypto_price/pkg/auth"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/controller"
+	"crypto_price/pkg/db"
+	"crypto_price/pkg/exchanges"
+	"crypto_price/pkg/health"
+	"crypto_price/pkg/jobs"
+	"crypto_price/pkg/logging"
+	"crypto_price/pkg/server"
+	"crypto_price/pkg/store"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+)
+
+// App wires the service together. It is built once at startup from the
+// configuration; every component receives its dependencies from here rather
+// than reaching for package-level singletons.
+type App struct {
+	Config     *config.Config
+	Reloader   *config.Reloader
+	Mongo      *db.Mongo
+	Redis      redis.UniversalClient
+	Store      store.PriceStore
+	Exchanges  *exchanges.Client
+	Assets     *assets.Registry
+	Auth       *auth.Authenticator
+	Controller *controller.Controller
+	Health     *health.Checker
+	Jobs       *jobs.Runner
+	HTTP       *server.HTTPServer
+	GRPC       *grpc.Server
+}
+
+// New builds the application from cfg. It only fails on invalid
+// configuration; unreachable databases are retried by the clients.
+func New(cfg *config.Config) (*App, error) {
+	a := &App{Config: cfg}
+
+	rdb, err := db.NewRedisClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Redis client: %w", err)
+	}
+	a.Redis = rdb
+
+	a.Store, err = store.New(cfg, rdb)
+	if err != nil {
+		rdb.Close()
+		return nil, err
+	}
+
+	a.Mongo = db.NewMongo(cfg)
+	a.Exchanges = exchanges.NewClient(cfg.Exchanges)
+	a.Assets = assets.NewRegistry(a.Mongo)
+	a.Auth = auth.New(cfg, a.Mongo, rdb)
+	a.Jobs = jobs.NewRunner(cfg.Jobs, a.Mongo, rdb, a.Store, a.Exchanges)
+	a.Controller = controller.New(cfg, a.Store, a.Assets, a.Mongo, a.Exchanges, a.Jobs)
+	a.Health = health.NewChecker(cfg, a.Store, a.Mongo, a.Exchanges, a.Jobs)
+	a.Reloader = config.NewReloader(cfg, a.Mongo.Settings)
+	a.Reloader.OnChange(a.reconfigure)
+	a.HTTP = server.NewHTTPServer(cfg, a.Reloader, a.Auth, a.Controller, a.Health)
+	a.GRPC = server.NewGRPCServer(a.Auth, a.Controller)
+
+	return a, nil
+}
+
+// reconfigure applies a reloaded configuration. Only settings tagged
+// reload:"safe" differ from the startup configuration.
+func (a *App) reconfigure(cfg *config.Config) {
+	if err := logging.Configure(cfg.Log); err != nil {
+		slog.Error("Failed to apply log settings", "error", err)
+	}
+	a.Jobs.Reconfigure(cfg.Jobs)
+	a.Health.Reconfigure(cfg)
+	a.Store.SetExpirations(store.ExpirationsFromConfig(cfg.Store))
+	a.Controller.SetFreshness(cfg.Thresholds.PriceFreshness)
+}
+
+// Run starts the ingestion jobs, the cache invalidation watcher, the config
+// reloader, the health checks and the HTTP server, and blocks until ctx is
+// done or the server fails.
+func (a *App) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		a.Jobs.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		a.Reloader.Watch(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		a.Controller.WatchCacheInvalidations(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		a.Health.Run(ctx)
+	}()
+
+	// The HTTP and gRPC servers stop together: when one fails the other is
+	// shut down too.
+	grpcErr := make(chan error, 1)
+	go func() {
+		err := server.ServeGRPC(ctx, a.GRPC, a.Config.Server.GRPCPort, a.Config.Server.ShutdownTimeout)
+		cancel()
+		grpcErr <- err
+	}()
+
+	err := a.HTTP.ListenAndServe(ctx)
+	cancel()
+	err = errors.Join(err, <-grpcErr)
+	wg.Wait()
+	return err
+}
+
+// Close releases the database clients.
+func (a *App) Close(ctx context.Context) {
+	if err := a.Mongo.Disconnect(ctx); err != nil {
+		slog.ErrorContext(ctx, "Error disconnecting from MongoDB", "error", err)
+	}
+	if err := a.Redis.Close(); err != nil {
+		slog.ErrorContext(ctx, "Error closing Redis client", "error", err)
+	}
+}
@@ -0,0 +1,206 @@
+package app_test
+
+import (
+	"context"
+	"crypto_price/pkg/app"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/jobs"
+	"crypto_price/pkg/models"
+	"crypto_price/pkg/server"
+	"crypto_price/pkg/store"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestPipeline runs the Binance job against a fake exchange and reads the
+// ingested prices back through the HTTP API, with the in-memory store in
+// between.
+func TestPipeline(t *testing.T) {
+	binance := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/ticker/24hr" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"symbol":"BTCUSDT","lastPrice":"60000","priceChange":"600","priceChangePercent":"1.01","bidPrice":"59999","askPrice":"60001","highPrice":"61000","lowPrice":"59000","volume":"1234.5","quoteVolume":"74070000"},
+			{"symbol":"ETHUSDT","lastPrice":"3000","priceChange":"0","priceChangePercent":"0","bidPrice":"0","askPrice":"0","highPrice":"0","lowPrice":"0","volume":"0","quoteVolume":"0"},
+			{"symbol":"LUNAUSDT","lastPrice":"0"}
+		]`)
+	}))
+	defer binance.Close()
+
+	cfg := testConfig(t)
+	cfg.Exchanges.BinanceURL = binance.URL
+	cfg.Jobs.BinanceEnabled = true
+	cfg.Jobs.BinanceInterval = 50 * time.Millisecond
+
+	a, err := app.New(cfg)
+	if err != nil {
+		t.Fatalf("app.New: %v", err)
+	}
+	defer a.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := a.Store.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Jobs.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	timeout := time.After(5 * time.Second)
+	for received := false; !received; {
+		select {
+		case prefix := <-updates:
+			received = prefix == store.SourcePrefix("binance")
+		case <-timeout:
+			t.Fatal("no Binance update was published")
+		}
+	}
+	if a.Jobs.LastWrite(jobs.JobBinance).IsZero() {
+		t.Error("LastWrite(binance) is zero after an update")
+	}
+
+	if err := jobs.StoreUsdtIrrPrices(ctx, a.Store, []models.MarketSourceResult{
+		{Source: "nobitex", MarketName: "USDTIRT", WeightedMean: 600000, Trades: 100, ComputedAt: time.Now()},
+	}); err != nil {
+		t.Fatalf("StoreUsdtIrrPrices: %v", err)
+	}
+
+	handler := a.HTTP.Handler()
+	get := func(target string) (int, models.PriceResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		var response models.PriceResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("GET %s: invalid body %q: %v", target, w.Body, err)
+			}
+		}
+		return w.Code, response
+	}
+
+	code, btc := get("/v1/price?base=BTC&source=binance&include=stats")
+	if code != http.StatusOK {
+		t.Fatalf("GET BTC status = %d, want 200", code)
+	}
+	if btc.Price != 60000 || btc.Note != "" {
+		t.Errorf("BTC = %+v, want a fresh price of 60000", btc)
+	}
+	if btc.Stats == nil || btc.Stats.High != 61000 || btc.Stats.ChangePercent != 1.01 {
+		t.Errorf("BTC stats = %+v, want the ticker statistics", btc.Stats)
+	}
+
+	code, irr := get("/v1/price?base=ETH&source=binance&quote=irr&source_usdt=nobitex")
+	if code != http.StatusOK {
+		t.Fatalf("GET ETH/IRR status = %d, want 200", code)
+	}
+	if irr.Price != 3000*600000 || !strings.EqualFold(irr.Quote, "irr") {
+		t.Errorf("ETH/IRR = %+v, want 3000 * 600000 IRR", irr)
+	}
+
+	// Markets reported with a zero price are not ingested.
+	if code, _ := get("/v1/price?base=LUNA&source=binance"); code != http.StatusNotFound {
+		t.Errorf("GET LUNA status = %d, want 404", code)
+	}
+
+	client := dialGRPC(t, a)
+	response, err := client.GetCryptoPrice(ctx, &server.PriceRequest{Base: "BTC", Source: "binance", Quote: "usdt"})
+	if err != nil {
+		t.Fatalf("GetCryptoPrice(BTC): %v", err)
+	}
+	if response.GetPrice() != 60000 {
+		t.Errorf("GetCryptoPrice(BTC) = %v, want 60000", response.GetPrice())
+	}
+	_, err = client.GetCryptoPrice(ctx, &server.PriceRequest{Base: "LUNA", Source: "binance", Quote: "usdt"})
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("GetCryptoPrice(LUNA) code = %v, want NotFound", code)
+	}
+}
+
+// dialGRPC serves the gRPC API of a on an in-memory listener and returns a
+// client connected to it.
+func dialGRPC(t *testing.T, a *app.App) server.CryptoPriceServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	go a.GRPC.Serve(listener)
+	t.Cleanup(a.GRPC.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server.NewCryptoPriceServiceClient(conn)
+}
+
+// testConfig returns a configuration using the in-memory store, a miniredis
+// server and an unreachable MongoDB.
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg := config.Default()
+	cfg.Store.Backend = "memory"
+	cfg.Redis.Addrs = []string{mr.Addr()}
+	cfg.Mongo.URI = "mongodb://127.0.0.1:1"
+	cfg.Mongo.ConnectTimeout = 100 * time.Millisecond
+	cfg.Mongo.ServerSelectionTimeout = 100 * time.Millisecond
+	return cfg
+}
+
+func TestPprofFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(cfg *config.Config)
+		want int
+	}{
+		{"off by default", func(*config.Config) {}, http.StatusNotFound},
+		{"enabled", func(cfg *config.Config) { cfg.Server.Pprof = true }, http.StatusOK},
+		{"enabled with auth", func(cfg *config.Config) {
+			cfg.Server.Pprof = true
+			cfg.Auth.Enabled = true
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			tt.set(cfg)
+			a, err := app.New(cfg)
+			if err != nil {
+				t.Fatalf("app.New: %v", err)
+			}
+			defer a.Close(context.Background())
+
+			w := httptest.NewRecorder()
+			a.HTTP.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/", nil))
+			if w.Code != tt.want {
+				t.Errorf("GET /debug/pprof/ status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
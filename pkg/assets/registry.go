@@ -0,0 +1,277 @@
+package assets
+
+import (
+	"context"
+	"crypto_price/pkg/db"
+	"crypto_price/pkg/models"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	refreshInterval = time.Minute
+	retryInterval   = 10 * time.Second
+	defaultDecimals = 8
+	loadTimeout     = 10 * time.Second
+)
+
+// builtinAssets are priced through the USDT/IRR rate rather than an exchange
+// market, so they are never part of the tracked symbols.
+var builtinAssets = []models.Asset{
+	{Ticker: "USDT", Name: "Tether", Decimals: 2, Exchanges: map[string]string{}, Quotes: []string{"irr", "irt"}},
+	{Ticker: "USDC", Name: "USD Coin", Decimals: 2, Exchanges: map[string]string{}, Quotes: []string{"irr", "irt"}},
+}
+
+// Registry resolves tickers and aliases to assets. It is built from the
+// tracked symbols and the legacy market-making configs in MongoDB and
+// reloaded by lookups once it is older than refreshInterval.
+type Registry struct {
+	mongo *db.Mongo
+
+	mu          sync.RWMutex
+	assets      []models.Asset
+	index       map[string]int
+	loadedAt    time.Time
+	lastAttempt time.Time
+}
+
+// NewRegistry returns a registry loading assets from mongo on first use.
+func NewRegistry(mongo *db.Mongo) *Registry {
+	return &Registry{mongo: mongo}
+}
+
+// Lookup resolves a ticker or alias, case-insensitively, to its asset.
+func (r *Registry) Lookup(ctx context.Context, symbol string) (models.Asset, bool, error) {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return models.Asset{}, false, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.index[strings.ToUpper(symbol)]
+	if !ok {
+		return models.Asset{}, false, nil
+	}
+	return r.assets[i], true, nil
+}
+
+// List returns every registered asset sorted by ticker.
+func (r *Registry) List(ctx context.Context) ([]models.Asset, error) {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	assets := make([]models.Asset, len(r.assets))
+	copy(assets, r.assets)
+	return assets, nil
+}
+
+// Suggest returns up to limit registered tickers that look like symbol, for
+// "did you mean" hints on unknown assets.
+func (r *Registry) Suggest(symbol string, limit int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	symbol = strings.ToUpper(symbol)
+	type candidate struct {
+		ticker   string
+		distance int
+	}
+
+	best := make(map[string]int)
+	for name, i := range r.index {
+		distance := levenshtein(symbol, name)
+		if strings.HasPrefix(name, symbol) || strings.HasPrefix(symbol, name) {
+			distance = min(distance, 1)
+		}
+		if distance > 2 {
+			continue
+		}
+		ticker := r.assets[i].Ticker
+		if d, ok := best[ticker]; !ok || distance < d {
+			best[ticker] = distance
+		}
+	}
+
+	candidates := make([]candidate, 0, len(best))
+	for ticker, distance := range best {
+		candidates = append(candidates, candidate{ticker, distance})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].ticker < candidates[j].ticker
+	})
+
+	suggestions := []string{}
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		suggestions = append(suggestions, candidates[i].ticker)
+	}
+	return suggestions
+}
+
+// Invalidate forces the next lookup to reload the registry, e.g. after a
+// tracked symbol changed.
+func (r *Registry) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.loadedAt.IsZero() {
+		r.loadedAt = r.loadedAt.Add(-refreshInterval)
+	}
+	r.lastAttempt = time.Time{}
+}
+
+// ensureLoaded reloads the registry from MongoDB once it is older than
+// refreshInterval. A failed reload keeps serving the previous registry and is
+// retried after retryInterval; it is only reported when nothing was ever loaded.
+func (r *Registry) ensureLoaded(ctx context.Context) error {
+	r.mu.RLock()
+	fresh := time.Since(r.loadedAt) < refreshInterval
+	recentlyTried := time.Since(r.lastAttempt) < retryInterval
+	loaded := !r.loadedAt.IsZero()
+	r.mu.RUnlock()
+
+	if fresh || (loaded && recentlyTried) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Another caller may have reloaded while we waited for the lock.
+	if time.Since(r.loadedAt) < refreshInterval || (!r.loadedAt.IsZero() && time.Since(r.lastAttempt) < retryInterval) {
+		return nil
+	}
+	r.lastAttempt = time.Now()
+
+	assets, err := r.load(ctx)
+	if err != nil {
+		if r.loadedAt.IsZero() {
+			return err
+		}
+		slog.WarnContext(ctx, "Error reloading asset registry, serving previous copy", "error", err)
+		return nil
+	}
+
+	r.assets = assets
+	r.index = buildIndex(assets)
+	r.loadedAt = time.Now()
+	return nil
+}
+
+func (r *Registry) load(ctx context.Context) ([]models.Asset, error) {
+	ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+	defer cancel()
+
+	legacy, err := r.mongo.LegacyKucoinSymbols(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load legacy symbols: %w", err)
+	}
+	tracked, err := r.mongo.ListTrackedSymbols(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tracked symbols: %w", err)
+	}
+
+	return buildAssets(legacy, tracked), nil
+}
+
+// buildAssets merges the built-in assets, enabled tracked symbols and legacy
+// KuCoin symbols not covered by a tracked symbol into one sorted list.
+func buildAssets(legacy []string, tracked []models.TrackedSymbol) []models.Asset {
+	var assets []models.Asset
+	covered := make(map[string]bool)
+
+	for _, a := range builtinAssets {
+		assets = append(assets, a)
+		covered[a.Ticker] = true
+	}
+
+	for _, t := range tracked {
+		covered[strings.ToUpper(t.Asset)] = true
+		if kucoin, ok := t.Exchanges["kucoin"]; ok {
+			covered[strings.ToUpper(kucoin)] = true
+		}
+		if !t.Enabled {
+			continue
+		}
+
+		decimals := t.Decimals
+		if decimals == 0 {
+			decimals = defaultDecimals
+		}
+		assets = append(assets, models.Asset{
+			Ticker:    strings.ToUpper(t.Asset),
+			Name:      t.Name,
+			Decimals:  decimals,
+			Aliases:   t.Aliases,
+			Exchanges: t.Exchanges,
+			Quotes:    t.Quotes,
+		})
+	}
+
+	for _, symbol := range legacy {
+		ticker := strings.ToUpper(symbol)
+		if ticker == "" || covered[ticker] {
+			continue
+		}
+		covered[ticker] = true
+		assets = append(assets, models.Asset{
+			Ticker:    ticker,
+			Decimals:  defaultDecimals,
+			Exchanges: map[string]string{"kucoin": symbol},
+		})
+	}
+
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Ticker < assets[j].Ticker })
+	return assets
+}
+
+// buildIndex maps tickers, aliases and exchange symbols to asset positions.
+// Tickers take precedence over aliases when they collide.
+func buildIndex(assets []models.Asset) map[string]int {
+	index := make(map[string]int)
+	for i, a := range assets {
+		for _, alias := range a.Aliases {
+			index[strings.ToUpper(alias)] = i
+		}
+		for _, symbol := range a.Exchanges {
+			if _, ok := index[strings.ToUpper(symbol)]; !ok {
+				index[strings.ToUpper(symbol)] = i
+			}
+		}
+	}
+	for i, a := range assets {
+		index[a.Ticker] = i
+	}
+	return index
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto_price/pkg/cache"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/db"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Scopes granted to API keys.
+const (
+	ScopePriceRead   = "price:read"
+	ScopeHistoryRead = "history:read"
+	// ScopeMetricsRead grants scraping /metrics, e.g. to Prometheus, without
+	// access to prices or the admin API.
+	ScopeMetricsRead = "metrics:read"
+	// ScopeAdmin grants every other scope as well.
+	ScopeAdmin = "admin"
+)
+
+var (
+	ErrMissingKey = errors.New("API key is required")
+	ErrInvalidKey = errors.New("API key is invalid or disabled")
+)
+
+// APIKey is a client credential stored in the API key collection. Only the
+// SHA-256 hash of the key is persisted.
+type APIKey struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	KeyHash   string             `bson:"key_hash"`
+	Scopes    []string           `bson:"scopes"`
+	Disabled  bool               `bson:"disabled"`
+	CreatedAt time.Time          `bson:"created_at"`
+	// RateLimit is the sustained request rate in requests per second and
+	// Burst the bucket size. Zero values fall back to the configured defaults.
+	RateLimit float64 `bson:"rate_limit,omitempty"`
+	Burst     int     `bson:"burst,omitempty"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// HashKey returns the hex encoded SHA-256 hash under which a raw key is stored.
+func HashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyCacheCapacity bounds the number of resolved keys held in memory.
+const keyCacheCapacity = 10000
+
+// Authenticator checks API keys stored in MongoDB and applies their rate
+// limits in Redis.
+type Authenticator struct {
+	cfg   *config.Config
+	mongo *db.Mongo
+	rdb   redis.UniversalClient
+
+	keyCache *cache.LRU[*APIKey]
+	// proxies are the trusted proxies whose forwarded headers identify
+	// clients.
+	proxies []netip.Prefix
+}
+
+// New returns an Authenticator for the keys in cfg.APIKeyCollection.
+func New(cfg *config.Config, mongo *db.Mongo, rdb redis.UniversalClient) *Authenticator {
+	return &Authenticator{
+		cfg:      cfg,
+		mongo:    mongo,
+		rdb:      rdb,
+		keyCache: cache.NewLRU[*APIKey](keyCacheCapacity, cfg.Auth.KeyCacheTTL),
+		proxies:  parseProxies(cfg.Auth.TrustedProxies),
+	}
+}
+
+// Enabled reports whether requests must carry an API key.
+func (a *Authenticator) Enabled() bool {
+	return a.cfg.Auth.Enabled
+}
+
+// LookupKey resolves a raw API key. Keys that exist are cached for
+// auth.key_cache_ttl so authentication does not hit MongoDB on every request.
+// Misses are not cached: they are bounded by the failed authentication limit
+// instead, so clients sending random keys cannot fill the cache.
+func (a *Authenticator) LookupKey(ctx context.Context, raw string) (*APIKey, error) {
+	if raw == "" {
+		return nil, ErrMissingKey
+	}
+	hash := HashKey(raw)
+
+	key, _, ok := a.keyCache.Get(hash)
+	if !ok {
+		var err error
+		key, err = a.findKey(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			a.keyCache.Set(hash, key)
+		}
+	}
+
+	if key == nil || key.Disabled {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+func (a *Authenticator) findKey(ctx context.Context, hash string) (*APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	collection, err := a.mongo.Collection(ctx, a.cfg.Mongo.APIKeyCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	var key APIKey
+	err = collection.FindOne(ctx, bson.M{"key_hash": hash}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	return &key, nil
+}
+
+type contextKey struct{}
+
+// WithKey returns a copy of ctx carrying the authenticated key.
+func WithKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the authenticated key stored in ctx, if any.
+func FromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(*APIKey)
+	return key, ok
+}
@@ -0,0 +1,25 @@
+package auth
+
+import "testing"
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{[]string{ScopePriceRead}, ScopePriceRead, true},
+		{[]string{ScopePriceRead}, ScopeMetricsRead, false},
+		{[]string{ScopeMetricsRead}, ScopeMetricsRead, true},
+		{[]string{ScopeMetricsRead}, ScopePriceRead, false},
+		{[]string{ScopeMetricsRead}, ScopeAdmin, false},
+		{[]string{ScopeAdmin}, ScopeMetricsRead, true},
+		{nil, ScopePriceRead, false},
+	}
+	for _, tt := range tests {
+		key := &APIKey{Scopes: tt.scopes}
+		if got := key.HasScope(tt.scope); got != tt.want {
+			t.Errorf("HasScope(%q) with %v = %t, want %t", tt.scope, tt.scopes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// parseProxies parses auth.trusted_proxies, a list of addresses and CIDR
+// ranges. Invalid entries are skipped; the configuration rejects them.
+func parseProxies(entries []string) []netip.Prefix {
+	var proxies []netip.Prefix
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			proxies = append(proxies, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return proxies
+}
+
+// trusted reports whether addr belongs to a trusted proxy.
+func (a *Authenticator) trusted(addr string) bool {
+	parsed, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	parsed = parsed.Unmap()
+	for _, proxy := range a.proxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddress returns the address the failed authentication limit applies
+// to. When the peer at remote is a trusted proxy, the forwarded chain is
+// walked from the nearest hop and the first address not belonging to a
+// trusted proxy is the client; hops further left could be forged by it.
+func (a *Authenticator) clientAddress(remote string, forwarded []string) string {
+	client, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		client = remote
+	}
+	if !a.trusted(client) {
+		return client
+	}
+
+	var hops []string
+	for _, header := range forwarded {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		client = hops[i]
+		if !a.trusted(client) {
+			break
+		}
+	}
+	return client
+}
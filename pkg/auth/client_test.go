@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto_price/pkg/config"
+	"testing"
+)
+
+func TestClientAddress(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.10", "invalid"}
+	a := New(cfg, nil, nil)
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct client", "198.51.100.7:5000", nil, "198.51.100.7"},
+		{"untrusted peer header ignored", "198.51.100.7:5000", []string{"203.0.113.1"}, "198.51.100.7"},
+		{"trusted proxy", "10.1.2.3:5000", []string{"203.0.113.1"}, "203.0.113.1"},
+		{"forged left hops", "10.1.2.3:5000", []string{"6.6.6.6, 203.0.113.1"}, "203.0.113.1"},
+		{"proxy chain", "10.1.2.3:5000", []string{"203.0.113.1, 192.0.2.10", "10.9.9.9"}, "203.0.113.1"},
+		{"only proxies", "10.1.2.3:5000", []string{"10.4.4.4"}, "10.4.4.4"},
+		{"no header", "192.0.2.10:5000", nil, "192.0.2.10"},
+		{"ipv4-mapped peer", "[::ffff:10.1.2.3]:5000", []string{"203.0.113.1"}, "203.0.113.1"},
+		{"no port", "198.51.100.7", nil, "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.clientAddress(tt.remote, tt.forwarded); got != tt.want {
+				t.Errorf("clientAddress(%q, %q) = %q, want %q", tt.remote, tt.forwarded, got, tt.want)
+			}
+		})
+	}
+}
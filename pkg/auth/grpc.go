@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor authenticates gRPC calls with the API key sent in the
+// "x-api-key" or "authorization: Bearer" metadata. scopes maps full method
+// names to the scope they require; methods missing from it require admin.
+func (a *Authenticator) UnaryServerInterceptor(scopes map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !a.Enabled() {
+			return handler(ctx, req)
+		}
+
+		scope, ok := scopes[info.FullMethod]
+		if !ok {
+			scope = ScopeAdmin
+		}
+
+		key, failure := a.Authenticate(ctx, a.clientFromPeer(ctx), keyFromMetadata(ctx), scope, info.FullMethod)
+		if failure != nil {
+			return nil, status.Error(grpcCode(failure.Kind), failure.Message)
+		}
+		return handler(WithKey(ctx, key), req)
+	}
+}
+
+func keyFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		return values[0]
+	}
+	if values := md.Get("authorization"); len(values) > 0 && strings.HasPrefix(values[0], "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(values[0], "Bearer "))
+	}
+	return ""
+}
+
+// clientFromPeer returns the address of the client that made the call, read
+// from the x-forwarded-for metadata when the peer is a trusted proxy.
+func (a *Authenticator) clientFromPeer(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return a.clientAddress(p.Addr.String(), md.Get("x-forwarded-for"))
+}
+
+func grpcCode(kind string) codes.Code {
+	switch kind {
+	case FailureUnauthenticated:
+		return codes.Unauthenticated
+	case FailurePermissionDenied:
+		return codes.PermissionDenied
+	case FailureRateLimited:
+		return codes.ResourceExhausted
+	default:
+		return codes.Unavailable
+	}
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"crypto_price/pkg/api"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Failure describes why a request was rejected, independently of transport.
+type Failure struct {
+	Kind       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+// Failure kinds map onto the api error codes.
+const (
+	FailureUnauthenticated  = api.CodeUnauthenticated
+	FailurePermissionDenied = api.CodePermissionDenied
+	FailureRateLimited      = api.CodeRateLimited
+	FailureUnavailable      = api.CodeUnavailable
+)
+
+// Authenticate resolves raw, checks it grants scope, applies the key's rate
+// limit and records usage of route. Missing and unknown keys also count
+// against the failed authentication limit of client; valid keys are never
+// held back by it. It is shared by the HTTP middleware and the gRPC
+// interceptor.
+func (a *Authenticator) Authenticate(ctx context.Context, client, raw, scope, route string) (*APIKey, *Failure) {
+	key, err := a.LookupKey(ctx, raw)
+	if err != nil {
+		if errors.Is(err, ErrMissingKey) || errors.Is(err, ErrInvalidKey) {
+			if failure := a.recordFailure(ctx, client); failure != nil {
+				return nil, failure
+			}
+			return nil, &Failure{Kind: FailureUnauthenticated, Message: err.Error()}
+		}
+		slog.ErrorContext(ctx, "Error looking up API key", "error", err)
+		return nil, &Failure{Kind: FailureUnavailable, Message: "authentication is temporarily unavailable"}
+	}
+
+	if !key.HasScope(scope) {
+		return nil, &Failure{
+			Kind:    FailurePermissionDenied,
+			Message: fmt.Sprintf("API key %q lacks the %q scope", key.Name, scope),
+		}
+	}
+
+	allowed, retryAfter, err := a.Allow(ctx, key, route)
+	if err != nil {
+		// Rate limiting depends on Redis; prefer serving requests over
+		// rejecting every client while it is unreachable.
+		slog.WarnContext(ctx, "Error applying rate limit, allowing request", "key", key.Name, "error", err)
+	} else if !allowed {
+		return nil, &Failure{
+			Kind:       FailureRateLimited,
+			Message:    fmt.Sprintf("rate limit exceeded for API key %q", key.Name),
+			RetryAfter: retryAfter,
+		}
+	}
+
+	return key, nil
+}
+
+// recordFailure counts a failed authentication of client and returns a rate
+// limited failure once its bucket is empty. Like the per-key limit it fails
+// open when Redis is unreachable.
+func (a *Authenticator) recordFailure(ctx context.Context, client string) *Failure {
+	if client == "" {
+		return nil
+	}
+	allowed, retryAfter, err := a.AllowFailure(ctx, client)
+	if err != nil {
+		slog.WarnContext(ctx, "Error applying failed authentication limit", "client", client, "error", err)
+		return nil
+	}
+	if allowed {
+		return nil
+	}
+	return &Failure{
+		Kind:       FailureRateLimited,
+		Message:    "too many failed authentication attempts",
+		RetryAfter: retryAfter,
+	}
+}
+
+// RequireScope protects an HTTP handler with API key authentication. Keys are
+// read from the X-API-Key header or an "Authorization: Bearer" header. When
+// authentication is disabled in the configuration the handler is returned as is.
+func (a *Authenticator) RequireScope(scope string, next http.Handler) http.Handler {
+	if !a.Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, failure := a.Authenticate(r.Context(), a.clientFromRequest(r), keyFromRequest(r), scope, r.URL.Path)
+		if failure != nil {
+			writeFailure(w, failure)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), key)))
+	})
+}
+
+func keyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if authz := r.Header.Get("Authorization"); strings.HasPrefix(authz, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
+	}
+	return ""
+}
+
+// clientFromRequest returns the address of the client that sent r, read from
+// X-Forwarded-For when the peer is a trusted proxy.
+func (a *Authenticator) clientFromRequest(r *http.Request) string {
+	return a.clientAddress(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+}
+
+func writeFailure(w http.ResponseWriter, failure *Failure) {
+	switch failure.Kind {
+	case FailureUnauthenticated:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="crypto_price"`)
+		api.WriteError(w, http.StatusUnauthorized, failure.Kind, failure.Message, nil)
+	case FailurePermissionDenied:
+		api.WriteError(w, http.StatusForbidden, failure.Kind, failure.Message, nil)
+	case FailureRateLimited:
+		seconds := int(math.Ceil(failure.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		api.WriteError(w, http.StatusTooManyRequests, failure.Kind, failure.Message,
+			map[string]interface{}{"retry_after_seconds": seconds})
+	default:
+		api.WriteError(w, http.StatusServiceUnavailable, failure.Kind, failure.Message, nil)
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const usageRetention = 90 * 24 * time.Hour
+
+// tokenBucketScript refills the bucket stored at KEYS[1] and takes one token
+// from it. It uses the Redis clock so every replica shares the same view of
+// time. It returns {allowed, retry_after_ms}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate * 1000)
+end
+
+-- Fixed point, as some Lua implementations cannot parse the exponent form
+-- tostring uses for small numbers.
+redis.call('HSET', KEYS[1], 'tokens', string.format('%.6f', tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, retry}
+`)
+
+// Allow takes a token from the key's bucket and counts the request to route
+// in the key's usage, in a single round trip. When the bucket is empty it
+// returns false and how long the client should wait before retrying. Usage
+// is counted for rejected requests too; failing to count it is only logged.
+func (a *Authenticator) Allow(ctx context.Context, key *APIKey, route string) (bool, time.Duration, error) {
+	rate, burst := key.RateLimit, key.Burst
+	if rate <= 0 {
+		rate = a.cfg.Auth.RateLimitPerSecond
+	}
+	if burst <= 0 {
+		burst = a.cfg.Auth.RateLimitBurst
+	}
+
+	bucketKey := fmt.Sprintf("ratelimit:%s", key.ID.Hex())
+	usageKey := fmt.Sprintf("usage:%s:%s", key.ID.Hex(), time.Now().UTC().Format("20060102"))
+
+	var bucket *redis.Cmd
+	var usage *redis.IntCmd
+	// The commands go to different slots, so they are pipelined rather than
+	// run from one script, which Redis Cluster would reject.
+	a.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		bucket = tokenBucketScript.EvalSha(ctx, pipe, []string{bucketKey}, rate, burst)
+		usage = pipe.HIncrBy(ctx, usageKey, route, 1)
+		pipe.Expire(ctx, usageKey, usageRetention)
+		return nil
+	})
+	if err := usage.Err(); err != nil {
+		slog.WarnContext(ctx, "Error recording API key usage", "key", key.Name, "error", err)
+	}
+
+	res, err := bucket.Slice()
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		// Run falls back to EVAL, which also caches the script for the
+		// next requests.
+		res, err = tokenBucketScript.Run(ctx, a.rdb, []string{bucketKey}, rate, burst).Slice()
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to evaluate rate limit for %s: %w", key.Name, err)
+	}
+	return bucketResult(res)
+}
+
+// AllowFailure records a failed authentication of client and reports whether
+// it was still within the limit set by auth.failure_rate_limit_per_second and
+// auth.failure_rate_limit_burst.
+func (a *Authenticator) AllowFailure(ctx context.Context, client string) (bool, time.Duration, error) {
+	bucketKey := fmt.Sprintf("authfail:%s", client)
+	res, err := tokenBucketScript.Run(ctx, a.rdb, []string{bucketKey}, a.cfg.Auth.FailureRateLimitPerSecond, a.cfg.Auth.FailureRateLimitBurst).Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to evaluate failed authentication limit for %s: %w", client, err)
+	}
+	return bucketResult(res)
+}
+
+func bucketResult(res []interface{}) (bool, time.Duration, error) {
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket result %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	retryMs, _ := res[1].(int64)
+	return allowed == 1, time.Duration(retryMs) * time.Millisecond, nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestAuthenticator(t *testing.T, cfg *config.Config) (*Authenticator, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(cfg, nil, client), mr
+}
+
+func TestAllowFailure(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.Auth.FailureRateLimitPerSecond = 0.01
+	cfg.Auth.FailureRateLimitBurst = 3
+	a, _ := newTestAuthenticator(t, cfg)
+
+	for i := 0; i < 3; i++ {
+		if allowed, _, err := a.AllowFailure(ctx, "192.0.2.1"); err != nil || !allowed {
+			t.Fatalf("failure %d = %t, %v; want allowed", i, allowed, err)
+		}
+	}
+	allowed, retryAfter, err := a.AllowFailure(ctx, "192.0.2.1")
+	if err != nil || allowed {
+		t.Fatalf("failure after burst = %t, %v; want rejected", allowed, err)
+	}
+	if retryAfter <= 0 {
+		t.Errorf("retryAfter = %v, want positive", retryAfter)
+	}
+
+	// Other addresses have their own bucket.
+	if allowed, _, err := a.AllowFailure(ctx, "192.0.2.2"); err != nil || !allowed {
+		t.Errorf("other client = %t, %v; want allowed", allowed, err)
+	}
+}
+
+func TestAuthenticateFailureLimit(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.Auth.Enabled = true
+	cfg.Auth.FailureRateLimitPerSecond = 0.01
+	cfg.Auth.FailureRateLimitBurst = 2
+	a, mr := newTestAuthenticator(t, cfg)
+
+	key := &APIKey{ID: primitive.NewObjectID(), Name: "client", Scopes: []string{ScopePriceRead}}
+	a.keyCache.Set(HashKey("valid"), key)
+
+	wantKinds := []string{FailureUnauthenticated, FailureUnauthenticated, FailureRateLimited}
+	for i, want := range wantKinds {
+		if _, failure := a.Authenticate(ctx, "192.0.2.1", "", ScopePriceRead, "/v1/price"); failure == nil || failure.Kind != want {
+			t.Fatalf("attempt %d failure = %+v, want %s", i, failure, want)
+		}
+	}
+
+	// A valid key from the same address is not held back by the failures.
+	got, failure := a.Authenticate(ctx, "192.0.2.1", "valid", ScopePriceRead, "/v1/price")
+	if failure != nil || got != key {
+		t.Fatalf("valid key = %v, %+v; want authenticated", got, failure)
+	}
+	usageKey := "usage:" + key.ID.Hex() + ":" + time.Now().UTC().Format("20060102")
+	if count := mr.HGet(usageKey, "/v1/price"); count != "1" {
+		t.Errorf("usage count = %q, want 1", count)
+	}
+	if !mr.Exists("ratelimit:" + key.ID.Hex()) {
+		t.Error("rate limit bucket was not created")
+	}
+
+	if _, failure := a.Authenticate(ctx, "192.0.2.1", "valid", ScopeAdmin, "/v1/admin/symbols"); failure == nil || failure.Kind != FailurePermissionDenied {
+		t.Errorf("missing scope failure = %+v, want permission denied", failure)
+	}
+}
+
+func TestAllowRateLimit(t *testing.T) {
+	ctx := context.Background()
+	a, mr := newTestAuthenticator(t, config.Default())
+	key := &APIKey{ID: primitive.NewObjectID(), Name: "client", RateLimit: 0.01, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, err := a.Allow(ctx, key, "/v1/price"); err != nil || !allowed {
+			t.Fatalf("request %d = %t, %v; want allowed", i, allowed, err)
+		}
+	}
+	allowed, retryAfter, err := a.Allow(ctx, key, "/v1/price")
+	if err != nil || allowed || retryAfter <= 0 {
+		t.Errorf("request after burst = %t, %v, %v; want rejected with a retry delay", allowed, retryAfter, err)
+	}
+
+	// The script is reloaded when Redis lost it.
+	mr.FlushAll()
+	if err := a.rdb.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("ScriptFlush: %v", err)
+	}
+	if allowed, _, err := a.Allow(ctx, key, "/v1/price"); err != nil || !allowed {
+		t.Errorf("request after flush = %t, %v; want allowed", allowed, err)
+	}
+}
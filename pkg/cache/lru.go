@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Eviction reasons reported to the OnEvict callback.
+const (
+	EvictCapacity    = "capacity"
+	EvictExpired     = "expired"
+	EvictInvalidated = "invalidated"
+)
+
+// LRU is a size-bounded, concurrency-safe least-recently-used cache whose
+// entries also expire after a fixed TTL.
+type LRU[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+
+	// OnEvict, if set, is called with the reason whenever an entry is removed.
+	// It runs with the cache lock held and must not call back into the cache.
+	OnEvict func(reason string)
+}
+
+type entry[V any] struct {
+	key      string
+	value    V
+	storedAt time.Time
+}
+
+// NewLRU creates a cache holding at most capacity entries for up to ttl each.
+func NewLRU[V any](capacity int, ttl time.Duration) *LRU[V] {
+	return &LRU[V]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored under key and when it was stored.
+func (c *LRU[V]) Get(key string) (V, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, time.Time{}, false
+	}
+
+	e := el.Value.(*entry[V])
+	if time.Since(e.storedAt) > c.ttl {
+		c.remove(el, EvictExpired)
+		return zero, time.Time{}, false
+	}
+
+	c.ll.MoveToFront(el)
+	return e.value, e.storedAt, true
+}
+
+// Set stores value under key, evicting the least recently used entry when the
+// cache is full.
+func (c *LRU[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[V])
+		e.value = value
+		e.storedAt = time.Now()
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[V]{key: key, value: value, storedAt: time.Now()})
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back(), EvictCapacity)
+	}
+}
+
+// Delete removes the entry stored under key, reporting it to OnEvict as
+// invalidated, and returns whether there was one.
+func (c *LRU[V]) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if ok {
+		c.remove(el, EvictInvalidated)
+	}
+	return ok
+}
+
+// Len returns the number of entries, including expired ones not yet removed.
+func (c *LRU[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU[V]) remove(el *list.Element, reason string) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[V]).key)
+	if c.OnEvict != nil {
+		c.OnEvict(reason)
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[int](2, time.Hour)
+	var evicted []string
+	c.OnEvict = func(reason string) { evicted = append(evicted, reason) }
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if _, _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+	c.Set("c", 3)
+
+	if _, _, ok := c.Get("b"); ok {
+		t.Error("b was not evicted although it was least recently used")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, _, ok := c.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	if len(evicted) != 1 || evicted[0] != EvictCapacity {
+		t.Errorf("evictions = %v, want [%s]", evicted, EvictCapacity)
+	}
+}
+
+func TestLRUSetReplaces(t *testing.T) {
+	c := NewLRU[int](2, time.Hour)
+	c.Set("a", 1)
+	c.Set("a", 2)
+	if got, _, ok := c.Get("a"); !ok || got != 2 {
+		t.Errorf("Get(a) = %d, %t; want 2", got, ok)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want 1", c.Len())
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	c := NewLRU[int](2, 10*time.Millisecond)
+	var evicted []string
+	c.OnEvict = func(reason string) { evicted = append(evicted, reason) }
+
+	c.Set("a", 1)
+	if _, storedAt, ok := c.Get("a"); !ok || time.Since(storedAt) > time.Second {
+		t.Fatalf("Get(a) = %v, %t; want a fresh entry", storedAt, ok)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, _, ok := c.Get("a"); ok {
+		t.Error("a was served after its TTL")
+	}
+	if c.Len() != 0 || len(evicted) != 1 || evicted[0] != EvictExpired {
+		t.Errorf("Len = %d, evictions = %v; want 0, [%s]", c.Len(), evicted, EvictExpired)
+	}
+}
+
+func TestLRUDelete(t *testing.T) {
+	c := NewLRU[int](2, time.Hour)
+	var evicted []string
+	c.OnEvict = func(reason string) { evicted = append(evicted, reason) }
+
+	c.Set("a", 1)
+	if !c.Delete("a") {
+		t.Error("Delete(a) = false, want true")
+	}
+	if c.Delete("a") {
+		t.Error("second Delete(a) = true, want false")
+	}
+	if _, _, ok := c.Get("a"); ok {
+		t.Error("a was served after Delete")
+	}
+	if len(evicted) != 1 || evicted[0] != EvictInvalidated {
+		t.Errorf("evictions = %v, want [%s]", evicted, EvictInvalidated)
+	}
+}
@@ -1,145 +1,358 @@
 package config
 
 import (
-	"log"
-	"os"
-	"path/filepath"
-
-	yaml "gopkg.in/yaml.v3"
+	"time"
 )
 
+// Config is the complete service configuration. Every field can be set in
+// the config file under the path given by its yaml tags, or through the
+// environment variable named after that path (see env.go). Fields tagged
+// reload:"safe" can be changed while the service runs (see reload.go).
 type Config struct {
-	MongoHost           string
-	ConfigCollection    string
-	MarketDatabase      string
-	RedisHost           string
-	TradeDatabase       string
-	LastTradeCollection string
-	SentryDSN           string
-	ServerPort          string
-}
-
-func GetConfigs() *Config {
-	config := &Config{
-		// Default values - will be overridden by env vars or config file
-		MongoHost:           "mongodb://localhost:27017",
-		ConfigCollection:    "market-making-configs",
-		MarketDatabase:      "market-bot",
-		RedisHost:           "localhost:6379",
-		TradeDatabase:       "market_making",
-		LastTradeCollection: "last_trades",
-		SentryDSN:           "",
-		ServerPort:          "8080",
-	}
+	Server     ServerConfig     `yaml:"server"`
+	Log        LogConfig        `yaml:"log"`
+	Mongo      MongoConfig      `yaml:"mongo"`
+	Redis      RedisConfig      `yaml:"redis"`
+	Store      StoreConfig      `yaml:"store"`
+	PriceCache PriceCacheConfig `yaml:"price_cache"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Jobs       JobsConfig       `yaml:"jobs"`
+	Exchanges  ExchangesConfig  `yaml:"exchanges"`
+	Thresholds ThresholdsConfig `yaml:"thresholds"`
+	Health     HealthConfig     `yaml:"health"`
+	Sentry     SentryConfig     `yaml:"sentry"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Reload     ReloadConfig     `yaml:"reload"`
 
-	// Try to load from config file first
-	loadFromFile(config)
+	// file is the config file the configuration was read from, if any.
+	file string
+	// settings is the settings document applied on top of the file, if any.
+	settings map[string]interface{}
+}
 
-	// Override with environment variables (these take precedence)
-	loadFromEnv(config)
+// File returns the path of the config file c was read from, or "" when it
+// was built from defaults and the environment only.
+func (c *Config) File() string {
+	return c.file
+}
 
-	return config
+type ServerConfig struct {
+	Port            string        `yaml:"port"`
+	GRPCPort        string        `yaml:"grpc_port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// Pprof serves the Go profiler under /debug/pprof/, to admin keys when
+	// authentication is enabled.
+	Pprof bool `yaml:"pprof"`
 }
 
-func loadFromFile(config *Config) {
-	// Try multiple possible config file locations
-	configPaths := []string{
-		"pkg/config/env.yml",
-		"./pkg/config/env.yml",
-		filepath.Join(os.Getenv("PWD"), "pkg/config/env.yml"),
-	}
+type LogConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string `yaml:"level" reload:"safe"`
+	// Format is "text" for logfmt-style lines or "json".
+	Format string `yaml:"format"`
+	// SampleInterval is how often an error that repeats for the same
+	// symbol, such as a failing exchange request, is logged.
+	SampleInterval time.Duration `yaml:"sample_interval" reload:"safe"`
+}
 
-	var f []byte
-	var err error
+type MongoConfig struct {
+	URI string `yaml:"uri"`
+	// Username and Password, when set, replace the credentials in URI.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
 
-	for _, path := range configPaths {
-		f, err = os.ReadFile(path)
-		if err == nil {
-			log.Printf("Loaded config from: %s", path)
-			break
-		}
-	}
+	MarketDatabase        string `yaml:"market_database"`
+	TradeDatabase         string `yaml:"trade_database"`
+	ConfigCollection      string `yaml:"config_collection"`
+	LastTradeCollection   string `yaml:"last_trade_collection"`
+	APIKeyCollection      string `yaml:"api_key_collection"`
+	SymbolCollection      string `yaml:"symbol_collection"`
+	SymbolAuditCollection string `yaml:"symbol_audit_collection"`
+	// SettingsCollection holds the settings document applied on reload.
+	SettingsCollection string `yaml:"settings_collection"`
 
-	if err != nil {
-		log.Printf("Could not load config file, using defaults: %v", err)
-		return
-	}
+	MaxPoolSize            int           `yaml:"max_pool_size"`
+	MinPoolSize            int           `yaml:"min_pool_size"`
+	ConnectTimeout         time.Duration `yaml:"connect_timeout"`
+	ServerSelectionTimeout time.Duration `yaml:"server_selection_timeout"`
+	// ReconnectInterval bounds how often a failed connection is retried.
+	ReconnectInterval time.Duration `yaml:"reconnect_interval"`
+}
 
-	var data map[string]string
-	if err = yaml.Unmarshal(f, &data); err != nil {
-		log.Printf("Error parsing config file: %v", err)
-		return
-	}
+type RedisConfig struct {
+	// Addrs is a single node, the Sentinel addresses or the cluster seeds.
+	Addrs            []string `yaml:"addrs"`
+	Username         string   `yaml:"username"`
+	Password         string   `yaml:"password"`
+	DB               int      `yaml:"db"`
+	Mode             string   `yaml:"mode"`
+	MasterName       string   `yaml:"master_name"`
+	SentinelPassword string   `yaml:"sentinel_password"`
+	TLS              bool     `yaml:"tls"`
+	TLSCAFile        string   `yaml:"tls_ca_file"`
+	PoolSize         int      `yaml:"pool_size"`
+	MinIdleConns     int      `yaml:"min_idle_conns"`
+	PriceLayout      string   `yaml:"price_layout"`
+}
 
-	// Map YAML values to config struct
-	if val, ok := data["MONGO_HOST"]; ok {
-		config.MongoHost = val
-	}
-	if val, ok := data["CONFIG_COLLECTION"]; ok {
-		config.ConfigCollection = val
-	}
-	if val, ok := data["MARKET_DATABASE"]; ok {
-		config.MarketDatabase = val
-	}
-	if val, ok := data["REDIS_HOST"]; ok {
-		config.RedisHost = val
-	}
-	if val, ok := data["TRADE_DATABASE"]; ok {
-		config.TradeDatabase = val
-	}
-	if val, ok := data["LAST_TRADE_COLLECTION"]; ok {
-		config.LastTradeCollection = val
-	}
+type StoreConfig struct {
+	Backend string `yaml:"backend"`
+	// ShortTermTTL and LongTermTTL bound the two freshness tiers of stored
+	// prices; UsdtIrrTTL is how long a USDT/IRR rate stays readable.
+	ShortTermTTL time.Duration `yaml:"short_term_ttl" reload:"safe"`
+	LongTermTTL  time.Duration `yaml:"long_term_ttl" reload:"safe"`
+	UsdtIrrTTL   time.Duration `yaml:"usdt_irr_ttl" reload:"safe"`
 }
 
-func loadFromEnv(config *Config) {
-	// Environment variables override config file values
-	if val := os.Getenv("MONGO_HOST"); val != "" {
-		config.MongoHost = val
-	}
-	if val := os.Getenv("MONGO_USER"); val != "" {
-		config.MongoHost = "mongodb://" + val + ":" + os.Getenv("MONGO_PASSWORD") + "@" + extractHostFromMongoURI(config.MongoHost)
-	}
-	if val := os.Getenv("CONFIG_COLLECTION"); val != "" {
-		config.ConfigCollection = val
-	}
-	if val := os.Getenv("MARKET_DATABASE"); val != "" {
-		config.MarketDatabase = val
-	}
-	if val := os.Getenv("REDIS_HOST"); val != "" {
-		config.RedisHost = val
-	}
-	if val := os.Getenv("TRADE_DATABASE"); val != "" {
-		config.TradeDatabase = val
-	}
-	if val := os.Getenv("LAST_TRADE_COLLECTION"); val != "" {
-		config.LastTradeCollection = val
-	}
-	if val := os.Getenv("SENTRY_DSN"); val != "" {
-		config.SentryDSN = val
-	}
-	if val := os.Getenv("SERVER_PORT"); val != "" {
-		config.ServerPort = val
-	}
+type PriceCacheConfig struct {
+	Size int           `yaml:"size"`
+	TTL  time.Duration `yaml:"ttl"`
 }
 
-func extractHostFromMongoURI(uri string) string {
-	// Simple extraction - you might want to use a proper URI parser
-	if idx := findNth(uri, "@", 1); idx != -1 {
-		return uri[idx+1:]
-	}
-	return uri
-}
-
-func findNth(s string, substr string, n int) int {
-	count := 0
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			count++
-			if count == n {
-				return i
-			}
-		}
+type AuthConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	RateLimitPerSecond float64       `yaml:"rate_limit_per_second"`
+	RateLimitBurst     int           `yaml:"rate_limit_burst"`
+	KeyCacheTTL        time.Duration `yaml:"key_cache_ttl"`
+	// FailureRateLimitPerSecond and FailureRateLimitBurst bound failed
+	// authentications per client address.
+	FailureRateLimitPerSecond float64 `yaml:"failure_rate_limit_per_second"`
+	FailureRateLimitBurst     int     `yaml:"failure_rate_limit_burst"`
+	// TrustedProxies are the addresses and CIDR ranges of proxies whose
+	// X-Forwarded-For header identifies the client for that limit.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type JobsConfig struct {
+	KucoinInterval time.Duration `yaml:"kucoin_interval" reload:"safe"`
+	// SymbolPollInterval is how often the KuCoin symbols are reloaded when
+	// MongoDB change streams are unavailable.
+	SymbolPollInterval time.Duration `yaml:"symbol_poll_interval" reload:"safe"`
+	BinanceEnabled     bool          `yaml:"binance_enabled" reload:"safe"`
+	BinanceInterval    time.Duration `yaml:"binance_interval" reload:"safe"`
+	UsdtIrrInterval    time.Duration `yaml:"usdt_irr_interval" reload:"safe"`
+	UsdtIrrTimeout     time.Duration `yaml:"usdt_irr_timeout" reload:"safe"`
+	// UsdtIrrMode is "batch" to recompute USDT/IRR rates every
+	// UsdtIrrInterval, or "stream" to update them from a change stream of
+	// the trades.
+	UsdtIrrMode string `yaml:"usdt_irr_mode"`
+	// UsdtIrrSources are the markets the USDT/IRR rate is computed for.
+	UsdtIrrSources []string `yaml:"usdt_irr_sources" reload:"safe"`
+	// UsdtIrrWindow is how far back trades are considered and
+	// UsdtIrrMinAmount the smallest trade taken into account.
+	UsdtIrrWindow    time.Duration `yaml:"usdt_irr_window" reload:"safe"`
+	UsdtIrrMinAmount float64       `yaml:"usdt_irr_min_amount" reload:"safe"`
+	// SuppressAfter is how many consecutive failed fetches, by all
+	// replicas together, stop ingestion of a KuCoin symbol until it is
+	// re-enabled through the admin API. 0 never suppresses symbols.
+	SuppressAfter int `yaml:"suppress_after" reload:"safe"`
+}
+
+type ExchangesConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+	// PriceTimeout bounds each per-symbol price request.
+	PriceTimeout time.Duration `yaml:"price_timeout"`
+	BinanceURL   string        `yaml:"binance_url"`
+	KucoinURL    string        `yaml:"kucoin_url"`
+	// Binance and Kucoin limit the outbound requests to each exchange.
+	Binance OutboundConfig `yaml:"binance"`
+	Kucoin  OutboundConfig `yaml:"kucoin"`
+}
+
+// OutboundConfig limits the requests made to one exchange.
+type OutboundConfig struct {
+	// MaxConcurrency is how many requests may be in flight at once.
+	MaxConcurrency int `yaml:"max_concurrency"`
+	// RateLimit is the request weight spent per second on average, and
+	// Burst the weight that may be spent at once. Endpoints cost the
+	// weight the exchange assigns them.
+	RateLimit float64 `yaml:"rate_limit"`
+	Burst     int     `yaml:"burst"`
+	// MaxRetries is how many times a request failing with a network
+	// error, 429 or 5xx is retried. RetryBackoff is the delay before the
+	// first retry, doubled for each following one. A Retry-After longer
+	// than MaxRetryWait is not waited for.
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	MaxRetryWait time.Duration `yaml:"max_retry_wait"`
+	// BreakerThreshold consecutive failures open the circuit: requests
+	// fail immediately for BreakerCooldown, then a single probe decides
+	// whether it closes again.
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+}
+
+type ThresholdsConfig struct {
+	// PriceFreshness is the age after which responses carry an outdated note.
+	PriceFreshness time.Duration `yaml:"price_freshness" reload:"safe"`
+}
+
+type HealthConfig struct {
+	// Interval is how often the dependencies are checked in the background.
+	// Health endpoints serve the latest results.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout bounds each check.
+	Timeout time.Duration `yaml:"timeout"`
+	// Critical lists the checks (see HealthChecks) whose failure makes the
+	// service unhealthy and not ready. Failures of the others degrade it.
+	Critical []string `yaml:"critical" reload:"safe"`
+	// MaxPriceAge is how long the KuCoin and Binance jobs may go without
+	// writing prices before the service is degraded.
+	MaxPriceAge time.Duration `yaml:"max_price_age" reload:"safe"`
+	// MaxUsdtIrrAge is the same for the USDT/IRR rates.
+	MaxUsdtIrrAge time.Duration `yaml:"max_usdt_irr_age" reload:"safe"`
+	// MinUsdtIrrTrades is the number of trades below which a USDT/IRR rate
+	// is reported as insufficient in the freshness report.
+	MinUsdtIrrTrades int `yaml:"min_usdt_irr_trades" reload:"safe"`
+}
+
+type SentryConfig struct {
+	DSN              string  `yaml:"dsn"`
+	TracesSampleRate float64 `yaml:"traces_sample_rate"`
+}
+
+type TracingConfig struct {
+	// Endpoint is the host:port of the OTLP collector. Spans are not
+	// exported when it is empty.
+	Endpoint string `yaml:"endpoint"`
+	// Protocol is "grpc" or "http" (OTLP over HTTP/protobuf).
+	Protocol string `yaml:"protocol"`
+	// Insecure disables TLS towards the collector.
+	Insecure    bool   `yaml:"insecure"`
+	ServiceName string `yaml:"service_name"`
+	// SampleRate is the share of traces started by the service that are
+	// recorded. Traces continued from a caller follow its decision.
+	SampleRate float64 `yaml:"sample_rate"`
+}
+
+type ReloadConfig struct {
+	// Interval is how often the config file and the settings document are
+	// checked for changes.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// HealthChecks lists the checks that can be marked critical in
+// health.critical.
+var HealthChecks = []string{"price_store", "mongodb", "kucoin", "binance", "kucoin_data", "binance_data", "usdt_irr_data", "prices"}
+
+// UsdtIrrSources lists every source the USDT/IRR rate can be computed for.
+var UsdtIrrSources = []string{"wallex", "nobitex", "bitpin", "ramzinex"}
+
+// Default returns the configuration used for every value not set in the
+// config file or the environment.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			GRPCPort:        "50051",
+			ShutdownTimeout: 10 * time.Second,
+		},
+		Log: LogConfig{
+			Level:          "info",
+			Format:         "text",
+			SampleInterval: time.Minute,
+		},
+		Mongo: MongoConfig{
+			URI:                    "mongodb://localhost:27017",
+			MarketDatabase:         "market-bot",
+			TradeDatabase:          "market_making",
+			ConfigCollection:       "market-making-configs",
+			LastTradeCollection:    "last_trades",
+			APIKeyCollection:       "api_keys",
+			SymbolCollection:       "tracked_symbols",
+			SymbolAuditCollection:  "symbol_audit_log",
+			SettingsCollection:     "service_settings",
+			MaxPoolSize:            10,
+			MinPoolSize:            2,
+			ConnectTimeout:         10 * time.Second,
+			ServerSelectionTimeout: 5 * time.Second,
+			ReconnectInterval:      10 * time.Second,
+		},
+		Redis: RedisConfig{
+			Addrs:        []string{"localhost:6379"},
+			PoolSize:     10,
+			MinIdleConns: 2,
+			PriceLayout:  "both",
+		},
+		Store: StoreConfig{
+			Backend:      "redis",
+			ShortTermTTL: 20 * time.Second,
+			LongTermTTL:  10 * time.Minute,
+			UsdtIrrTTL:   5 * time.Minute,
+		},
+		PriceCache: PriceCacheConfig{
+			Size: 10000,
+			TTL:  5 * time.Second,
+		},
+		Auth: AuthConfig{
+			RateLimitPerSecond: 10,
+			RateLimitBurst:     20,
+			KeyCacheTTL:        time.Minute,
+
+			FailureRateLimitPerSecond: 0.2,
+			FailureRateLimitBurst:     10,
+		},
+		Jobs: JobsConfig{
+			KucoinInterval:     15 * time.Second,
+			SymbolPollInterval: 15 * time.Second,
+			BinanceInterval:    15 * time.Second,
+			UsdtIrrInterval:    2 * time.Minute,
+			UsdtIrrTimeout:     10 * time.Second,
+			UsdtIrrMode:        "batch",
+			UsdtIrrSources:     append([]string(nil), UsdtIrrSources...),
+			UsdtIrrWindow:      30 * time.Minute,
+			UsdtIrrMinAmount:   50,
+			SuppressAfter:      20,
+		},
+		Exchanges: ExchangesConfig{
+			Timeout:      10 * time.Second,
+			PriceTimeout: 5 * time.Second,
+			BinanceURL:   "https://api.binance.com",
+			KucoinURL:    "https://api.kucoin.com",
+			// Binance allows 6000 weight per minute and KuCoin 2000 per 30
+			// seconds for public endpoints; both defaults stay well below.
+			Binance: OutboundConfig{
+				MaxConcurrency:   8,
+				RateLimit:        20,
+				Burst:            100,
+				MaxRetries:       2,
+				RetryBackoff:     250 * time.Millisecond,
+				MaxRetryWait:     5 * time.Second,
+				BreakerThreshold: 5,
+				BreakerCooldown:  30 * time.Second,
+			},
+			Kucoin: OutboundConfig{
+				MaxConcurrency:   16,
+				RateLimit:        40,
+				Burst:            200,
+				MaxRetries:       2,
+				RetryBackoff:     250 * time.Millisecond,
+				MaxRetryWait:     5 * time.Second,
+				BreakerThreshold: 5,
+				BreakerCooldown:  30 * time.Second,
+			},
+		},
+		Thresholds: ThresholdsConfig{
+			PriceFreshness: 20 * time.Second,
+		},
+		Health: HealthConfig{
+			Interval:         15 * time.Second,
+			Timeout:          5 * time.Second,
+			Critical:         []string{"price_store", "mongodb"},
+			MaxPriceAge:      time.Minute,
+			MaxUsdtIrrAge:    10 * time.Minute,
+			MinUsdtIrrTrades: 5,
+		},
+		Sentry: SentryConfig{
+			TracesSampleRate: 1.0,
+		},
+		Tracing: TracingConfig{
+			Protocol:    "grpc",
+			ServiceName: "crypto_price",
+			SampleRate:  1.0,
+		},
+		Reload: ReloadConfig{
+			Interval: 10 * time.Second,
+		},
 	}
-	return -1
-}
\ No newline at end of file
+}
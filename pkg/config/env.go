@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every setting can be overridden by the environment variable named after its
+// path in the config file, e.g. JOBS_KUCOIN_INTERVAL for jobs.kucoin_interval.
+// Lists are comma-separated and durations use Go syntax ("15s", "2m").
+// Empty variables are ignored.
+
+// legacyEnv maps variable names from before the configuration was nested to
+// their current names. They are still honoured; the current name wins when
+// both are set.
+var legacyEnv = map[string]string{
+	"MONGO_HOST":              "MONGO_URI",
+	"MONGO_USER":              "MONGO_USERNAME",
+	"MARKET_DATABASE":         "MONGO_MARKET_DATABASE",
+	"TRADE_DATABASE":          "MONGO_TRADE_DATABASE",
+	"CONFIG_COLLECTION":       "MONGO_CONFIG_COLLECTION",
+	"LAST_TRADE_COLLECTION":   "MONGO_LAST_TRADE_COLLECTION",
+	"API_KEY_COLLECTION":      "MONGO_API_KEY_COLLECTION",
+	"SYMBOL_COLLECTION":       "MONGO_SYMBOL_COLLECTION",
+	"SYMBOL_AUDIT_COLLECTION": "MONGO_SYMBOL_AUDIT_COLLECTION",
+	"REDIS_HOST":              "REDIS_ADDRS",
+	"PRICE_STORE":             "STORE_BACKEND",
+	"RATE_LIMIT_PER_SECOND":   "AUTH_RATE_LIMIT_PER_SECOND",
+	"RATE_LIMIT_BURST":        "AUTH_RATE_LIMIT_BURST",
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting is a single value of the configuration.
+type setting struct {
+	// path holds the yaml keys leading to the value.
+	path  []string
+	field reflect.StructField
+	value reflect.Value
+}
+
+// name returns the path as written in the config file, e.g. jobs.kucoin_interval.
+func (s setting) name() string {
+	return strings.Join(s.path, ".")
+}
+
+// envName returns the environment variable of the setting, e.g.
+// JOBS_KUCOIN_INTERVAL.
+func (s setting) envName() string {
+	return strings.ToUpper(strings.Join(s.path, "_"))
+}
+
+// settings returns every value of cfg in declaration order.
+func settings(cfg *Config) []setting {
+	var out []setting
+	collectSettings(reflect.ValueOf(cfg).Elem(), nil, &out)
+	return out
+}
+
+func collectSettings(v reflect.Value, prefix []string, out *[]setting) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := append(append([]string(nil), prefix...), field.Tag.Get("yaml"))
+		if v.Field(i).Kind() == reflect.Struct {
+			collectSettings(v.Field(i), path, out)
+			continue
+		}
+		*out = append(*out, setting{path: path, field: field, value: v.Field(i)})
+	}
+}
+
+// fields returns every setting of cfg keyed by its environment variable name.
+func fields(cfg *Config) map[string]reflect.Value {
+	out := make(map[string]reflect.Value)
+	for _, s := range settings(cfg) {
+		out[s.envName()] = s.value
+	}
+	return out
+}
+
+// applyEnv sets every setting lookup has a non-empty value for.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	targets := fields(cfg)
+	var errs []error
+
+	apply := func(name, target string) {
+		val, ok := lookup(name)
+		if !ok || val == "" {
+			return
+		}
+		if err := setValue(targets[target], val); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	legacy := make([]string, 0, len(legacyEnv))
+	for name := range legacyEnv {
+		legacy = append(legacy, name)
+	}
+	sort.Strings(legacy)
+	for _, name := range legacy {
+		apply(name, legacyEnv[name])
+	}
+	for name := range targets {
+		apply(name, name)
+	}
+
+	return errors.Join(errs...)
+}
+
+func setValue(v reflect.Value, val string) error {
+	switch {
+	case v.Type() == durationType:
+		parsed, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(parsed))
+	case v.Kind() == reflect.String:
+		v.SetString(val)
+	case v.Kind() == reflect.Bool:
+		parsed, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		v.SetBool(parsed)
+	case v.Kind() == reflect.Int:
+		parsed, err := strconv.Atoi(val)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(parsed))
+	case v.Kind() == reflect.Float64:
+		parsed, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(parsed)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
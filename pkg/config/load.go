@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// defaultPaths are searched for a config file when none is given.
+var defaultPaths = []string{
+	"pkg/config/env.yml",
+	filepath.Join(os.Getenv("PWD"), "pkg/config/env.yml"),
+}
+
+// SettingsReader returns the settings document for a configuration built
+// from the file and the environment, which tells it where the document is. A
+// nil map means there is none.
+type SettingsReader func(ctx context.Context, cfg *Config) (map[string]interface{}, error)
+
+// Load builds the configuration from the defaults, the config file at path,
+// the settings document returned by readSettings and the environment, in
+// increasing order of precedence, and validates the result. An empty path
+// searches the default locations and falls back to defaults and environment
+// when no file exists there. readSettings may be nil; when it fails or the
+// document is invalid, the service starts without the document.
+func Load(path string, readSettings SettingsReader) (*Config, error) {
+	file, data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		slog.Info("Loaded config", "file", file)
+	} else {
+		slog.Info("No config file found, using defaults and environment")
+	}
+
+	cfg, err := build(file, data, nil)
+	if err != nil || readSettings == nil {
+		return cfg, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Reload.Interval)
+	defer cancel()
+	settings, err := readSettings(ctx, cfg)
+	if err != nil {
+		slog.Warn("Settings document unavailable, starting without it", "error", err)
+		return cfg, nil
+	}
+	if settings == nil {
+		return cfg, nil
+	}
+	withSettings, err := build(file, data, settings)
+	if err != nil {
+		slog.Warn("Settings document ignored", "error", err)
+		return cfg, nil
+	}
+	slog.Info("Loaded settings document")
+	return withSettings, nil
+}
+
+// readFile returns the config file at path, or the first one found in the
+// default locations when path is empty. It returns an empty name when no
+// default file exists.
+func readFile(path string) (string, []byte, error) {
+	paths := defaultPaths
+	if path != "" {
+		paths = []string{path}
+	}
+
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if errors.Is(err, os.ErrNotExist) && path == "" {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		return p, data, nil
+	}
+	return "", nil, nil
+}
+
+// build applies the file contents, the settings overrides and the
+// environment to the defaults and validates the result.
+func build(file string, data []byte, overrides map[string]interface{}) (*Config, error) {
+	cfg := Default()
+	cfg.file = file
+	cfg.settings = overrides
+
+	if data != nil {
+		if err := decodeFile(cfg, data); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", file, err)
+		}
+	}
+	if len(overrides) > 0 {
+		if err := decodeOverrides(cfg, overrides); err != nil {
+			return nil, fmt.Errorf("failed to parse settings document: %w", err)
+		}
+	}
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// decodeOverrides applies a document in the nested file format, such as the
+// settings document stored in MongoDB, rejecting unknown keys.
+func decodeOverrides(cfg *Config, overrides map[string]interface{}) error {
+	data, err := yaml.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(cfg)
+}
+
+// decodeFile reads the nested YAML format, rejecting unknown keys. Files in
+// the older flat format, whose top-level keys are environment variable names,
+// are still accepted.
+func decodeFile(cfg *Config, data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	if isFlatFile(root.Content[0]) {
+		slog.Warn("Config file uses the deprecated flat format; see README for the nested format")
+		return decodeFlatFile(cfg, data)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func isFlatFile(node *yaml.Node) bool {
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return false
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if key != strings.ToUpper(key) {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeFlatFile(cfg *Config, data []byte) error {
+	var flat map[string]string
+	if err := yaml.Unmarshal(data, &flat); err != nil {
+		return err
+	}
+
+	known := fields(cfg)
+	var unknown []string
+	for name := range flat {
+		if _, ok := known[name]; ok {
+			continue
+		}
+		if _, ok := legacyEnv[name]; ok {
+			continue
+		}
+		unknown = append(unknown, name)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+
+	return applyEnv(cfg, func(name string) (string, bool) {
+		val, ok := flat[name]
+		return val, ok
+	})
+}
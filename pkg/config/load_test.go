@@ -0,0 +1,237 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv blanks every variable build reads, as empty variables are
+// ignored, so the tests do not depend on the environment they run in.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for name := range fields(Default()) {
+		t.Setenv(name, "")
+	}
+	for name := range legacyEnv {
+		t.Setenv(name, "")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config) {
+				want := Default()
+				want.file = "test.yml"
+				if !reflect.DeepEqual(cfg, want) {
+					t.Errorf("build without input = %+v, want the defaults", cfg)
+				}
+			},
+		},
+		{
+			name: "nested file",
+			file: "server:\n  port: \"9090\"\njobs:\n  kucoin_interval: 30s\n  usdt_irr_sources: [nobitex]\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != "9090" || cfg.Jobs.KucoinInterval != 30*time.Second {
+					t.Errorf("port = %q, kucoin interval = %v; want 9090, 30s", cfg.Server.Port, cfg.Jobs.KucoinInterval)
+				}
+				if !reflect.DeepEqual(cfg.Jobs.UsdtIrrSources, []string{"nobitex"}) {
+					t.Errorf("usdt_irr_sources = %v, want [nobitex]", cfg.Jobs.UsdtIrrSources)
+				}
+				if cfg.Server.GRPCPort != "50051" {
+					t.Errorf("grpc port = %q, want the default", cfg.Server.GRPCPort)
+				}
+			},
+		},
+		{
+			name: "flat file",
+			file: "MONGO_HOST: mongodb://mongo:27017\nREDIS_HOST: redis:6379\nSERVER_PORT: \"9090\"\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Mongo.URI != "mongodb://mongo:27017" || cfg.Server.Port != "9090" {
+					t.Errorf("mongo uri = %q, port = %q", cfg.Mongo.URI, cfg.Server.Port)
+				}
+				if !reflect.DeepEqual(cfg.Redis.Addrs, []string{"redis:6379"}) {
+					t.Errorf("redis addrs = %v, want [redis:6379]", cfg.Redis.Addrs)
+				}
+			},
+		},
+		{
+			name: "environment over file",
+			file: "server:\n  port: \"9090\"\n",
+			env:  map[string]string{"SERVER_PORT": "7070", "JOBS_USDT_IRR_SOURCES": "wallex, bitpin"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != "7070" {
+					t.Errorf("port = %q, want 7070", cfg.Server.Port)
+				}
+				if !reflect.DeepEqual(cfg.Jobs.UsdtIrrSources, []string{"wallex", "bitpin"}) {
+					t.Errorf("usdt_irr_sources = %v, want [wallex bitpin]", cfg.Jobs.UsdtIrrSources)
+				}
+			},
+		},
+		{
+			name: "legacy environment",
+			env: map[string]string{
+				"MONGO_HOST":            "mongodb://legacy:27017",
+				"MONGO_USER":            "svc",
+				"REDIS_HOST":            "a:6379,b:6379",
+				"PRICE_STORE":           "memory",
+				"RATE_LIMIT_PER_SECOND": "3",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Mongo.URI != "mongodb://legacy:27017" || cfg.Mongo.Username != "svc" {
+					t.Errorf("mongo = %q as %q", cfg.Mongo.URI, cfg.Mongo.Username)
+				}
+				if !reflect.DeepEqual(cfg.Redis.Addrs, []string{"a:6379", "b:6379"}) {
+					t.Errorf("redis addrs = %v", cfg.Redis.Addrs)
+				}
+				if cfg.Store.Backend != "memory" || cfg.Auth.RateLimitPerSecond != 3 {
+					t.Errorf("backend = %q, rate limit = %v", cfg.Store.Backend, cfg.Auth.RateLimitPerSecond)
+				}
+			},
+		},
+		{
+			name: "current name wins over legacy",
+			env:  map[string]string{"MONGO_HOST": "mongodb://legacy:27017", "MONGO_URI": "mongodb://current:27017"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Mongo.URI != "mongodb://current:27017" {
+					t.Errorf("mongo uri = %q, want the current variable", cfg.Mongo.URI)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, val := range tt.env {
+				t.Setenv(name, val)
+			}
+			var data []byte
+			if tt.file != "" {
+				data = []byte(tt.file)
+			}
+			cfg, err := build("test.yml", data, nil)
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want string
+	}{
+		{"unknown nested key", "server:\n  prot: \"9090\"\n", nil, "prot"},
+		{"unknown flat key", "SERVER_PROT: \"9090\"\n", nil, "unknown keys: SERVER_PROT"},
+		{"invalid duration", "", map[string]string{"JOBS_KUCOIN_INTERVAL": "often"}, "JOBS_KUCOIN_INTERVAL"},
+		{"invalid value", "", map[string]string{"LOG_LEVEL": "loud"}, "log.level"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, val := range tt.env {
+				t.Setenv(name, val)
+			}
+			_, err := build("test.yml", []byte(tt.file), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("build error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildOverrides(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("LOG_LEVEL", "warn")
+	overrides := map[string]interface{}{
+		"log":  map[string]interface{}{"level": "debug", "format": "json"},
+		"jobs": map[string]interface{}{"kucoin_interval": "45s"},
+	}
+	cfg, err := build("test.yml", []byte("log:\n  format: text\n"), overrides)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	// The settings document overrides the file, the environment overrides both.
+	if cfg.Log.Format != "json" || cfg.Log.Level != "warn" || cfg.Jobs.KucoinInterval != 45*time.Second {
+		t.Errorf("format = %q, level = %q, interval = %v; want json, warn, 45s", cfg.Log.Format, cfg.Log.Level, cfg.Jobs.KucoinInterval)
+	}
+
+	if _, err := build("", nil, map[string]interface{}{"jobs": map[string]interface{}{"unknown": 1}}); err == nil {
+		t.Error("build accepted an unknown key in the settings document")
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	clearEnv(t)
+	path := filepath.Join(t.TempDir(), "env.yml")
+	if err := os.WriteFile(path, []byte("server:\n  port: \"9090\"\njobs:\n  kucoin_interval: 30s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		settings     map[string]interface{}
+		err          error
+		wantPort     string
+		wantInterval time.Duration
+	}{
+		{
+			name:         "no document",
+			wantPort:     "9090",
+			wantInterval: 30 * time.Second,
+		},
+		{
+			name: "document over file",
+			settings: map[string]interface{}{
+				"server": map[string]interface{}{"port": "7070"},
+				"jobs":   map[string]interface{}{"kucoin_interval": "45s"},
+			},
+			wantPort:     "7070",
+			wantInterval: 45 * time.Second,
+		},
+		{
+			name:         "unreadable document",
+			err:          errors.New("connection refused"),
+			wantPort:     "9090",
+			wantInterval: 30 * time.Second,
+		},
+		{
+			name:         "invalid document",
+			settings:     map[string]interface{}{"jobs": map[string]interface{}{"unknown": 1}},
+			wantPort:     "9090",
+			wantInterval: 30 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(path, func(ctx context.Context, cfg *Config) (map[string]interface{}, error) {
+				if cfg.Server.Port != "9090" {
+					t.Errorf("settings read with port %q, want the file's", cfg.Server.Port)
+				}
+				return tt.settings, tt.err
+			})
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Server.Port != tt.wantPort || cfg.Jobs.KucoinInterval != tt.wantInterval {
+				t.Errorf("port = %q, interval = %v; want %q, %v", cfg.Server.Port, cfg.Jobs.KucoinInterval, tt.wantPort, tt.wantInterval)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"io"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// Redacted returns a copy of c with passwords and credentials in URIs
+// replaced, safe to log or print.
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Redis.Addrs = append([]string(nil), c.Redis.Addrs...)
+	r.Auth.TrustedProxies = append([]string(nil), c.Auth.TrustedProxies...)
+
+	r.Mongo.URI = redactURL(c.Mongo.URI)
+	r.Mongo.Password = redact(c.Mongo.Password)
+	r.Redis.Password = redact(c.Redis.Password)
+	r.Redis.SentinelPassword = redact(c.Redis.SentinelPassword)
+	// The DSN's user is the project key.
+	r.Sentry.DSN = redact(c.Sentry.DSN)
+	return &r
+}
+
+// Print writes the redacted configuration to w as YAML, in the format the
+// config file accepts.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Document returns the redacted configuration in the nested file format,
+// e.g. for JSON responses.
+func (c *Config) Document() (map[string]interface{}, error) {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURL hides the password in the userinfo of raw. It does not use
+// net/url because MongoDB seed lists ("host1:27017,host2:27017") are not
+// valid URL hosts.
+func redactURL(raw string) string {
+	scheme := strings.Index(raw, "://")
+	if scheme < 0 {
+		return raw
+	}
+	rest := raw[scheme+3:]
+	if slash := strings.IndexAny(rest, "/?"); slash >= 0 {
+		rest = rest[:slash]
+	}
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return raw
+	}
+	colon := strings.Index(rest[:at], ":")
+	if colon < 0 {
+		return raw
+	}
+	start := scheme + 3 + colon + 1
+	return raw[:start] + redacted + raw[scheme+3+at:]
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Sources a reload can be triggered by.
+const (
+	SourceStartup  = "startup"
+	SourceFile     = "file"
+	SourceSettings = "settings"
+)
+
+// Overrides returns the settings document to apply on top of the config
+// file, in the nested file format. A nil map means there is none.
+type Overrides func(ctx context.Context) (map[string]interface{}, error)
+
+// Version describes the configuration currently applied.
+type Version struct {
+	// Number starts at 1 and grows with every applied change.
+	Number    uint64    `json:"number"`
+	Source    string    `json:"source"`
+	AppliedAt time.Time `json:"applied_at"`
+	// Applied lists the settings changed by the last applied reload.
+	Applied []string `json:"applied,omitempty"`
+	// Rejected lists the settings the last reload could not change because
+	// they are only read at startup.
+	Rejected []string `json:"rejected,omitempty"`
+	// LastError is why the last reload failed, if it did.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Reloader holds the current configuration and keeps it in sync with the
+// config file and the settings document. Settings tagged reload:"safe" are
+// applied live; a change to any other setting is rejected and logged, and
+// only takes effect after a restart.
+type Reloader struct {
+	overrides Overrides
+
+	mu        sync.RWMutex
+	cfg       *Config
+	version   Version
+	listeners []func(*Config)
+
+	// Inputs of the last reload, so unchanged inputs are not re-applied and
+	// rejections are logged once.
+	lastData      []byte
+	lastOverrides []byte
+	lastErr       string
+	// settings is the last settings document loaded, used while it cannot
+	// be read.
+	settings map[string]interface{}
+}
+
+// NewReloader returns a Reloader starting from cfg. overrides may be nil when
+// there is no settings document.
+func NewReloader(cfg *Config, overrides Overrides) *Reloader {
+	r := &Reloader{
+		overrides: overrides,
+		cfg:       cfg,
+		version:   Version{Number: 1, Source: SourceStartup, AppliedAt: time.Now()},
+	}
+	if cfg.File() != "" {
+		// A read error is reported by the first reload.
+		r.lastData, _ = os.ReadFile(cfg.File())
+	}
+	if cfg.settings != nil {
+		// The document read by Load is not a change.
+		r.settings = cfg.settings
+		r.lastOverrides, _ = yaml.Marshal(cfg.settings)
+	}
+	return r
+}
+
+// Current returns the configuration in effect. It must not be modified.
+func (r *Reloader) Current() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg
+}
+
+// Version returns the version of the configuration in effect.
+func (r *Reloader) Version() Version {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
+}
+
+// OnChange registers fn to be called with the new configuration after every
+// applied change.
+func (r *Reloader) OnChange(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Watch reloads every reload.interval until ctx is done.
+func (r *Reloader) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.Current().Reload.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.Reload(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reload reads the config file and the settings document and applies what
+// changed since the last call.
+func (r *Reloader) Reload(ctx context.Context) {
+	file := r.Current().File()
+
+	var data []byte
+	if file != "" {
+		var err error
+		data, err = os.ReadFile(file)
+		if err != nil {
+			r.fail(fmt.Errorf("failed to read config file: %w", err))
+			return
+		}
+	}
+
+	healthy := true
+	if r.overrides != nil {
+		ctx, cancel := context.WithTimeout(ctx, r.Current().Reload.Interval)
+		settings, err := r.overrides(ctx)
+		cancel()
+		if err != nil {
+			// The file can still be reloaded with the last known settings.
+			r.warn(fmt.Errorf("failed to load settings document: %w", err))
+			healthy = false
+		} else {
+			r.mu.Lock()
+			r.settings = settings
+			r.mu.Unlock()
+		}
+	}
+
+	r.mu.Lock()
+	overrides := r.settings
+	r.mu.Unlock()
+	var encoded []byte
+	if overrides != nil {
+		var err error
+		if encoded, err = yaml.Marshal(overrides); err != nil {
+			r.fail(fmt.Errorf("failed to encode settings document: %w", err))
+			return
+		}
+	}
+
+	r.mu.Lock()
+	var sources []string
+	if !bytes.Equal(data, r.lastData) {
+		sources = append(sources, SourceFile)
+	}
+	if !bytes.Equal(encoded, r.lastOverrides) {
+		sources = append(sources, SourceSettings)
+	}
+	r.lastData, r.lastOverrides = data, encoded
+	r.mu.Unlock()
+
+	if len(sources) > 0 {
+		next, err := build(file, data, overrides)
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		if err := r.apply(next, strings.Join(sources, "+")); err != nil {
+			r.fail(err)
+			return
+		}
+	}
+
+	if healthy {
+		r.mu.Lock()
+		r.lastErr, r.version.LastError = "", ""
+		r.mu.Unlock()
+	}
+}
+
+// fail records a failed reload, logging it unless it repeats the last one.
+// The configuration in effect is kept.
+func (r *Reloader) fail(err error) {
+	r.warn(err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Retry the same inputs once the cause is fixed.
+	r.lastData, r.lastOverrides = nil, nil
+}
+
+// warn records a reload problem, logging it unless it repeats the last one.
+func (r *Reloader) warn(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if msg := err.Error(); msg != r.lastErr {
+		slog.Warn("Config reload failed, keeping current version", "version", r.version.Number, "error", err)
+		r.lastErr = msg
+	}
+	r.version.LastError = err.Error()
+}
+
+// apply switches to the safe settings of next that differ from the current
+// configuration and rejects the others.
+func (r *Reloader) apply(next *Config, source string) error {
+	r.mu.Lock()
+
+	merged := *r.cfg
+	var applied, rejected []string
+	current, target, changed := settings(r.cfg), settings(next), settings(&merged)
+	for i := range current {
+		if reflect.DeepEqual(current[i].value.Interface(), target[i].value.Interface()) {
+			continue
+		}
+		name := current[i].name()
+		if current[i].field.Tag.Get("reload") != "safe" {
+			rejected = append(rejected, name)
+			continue
+		}
+		changed[i].value.Set(target[i].value)
+		applied = append(applied, name)
+	}
+
+	r.version.Rejected = rejected
+	if len(rejected) > 0 {
+		slog.Warn("Config reload ignored settings that cannot change without a restart", "source", source, "settings", rejected)
+	}
+	if len(applied) == 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	// Safe settings can depend on each other, e.g. the store TTLs.
+	if err := merged.Validate(); err != nil {
+		r.mu.Unlock()
+		if len(rejected) > 0 {
+			return fmt.Errorf("cannot apply %s without %s: %w", strings.Join(applied, ", "), strings.Join(rejected, ", "), err)
+		}
+		return fmt.Errorf("cannot apply %s: %w", strings.Join(applied, ", "), err)
+	}
+
+	r.cfg = &merged
+	r.version = Version{
+		Number:    r.version.Number + 1,
+		Source:    source,
+		AppliedAt: time.Now(),
+		Applied:   applied,
+		Rejected:  rejected,
+	}
+	listeners := make([]func(*Config), len(r.listeners))
+	copy(listeners, r.listeners)
+	slog.Info("Config reload applied", "source", source, "settings", applied, "version", r.version.Number)
+	r.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(&merged)
+	}
+	return nil
+}
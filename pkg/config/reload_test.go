@@ -0,0 +1,54 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloaderStartsFromLoadedSettings(t *testing.T) {
+	clearEnv(t)
+	path := filepath.Join(t.TempDir(), "env.yml")
+	if err := os.WriteFile(path, []byte("server:\n  port: \"9090\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// server.port is only read at startup.
+	settings := map[string]interface{}{"server": map[string]interface{}{"port": "7070"}}
+	var readErr error
+	read := func(context.Context) (map[string]interface{}, error) { return settings, readErr }
+
+	cfg, err := Load(path, func(ctx context.Context, _ *Config) (map[string]interface{}, error) { return read(ctx) })
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != "7070" {
+		t.Fatalf("port = %q, want the document's 7070", cfg.Server.Port)
+	}
+	r := NewReloader(cfg, read)
+
+	r.Reload(context.Background())
+	if v := r.Version(); v.Number != 1 || len(v.Rejected) > 0 || v.LastError != "" {
+		t.Errorf("reload of the startup document = %+v, want version 1 without rejections", v)
+	}
+
+	// While the document cannot be read, the one loaded at startup still
+	// applies to file changes.
+	readErr = errors.New("connection refused")
+	r = NewReloader(cfg, read)
+	if err := os.WriteFile(path, []byte("server:\n  port: \"9090\"\njobs:\n  kucoin_interval: 30s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r.Reload(context.Background())
+	if v := r.Version(); v.Number != 2 || len(v.Rejected) > 0 {
+		t.Errorf("file reload without the document = %+v, want version 2 without rejections", v)
+	}
+
+	readErr = nil
+	settings = map[string]interface{}{"server": map[string]interface{}{"port": "6060"}}
+	r.Reload(context.Background())
+	if v := r.Version(); len(v.Rejected) != 1 || v.Rejected[0] != "server.port" {
+		t.Errorf("rejected = %v, want [server.port]", v.Rejected)
+	}
+}
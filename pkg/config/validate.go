@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var v validator
+
+	v.port("server.port", c.Server.Port)
+	v.port("server.grpc_port", c.Server.GRPCPort)
+	if c.Server.GRPCPort == c.Server.Port {
+		v.fail("server.grpc_port", "must differ from server.port")
+	}
+	v.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	v.oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+	v.oneOf("log.format", c.Log.Format, "text", "json")
+	v.positive("log.sample_interval", c.Log.SampleInterval)
+
+	if !strings.HasPrefix(c.Mongo.URI, "mongodb://") && !strings.HasPrefix(c.Mongo.URI, "mongodb+srv://") {
+		v.fail("mongo.uri", "must start with mongodb:// or mongodb+srv://")
+	}
+	if c.Mongo.Password != "" && c.Mongo.Username == "" {
+		v.fail("mongo.password", "requires mongo.username")
+	}
+	v.required("mongo.market_database", c.Mongo.MarketDatabase)
+	v.required("mongo.trade_database", c.Mongo.TradeDatabase)
+	v.required("mongo.config_collection", c.Mongo.ConfigCollection)
+	v.required("mongo.last_trade_collection", c.Mongo.LastTradeCollection)
+	v.required("mongo.api_key_collection", c.Mongo.APIKeyCollection)
+	v.required("mongo.symbol_collection", c.Mongo.SymbolCollection)
+	v.required("mongo.symbol_audit_collection", c.Mongo.SymbolAuditCollection)
+	v.required("mongo.settings_collection", c.Mongo.SettingsCollection)
+	if c.Mongo.MaxPoolSize < 1 {
+		v.fail("mongo.max_pool_size", "must be at least 1")
+	}
+	if c.Mongo.MinPoolSize < 0 || c.Mongo.MinPoolSize > c.Mongo.MaxPoolSize {
+		v.fail("mongo.min_pool_size", "must be between 0 and mongo.max_pool_size")
+	}
+	v.positive("mongo.connect_timeout", c.Mongo.ConnectTimeout)
+	v.positive("mongo.server_selection_timeout", c.Mongo.ServerSelectionTimeout)
+	v.positive("mongo.reconnect_interval", c.Mongo.ReconnectInterval)
+
+	if len(c.Redis.Addrs) == 0 {
+		v.fail("redis.addrs", "at least one address is required")
+	}
+	if c.Redis.DB < 0 {
+		v.fail("redis.db", "must not be negative")
+	}
+	v.oneOf("redis.mode", c.Redis.Mode, "", "standalone", "sentinel", "cluster")
+	if c.Redis.Mode == "sentinel" && c.Redis.MasterName == "" {
+		v.fail("redis.master_name", "is required in sentinel mode")
+	}
+	if c.Redis.Mode == "cluster" && c.Redis.DB != 0 {
+		v.fail("redis.db", "must be 0 in cluster mode")
+	}
+	if c.Redis.TLSCAFile != "" && !c.Redis.TLS {
+		v.fail("redis.tls_ca_file", "requires redis.tls")
+	}
+	if c.Redis.PoolSize < 1 {
+		v.fail("redis.pool_size", "must be at least 1")
+	}
+	if c.Redis.MinIdleConns < 0 {
+		v.fail("redis.min_idle_conns", "must not be negative")
+	}
+	v.oneOf("redis.price_layout", c.Redis.PriceLayout, "legacy", "hash", "both")
+
+	v.oneOf("store.backend", c.Store.Backend, "redis", "memory")
+	v.positive("store.short_term_ttl", c.Store.ShortTermTTL)
+	v.positive("store.usdt_irr_ttl", c.Store.UsdtIrrTTL)
+	if c.Store.LongTermTTL < c.Store.ShortTermTTL {
+		v.fail("store.long_term_ttl", "must not be shorter than store.short_term_ttl")
+	}
+
+	if c.PriceCache.Size < 1 {
+		v.fail("price_cache.size", "must be at least 1")
+	}
+	v.positive("price_cache.ttl", c.PriceCache.TTL)
+
+	if c.Auth.RateLimitPerSecond <= 0 {
+		v.fail("auth.rate_limit_per_second", "must be positive")
+	}
+	if c.Auth.RateLimitBurst < 1 {
+		v.fail("auth.rate_limit_burst", "must be at least 1")
+	}
+	v.positive("auth.key_cache_ttl", c.Auth.KeyCacheTTL)
+	if c.Auth.FailureRateLimitPerSecond <= 0 {
+		v.fail("auth.failure_rate_limit_per_second", "must be positive")
+	}
+	if c.Auth.FailureRateLimitBurst < 1 {
+		v.fail("auth.failure_rate_limit_burst", "must be at least 1")
+	}
+	for _, proxy := range c.Auth.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			v.fail("auth.trusted_proxies", fmt.Sprintf("%q is not an address or CIDR range", proxy))
+		}
+	}
+
+	v.positive("jobs.kucoin_interval", c.Jobs.KucoinInterval)
+	v.positive("jobs.symbol_poll_interval", c.Jobs.SymbolPollInterval)
+	v.positive("jobs.binance_interval", c.Jobs.BinanceInterval)
+	if c.Jobs.SuppressAfter < 0 {
+		v.fail("jobs.suppress_after", "must not be negative")
+	}
+	v.positive("jobs.usdt_irr_interval", c.Jobs.UsdtIrrInterval)
+	v.positive("jobs.usdt_irr_timeout", c.Jobs.UsdtIrrTimeout)
+	v.positive("jobs.usdt_irr_window", c.Jobs.UsdtIrrWindow)
+	v.oneOf("jobs.usdt_irr_mode", c.Jobs.UsdtIrrMode, "batch", "stream")
+	if len(c.Jobs.UsdtIrrSources) == 0 {
+		v.fail("jobs.usdt_irr_sources", "at least one source is required")
+	}
+	for _, source := range c.Jobs.UsdtIrrSources {
+		v.oneOf("jobs.usdt_irr_sources", source, UsdtIrrSources...)
+	}
+	if c.Jobs.UsdtIrrMinAmount < 0 {
+		v.fail("jobs.usdt_irr_min_amount", "must not be negative")
+	}
+
+	v.positive("exchanges.timeout", c.Exchanges.Timeout)
+	v.positive("exchanges.price_timeout", c.Exchanges.PriceTimeout)
+	v.url("exchanges.binance_url", c.Exchanges.BinanceURL)
+	v.url("exchanges.kucoin_url", c.Exchanges.KucoinURL)
+	v.outbound("exchanges.binance", c.Exchanges.Binance)
+	v.outbound("exchanges.kucoin", c.Exchanges.Kucoin)
+
+	v.positive("thresholds.price_freshness", c.Thresholds.PriceFreshness)
+
+	v.positive("health.interval", c.Health.Interval)
+	v.positive("health.timeout", c.Health.Timeout)
+	for _, check := range c.Health.Critical {
+		v.oneOf("health.critical", check, HealthChecks...)
+	}
+	v.positive("health.max_price_age", c.Health.MaxPriceAge)
+	v.positive("health.max_usdt_irr_age", c.Health.MaxUsdtIrrAge)
+	if c.Health.MinUsdtIrrTrades < 0 {
+		v.fail("health.min_usdt_irr_trades", "must not be negative")
+	}
+
+	if c.Sentry.DSN != "" {
+		v.url("sentry.dsn", c.Sentry.DSN)
+	}
+	if c.Sentry.TracesSampleRate < 0 || c.Sentry.TracesSampleRate > 1 {
+		v.fail("sentry.traces_sample_rate", "must be between 0 and 1")
+	}
+
+	v.oneOf("tracing.protocol", c.Tracing.Protocol, "grpc", "http")
+	v.required("tracing.service_name", c.Tracing.ServiceName)
+	if c.Tracing.SampleRate < 0 || c.Tracing.SampleRate > 1 {
+		v.fail("tracing.sample_rate", "must be between 0 and 1")
+	}
+
+	v.positive("reload.interval", c.Reload.Interval)
+
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(name, problem string) {
+	v.errs = append(v.errs, fmt.Errorf("%s %s", name, problem))
+}
+
+func (v *validator) required(name, val string) {
+	if val == "" {
+		v.fail(name, "is required")
+	}
+}
+
+func (v *validator) positive(name string, d time.Duration) {
+	if d <= 0 {
+		v.fail(name, "must be a positive duration")
+	}
+}
+
+func (v *validator) outbound(name string, c OutboundConfig) {
+	if c.MaxConcurrency < 1 {
+		v.fail(name+".max_concurrency", "must be at least 1")
+	}
+	if c.RateLimit <= 0 {
+		v.fail(name+".rate_limit", "must be positive")
+	}
+	if c.Burst < 1 {
+		v.fail(name+".burst", "must be at least 1")
+	}
+	if c.MaxRetries < 0 {
+		v.fail(name+".max_retries", "must not be negative")
+	}
+	v.positive(name+".retry_backoff", c.RetryBackoff)
+	v.positive(name+".max_retry_wait", c.MaxRetryWait)
+	if c.BreakerThreshold < 1 {
+		v.fail(name+".breaker_threshold", "must be at least 1")
+	}
+	v.positive(name+".breaker_cooldown", c.BreakerCooldown)
+}
+
+func (v *validator) port(name, val string) {
+	port, err := strconv.Atoi(val)
+	if err != nil || port < 1 || port > 65535 {
+		v.fail(name, fmt.Sprintf("must be a port number, got %q", val))
+	}
+}
+
+func (v *validator) url(name, val string) {
+	u, err := url.Parse(val)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		v.fail(name, "must be an absolute URL")
+	}
+}
+
+func (v *validator) oneOf(name, val string, allowed ...string) {
+	for _, a := range allowed {
+		if val == a {
+			return
+		}
+	}
+	v.fail(name, fmt.Sprintf("must be one of %q, got %q", allowed, val))
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("Default().Validate() = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"invalid port", func(c *Config) { c.Server.Port = "http" }, "server.port"},
+		{"shared ports", func(c *Config) { c.Server.GRPCPort = c.Server.Port }, "server.grpc_port must differ from server.port"},
+		{"log level", func(c *Config) { c.Log.Level = "trace" }, "log.level"},
+		{"mongo scheme", func(c *Config) { c.Mongo.URI = "localhost:27017" }, "mongo.uri"},
+		{"password without user", func(c *Config) { c.Mongo.Password = "secret" }, "mongo.password requires mongo.username"},
+		{"pool sizes", func(c *Config) { c.Mongo.MinPoolSize = 20 }, "mongo.min_pool_size"},
+		{"no redis", func(c *Config) { c.Redis.Addrs = nil }, "redis"},
+		{"cache size", func(c *Config) { c.PriceCache.Size = 0 }, "price_cache.size"},
+		{"rate limit", func(c *Config) { c.Auth.RateLimitPerSecond = 0 }, "auth.rate_limit_per_second"},
+		{"trusted proxy", func(c *Config) { c.Auth.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"} }, `auth.trusted_proxies "proxy.local"`},
+		{"negative duration", func(c *Config) { c.Jobs.KucoinInterval = -time.Second }, "jobs.kucoin_interval must be a positive duration"},
+		{"usdt/irr mode", func(c *Config) { c.Jobs.UsdtIrrMode = "poll" }, "jobs.usdt_irr_mode"},
+		{"usdt/irr source", func(c *Config) { c.Jobs.UsdtIrrSources = []string{"nobitex", "binance"} }, "jobs.usdt_irr_sources"},
+		{"no usdt/irr source", func(c *Config) { c.Jobs.UsdtIrrSources = nil }, "at least one source"},
+		{"exchange url", func(c *Config) { c.Exchanges.KucoinURL = "api.kucoin.com" }, "exchanges.kucoin_url"},
+		{"outbound limits", func(c *Config) { c.Exchanges.Binance.Burst = 0 }, "exchanges.binance.burst"},
+		{"critical check", func(c *Config) { c.Health.Critical = []string{"disk"} }, "health.critical"},
+		{"sample rate", func(c *Config) { c.Sentry.TracesSampleRate = 2 }, "sentry.traces_sample_rate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := Default()
+	cfg.Log.Level = "trace"
+	cfg.PriceCache.Size = 0
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "log.level") || !strings.Contains(err.Error(), "price_cache.size") {
+		t.Errorf("Validate() = %v, want both invalid settings", err)
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"crypto_price/pkg/api"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const maxAssetSuggestions = 3
+
+// UnknownAssetError is returned for a base that is not in the asset registry.
+type UnknownAssetError struct {
+	Asset       string
+	Suggestions []string
+}
+
+func (e *UnknownAssetError) Error() string {
+	if len(e.Suggestions) == 0 {
+		return fmt.Sprintf("unknown asset '%s'", e.Asset)
+	}
+	return fmt.Sprintf("unknown asset '%s', did you mean: %s", e.Asset, strings.Join(e.Suggestions, ", "))
+}
+
+// ResolveAsset maps base, which may be an alias, to its canonical ticker and
+// checks the asset can be priced in quote. When the registry cannot be loaded
+// the upper-cased base is returned unchanged so price requests keep working.
+func (c *Controller) ResolveAsset(ctx context.Context, base, quote string) (string, error) {
+	asset, ok, err := c.assets.Lookup(ctx, base)
+	if err != nil {
+		slog.WarnContext(ctx, "Asset registry unavailable, skipping validation", "base", base, "error", err)
+		return strings.ToUpper(base), nil
+	}
+	if !ok {
+		return "", &UnknownAssetError{
+			Asset:       strings.ToUpper(base),
+			Suggestions: c.assets.Suggest(base, maxAssetSuggestions),
+		}
+	}
+	if !asset.SupportsQuote(quote) {
+		return "", &ParamError{
+			Param:   "quote",
+			Message: fmt.Sprintf("%s cannot be priced in %s (supported: %s)", asset.Ticker, strings.ToUpper(quote), strings.ToUpper(strings.Join(asset.Quotes, ", "))),
+		}
+	}
+	return asset.Ticker, nil
+}
+
+// marketSymbol returns the symbol source lists base under, falling back to
+// base itself for assets without a mapping.
+func (c *Controller) marketSymbol(ctx context.Context, base, source string) string {
+	asset, ok, err := c.assets.Lookup(ctx, base)
+	if err != nil || !ok {
+		return base
+	}
+	if symbol, ok := asset.Exchanges[strings.ToLower(source)]; ok && symbol != "" {
+		return strings.ToUpper(symbol)
+	}
+	return asset.Ticker
+}
+
+// HandleAssets lists the asset registry.
+func (c *Controller) HandleAssets(w http.ResponseWriter, r *http.Request) {
+	list, err := c.assets.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing assets", "error", err)
+		api.WriteError(w, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error(), nil)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{"assets": list})
+}
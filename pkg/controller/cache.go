@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"crypto_price/pkg/store"
+	"crypto_price/pkg/tracing"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	cacheLoadTimeout         = 5 * time.Second
+	cacheResubscribeInterval = 10 * time.Second
+)
+
+var (
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_price_cache_requests_total",
+		Help: "Price cache lookups by result (hit or miss).",
+	}, []string{"result"})
+
+	cacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_price_cache_evictions_total",
+		Help: "Price cache evictions by reason.",
+	}, []string{"reason"})
+
+	cacheHitAge = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crypto_price_cache_hit_age_seconds",
+		Help:    "Time since a cached price was loaded from the price store when it is served.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	})
+)
+
+// CacheCollectors returns the Prometheus collectors of the price cache.
+func (c *Controller) CacheCollectors() []prometheus.Collector {
+	return []prometheus.Collector{cacheRequests, cacheEvictions, cacheHitAge, c.cacheEntries}
+}
+
+// cachedPrice is a cache entry along with the generation of its key when the
+// load that produced it started.
+type cachedPrice struct {
+	info       PriceInfo
+	generation uint64
+}
+
+// prefixGenerations counts the invalidations of each announced key prefix.
+// Entries loaded before an invalidation of their prefix are outdated; they
+// are dropped when next read rather than searched for on every update.
+type prefixGenerations struct {
+	mu     sync.RWMutex
+	counts map[string]uint64
+}
+
+// bump records an invalidation of every key starting with prefix.
+func (g *prefixGenerations) bump(prefix string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.counts == nil {
+		g.counts = make(map[string]uint64)
+	}
+	g.counts[prefix]++
+}
+
+// of returns the generation of key: the number of invalidations of the
+// prefixes it starts with. There is one prefix per source, so the scan is
+// short.
+func (g *prefixGenerations) of(key string) uint64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var generation uint64
+	for prefix, count := range g.counts {
+		if strings.HasPrefix(key, prefix) {
+			generation += count
+		}
+	}
+	return generation
+}
+
+// cachedPriceInfo serves key from the in-memory cache, falling back to load on
+// a miss. Concurrent misses for the same key share a single load.
+func (c *Controller) cachedPriceInfo(ctx context.Context, key string, load func(context.Context) (PriceInfo, error)) (info PriceInfo, err error) {
+	ctx, span := tracing.Start(ctx, "price_cache.get", attribute.String("cache.key", key))
+	defer func() { tracing.End(span, err) }()
+
+	if cached, storedAt, ok := c.priceCache.Get(key); ok {
+		if cached.generation == c.generations.of(key) {
+			span.SetAttributes(attribute.Bool("cache.hit", true))
+			cacheRequests.WithLabelValues("hit").Inc()
+			cacheHitAge.Observe(time.Since(storedAt).Seconds())
+			return cached.info, nil
+		}
+		c.priceCache.Delete(key)
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	cacheRequests.WithLabelValues("miss").Inc()
+
+	result, err, _ := c.priceLoads.Do(key, func() (interface{}, error) {
+		// The load is shared, so it must not be cancelled with the request
+		// that happened to start it.
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheLoadTimeout)
+		defer cancel()
+
+		// An invalidation during the load makes the entry outdated as soon
+		// as it is stored.
+		generation := c.generations.of(key)
+		info, err := load(loadCtx)
+		if err != nil {
+			return PriceInfo{}, err
+		}
+		c.priceCache.Set(key, cachedPrice{info: info, generation: generation})
+		return info, nil
+	})
+	if err != nil {
+		return PriceInfo{}, err
+	}
+	return result.(PriceInfo), nil
+}
+
+func (c *Controller) getCachedPrice(ctx context.Context, symbol, source string) (PriceInfo, error) {
+	return c.cachedPriceInfo(ctx, store.PriceKey(source, symbol), func(ctx context.Context) (PriceInfo, error) {
+		return c.getStoredPrice(ctx, symbol, source)
+	})
+}
+
+func (c *Controller) getCachedUsdtIrr(ctx context.Context, sourceUsdt string) (PriceInfo, error) {
+	return c.cachedPriceInfo(ctx, store.UsdtIrrKey(sourceUsdt), func(ctx context.Context) (PriceInfo, error) {
+		return c.getStoredUsdtIrr(ctx, sourceUsdt)
+	})
+}
+
+// WatchCacheInvalidations drops cached prices announced on the price updates
+// channel until ctx is done. Updates missed while disconnected are bounded by
+// the cache TTL.
+func (c *Controller) WatchCacheInvalidations(ctx context.Context) {
+	for {
+		if err := c.watchCacheInvalidations(ctx); err != nil {
+			slog.WarnContext(ctx, "Price cache invalidation stopped, retrying", "retry_in", cacheResubscribeInterval, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cacheResubscribeInterval):
+		}
+	}
+}
+
+func (c *Controller) watchCacheInvalidations(ctx context.Context) error {
+	updates, err := c.store.Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Subscribed to price store updates for price cache invalidation", "store", c.store.Name())
+
+	for prefix := range updates {
+		c.generations.bump(prefix)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return errors.New("price store subscription closed")
+}
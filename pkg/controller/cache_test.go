@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/store"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestController(t *testing.T) (*Controller, *store.MemoryStore) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.PriceCache.TTL = time.Hour
+	ps := store.NewMemoryStore(store.Expirations{ShortTerm: time.Hour, LongTerm: time.Hour, UsdtIrr: time.Hour})
+	return New(cfg, ps, nil, nil, nil, nil), ps
+}
+
+func TestCachedPriceInfoSharesLoads(t *testing.T) {
+	c, _ := newTestController(t)
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (PriceInfo, error) {
+		loads.Add(1)
+		<-release
+		return PriceInfo{Price: 42}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if info, err := c.cachedPriceInfo(ctx, "price:kucoin:BTCUSDT", load); err != nil || info.Price != 42 {
+				t.Errorf("cachedPriceInfo = %+v, %v; want 42", info, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Callers that missed the shared load are served from the cache.
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loads = %d, want 1", n)
+	}
+}
+
+func TestCachedPriceInfoGenerations(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		invalidated string
+		wantReload  bool
+	}{
+		{"own source", store.SourcePrefix("kucoin"), true},
+		{"other source", store.SourcePrefix("binance"), false},
+		{"usdt rate", store.UsdtIrrKey("nobitex"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestController(t)
+			key := store.PriceKey("kucoin", "BTCUSDT")
+
+			var loads atomic.Int32
+			load := func(context.Context) (PriceInfo, error) {
+				// Invalidate while the first load is in flight.
+				if loads.Add(1) == 1 {
+					c.generations.bump(tt.invalidated)
+				}
+				return PriceInfo{Price: 42}, nil
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := c.cachedPriceInfo(ctx, key, load); err != nil {
+					t.Fatalf("cachedPriceInfo: %v", err)
+				}
+			}
+
+			want := int32(1)
+			if tt.wantReload {
+				want = 2
+			}
+			if n := loads.Load(); n != want {
+				t.Errorf("loads = %d, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	c, ps := newTestController(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.WatchCacheInvalidations(ctx)
+
+	put := func(source string, price float64) {
+		t.Helper()
+		if err := ps.PutPrices(ctx, source, map[string]float64{"BTCUSDT": price}, nil, time.Now()); err != nil {
+			t.Fatalf("PutPrices: %v", err)
+		}
+	}
+	put("kucoin", 60000)
+	put("binance", 60100)
+	for _, source := range []string{"kucoin", "binance"} {
+		if _, err := c.getCachedPrice(ctx, "BTCUSDT", source); err != nil {
+			t.Fatalf("getCachedPrice(%s): %v", source, err)
+		}
+	}
+
+	// The subscription may start after the first update, so keep writing
+	// until the cached price follows.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		put("kucoin", 61000)
+		info, err := c.getCachedPrice(ctx, "BTCUSDT", "kucoin")
+		if err != nil {
+			t.Fatalf("getCachedPrice: %v", err)
+		}
+		if info.Price == 61000 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cached price = %v after updates, want 61000", info.Price)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Updates of one source leave the prices of others cached.
+	if generation := c.generations.of(store.PriceKey("binance", "BTCUSDT")); generation != 0 {
+		t.Errorf("binance generation = %d after kucoin updates, want 0", generation)
+	}
+	if _, _, ok := c.priceCache.Get(store.PriceKey("binance", "BTCUSDT")); !ok {
+		t.Error("binance price was dropped by kucoin updates")
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"crypto_price/pkg/assets"
+	"crypto_price/pkg/cache"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/db"
+	"crypto_price/pkg/exchanges"
+	"crypto_price/pkg/models"
+	"crypto_price/pkg/store"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Ingestion reports and resets the failures of ingested symbols.
+type Ingestion interface {
+	// SymbolFailures returns the KuCoin symbols whose latest fetches
+	// failed, including suppressed ones.
+	SymbolFailures(ctx context.Context) ([]models.SymbolFailure, error)
+	// ResetSymbol resumes ingestion of a suppressed KuCoin symbol and
+	// reports whether it had failures.
+	ResetSymbol(ctx context.Context, symbol string) (bool, error)
+}
+
+// Controller serves the price, asset and admin endpoints.
+type Controller struct {
+	store     store.PriceStore
+	assets    *assets.Registry
+	mongo     *db.Mongo
+	exchanges *exchanges.Client
+	ingestion Ingestion
+
+	// freshness is the age after which responses carry an outdated note,
+	// as a time.Duration.
+	freshness atomic.Int64
+
+	priceCache   *cache.LRU[cachedPrice]
+	priceLoads   singleflight.Group
+	cacheEntries prometheus.Collector
+	// generations counts the invalidations of each announced key prefix.
+	generations prefixGenerations
+}
+
+// New returns a Controller reading prices from ps, with an in-memory price
+// cache sized by cfg.
+func New(cfg *config.Config, ps store.PriceStore, registry *assets.Registry, mongo *db.Mongo, exchangeClient *exchanges.Client, ingestion Ingestion) *Controller {
+	c := &Controller{
+		store:      ps,
+		assets:     registry,
+		mongo:      mongo,
+		exchanges:  exchangeClient,
+		ingestion:  ingestion,
+		priceCache: cache.NewLRU[cachedPrice](cfg.PriceCache.Size, cfg.PriceCache.TTL),
+	}
+	c.SetFreshness(cfg.Thresholds.PriceFreshness)
+	c.priceCache.OnEvict = func(reason string) {
+		cacheEvictions.WithLabelValues(reason).Inc()
+	}
+	c.cacheEntries = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "crypto_price_cache_entries",
+		Help: "Number of entries in the price cache.",
+	}, func() float64 {
+		return float64(c.priceCache.Len())
+	})
+	return c
+}
+
+// Freshness returns the age after which a price is reported as outdated.
+func (c *Controller) Freshness() time.Duration {
+	return time.Duration(c.freshness.Load())
+}
+
+// SetFreshness replaces the age after which a price is reported as outdated.
+func (c *Controller) SetFreshness(d time.Duration) {
+	c.freshness.Store(int64(d))
+}
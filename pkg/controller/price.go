@@ -2,100 +2,194 @@ package controller
 
 import (
 	"context"
-	"crypto_price/pkg/db"
+	"crypto_price/pkg/api"
 	"crypto_price/pkg/models"
+	"crypto_price/pkg/reporting"
+	"crypto_price/pkg/store"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 )
 
 const (
     DEFAULT_SOURCE = "binance"
     DEFAULT_QUOTE   = "usdt"
     DEFAULT_USDT_SOURCE = "nobitex"
+)
 
-    REDIS_LONG_TERM_EXPIRATION  = 10 * time.Minute
-    REDIS_SHORT_TERM_EXPIRATION = 20 * time.Second
+// ErrPriceNotAvailable is returned when a price or conversion rate is missing
+// from the price store, typically because ingestion has not produced it yet.
+var ErrPriceNotAvailable = store.ErrNotFound
 
-    PRICE_FRESHNESS_THRESHOLD = 20 * time.Second
-)
+// ParamError reports an invalid or missing request parameter.
+type ParamError struct {
+	Param   string
+	Message string
+}
+
+func (e *ParamError) Error() string {
+	return e.Message
+}
 
 type PriceInfo struct {
 	Price     float64
 	Timestamp time.Time
+	// Stats are the 24h statistics of the market, in the quote of Price.
+	Stats *models.MarketStats
 }
 
-type PriceResponse struct {
-	Symbol     string  `json:"symbol"`
-	Source     string  `json:"source"`
-	Price      float64 `json:"price"`
-	Elapsed    float64 `json:"elapsed"`
-	SourceUsdt string  `json:"source_usdt"`
-	Quote      string  `json:"quote"`
-	Note       string  `json:"note,omitempty"`
+// includeStats reports whether the include query parameter, a
+// comma-separated list, asks for the 24h statistics.
+func includeStats(r *http.Request) (bool, error) {
+	include := false
+	for _, item := range strings.Split(r.URL.Query().Get("include"), ",") {
+		switch strings.TrimSpace(item) {
+		case "":
+		case "stats":
+			include = true
+		default:
+			return false, &ParamError{Param: "include", Message: fmt.Sprintf("invalid 'include' value %q (supported: stats)", item)}
+		}
+	}
+	return include, nil
 }
 
 // HandlePriceRequest handles the incoming price request and returns the price information.
-func HandlePriceRequest(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) HandlePriceRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Parse and validate query parameters
-	base, source, quote, sourceUsdt, err := parseAndValidateParams(r)
+	base, source, quote, sourceUsdt, err := c.parseAndValidateParams(r)
+	if err != nil {
+		var unknownErr *UnknownAssetError
+		if errors.As(err, &unknownErr) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	withStats, err := includeStats(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Fetch the price information
-	priceInfo, err := fetchPrice(r.Context(), base, source, quote, sourceUsdt)
+	price, err := c.FetchPrice(r.Context(), base, source, quote, sourceUsdt)
 	if err != nil {
-		log.Printf("Error fetching price: %v", err)
+		slog.WarnContext(r.Context(), "Error fetching price", "base", base, "source", source, "quote", quote, "error", err)
 		http.Error(w, fmt.Sprintf("Error retrieving price: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	if !withStats {
+		price.Stats = nil
+	}
+
 	// Build the response
-	response := buildPriceResponse(base, source, quote, sourceUsdt, priceInfo)
+	response := models.NewPriceResponse(price, time.Now(), c.Freshness())
 
 	// Encode and send the response
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response: %v", err)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
-// parseAndValidateParams parses and validates the query parameters.
-func parseAndValidateParams(r *http.Request) (base, source, quote, sourceUsdt string, err error) {
+// HandlePriceRequestV1 serves /v1/price. It returns the same payload as
+// HandlePriceRequest but reports failures as JSON error envelopes.
+func (c *Controller) HandlePriceRequestV1(w http.ResponseWriter, r *http.Request) {
+	base, source, quote, sourceUsdt, err := c.parseAndValidateParams(r)
+	if err != nil {
+		var unknownErr *UnknownAssetError
+		if errors.As(err, &unknownErr) {
+			api.WriteError(w, http.StatusNotFound, api.CodeNotFound, err.Error(), map[string]interface{}{
+				"parameter":   "base",
+				"suggestions": unknownErr.Suggestions,
+			})
+			return
+		}
+		var paramErr *ParamError
+		details := map[string]interface{}{}
+		if errors.As(err, &paramErr) {
+			details["parameter"] = paramErr.Param
+		}
+		api.WriteError(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error(), details)
+		return
+	}
+	withStats, err := includeStats(r)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error(),
+			map[string]interface{}{"parameter": "include"})
+		return
+	}
+
+	price, err := c.FetchPrice(r.Context(), base, source, quote, sourceUsdt)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error fetching price", "base", base, "source", source, "quote", quote, "error", err)
+		details := map[string]interface{}{
+			"base":   base,
+			"source": source,
+			"quote":  quote,
+		}
+		if errors.Is(err, ErrPriceNotAvailable) {
+			api.WriteError(w, http.StatusNotFound, api.CodeNotFound, err.Error(), details)
+			return
+		}
+		api.WriteError(w, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error(), details)
+		return
+	}
+
+	if !withStats {
+		price.Stats = nil
+	}
+	api.WriteJSON(w, http.StatusOK, models.NewPriceResponse(price, time.Now(), c.Freshness()))
+}
+
+// parseAndValidateParams parses and validates the query parameters and
+// resolves base to its canonical ticker.
+func (c *Controller) parseAndValidateParams(r *http.Request) (base, source, quote, sourceUsdt string, err error) {
 	query := r.URL.Query()
-	base = query.Get("base")
-	source = query.Get("source")
-	quote = query.Get("quote")
-	sourceUsdt = query.Get("source_usdt")
+	base, source, quote, sourceUsdt, err = ValidateParams(query.Get("base"), query.Get("source"), query.Get("quote"), query.Get("source_usdt"))
+	if err != nil {
+		return "", "", "", "", err
+	}
 
+	base, err = c.ResolveAsset(r.Context(), base, quote)
+	if err != nil {
+		return "", "", "", "", err
+	}
+
+	return base, source, quote, sourceUsdt, nil
+}
+
+// ValidateParams validates price request parameters and fills in defaults for
+// the optional ones. It is shared by the HTTP and gRPC handlers.
+func ValidateParams(base, source, quote, sourceUsdt string) (string, string, string, string, error) {
 	if base == "" {
-		return "", "", "", "", fmt.Errorf("please specify 'base' to get price")
+		return "", "", "", "", &ParamError{Param: "base", Message: "please specify 'base' to get price"}
 	}
 	if !isValidSymbol(base) {
-		return "", "", "", "", fmt.Errorf("invalid 'base' parameter")
+		return "", "", "", "", &ParamError{Param: "base", Message: "invalid 'base' parameter"}
 	}
 
 	if quote == "" {
 		quote = DEFAULT_QUOTE
 	} else if !isValidQuote(quote) {
-		return "", "", "", "", fmt.Errorf("invalid 'quote' parameter")
+		return "", "", "", "", &ParamError{Param: "quote", Message: "invalid 'quote' parameter"}
 	}
 
 	if source == "" {
 		source = DEFAULT_SOURCE
 	} else if !isValidSource(source) {
-		return "", "", "", "", fmt.Errorf("invalid 'source' parameter")
+		return "", "", "", "", &ParamError{Param: "source", Message: "invalid 'source' parameter"}
 	}
 
 	if sourceUsdt == "" {
@@ -105,8 +199,36 @@ func parseAndValidateParams(r *http.Request) (base, source, quote, sourceUsdt st
 	return base, source, quote, sourceUsdt, nil
 }
 
+// FetchPrice retrieves the price of base in quote and returns it as the
+// canonical domain model, with the 24h statistics of the market when they
+// are stored. Parameters are expected to be validated already.
+func (c *Controller) FetchPrice(ctx context.Context, base, source, quote, sourceUsdt string) (models.Price, error) {
+	priceInfo, err := c.fetchPrice(ctx, base, source, quote, sourceUsdt)
+	if err != nil {
+		if !errors.Is(err, ErrPriceNotAvailable) {
+			reporting.CaptureError(ctx, err, map[string]string{
+				reporting.TagSource: source,
+				reporting.TagSymbol: base + "USDT",
+				"quote":             quote,
+			})
+		}
+		return models.Price{}, err
+	}
+
+	return models.Price{
+		Symbol:     base + "USDT",
+		Base:       base,
+		Quote:      quote,
+		Source:     source,
+		SourceUsdt: sourceUsdt,
+		Value:      priceInfo.Price,
+		Timestamp:  priceInfo.Timestamp,
+		Stats:      priceInfo.Stats,
+	}, nil
+}
+
 // fetchPrice retrieves the price information based on the provided parameters.
-func fetchPrice(ctx context.Context, base, source, quote, sourceUsdt string) (PriceInfo, error) {
+func (c *Controller) fetchPrice(ctx context.Context, base, source, quote, sourceUsdt string) (PriceInfo, error) {
 	// Validate inputs
 	if base == "" {
 		return PriceInfo{}, fmt.Errorf("base currency cannot be empty")
@@ -116,34 +238,31 @@ func fetchPrice(ctx context.Context, base, source, quote, sourceUsdt string) (Pr
 	}
 
 	var priceInfo PriceInfo
-	symbol := base + "USDT"
+	symbol := c.marketSymbol(ctx, base, source) + "USDT"
 
 	switch strings.ToUpper(quote) {
 	case "USDT":
-		log.Printf("Fetching price for %s from %s", symbol, source)
-		price, err := getPriceFromRedis(ctx, symbol, source)
+		slog.DebugContext(ctx, "Fetching price", "symbol", symbol, "source", source)
+		price, err := c.getCachedPrice(ctx, symbol, source)
 		if err != nil {
 			return PriceInfo{}, fmt.Errorf("failed to retrieve USDT price for %s from %s: %w", symbol, source, err)
 		}
 		priceInfo = price
 
 	case "IRR", "IRT":
-		usdtPrice, err := getUsdtIrrFromRedis(ctx, sourceUsdt)
+		usdtInfo, err := c.getCachedUsdtIrr(ctx, sourceUsdt)
 		if err != nil {
 			return PriceInfo{}, fmt.Errorf("failed to retrieve USDT/%s conversion rate from %s: %w", strings.ToUpper(quote), sourceUsdt, err)
 		}
 
-		if usdtPrice <= 0 {
-			return PriceInfo{}, fmt.Errorf("invalid USDT/%s conversion rate: %f", strings.ToUpper(quote), usdtPrice)
+		if usdtInfo.Price <= 0 {
+			return PriceInfo{}, fmt.Errorf("invalid USDT/%s conversion rate: %f", strings.ToUpper(quote), usdtInfo.Price)
 		}
 
 		if base == "USDT" || base == "USDC" {
-			priceInfo = PriceInfo{
-				Price:     usdtPrice,
-				Timestamp: time.Now(),
-			}
+			priceInfo = usdtInfo
 		} else {
-			basePrice, err := getPriceFromRedis(ctx, symbol, source)
+			basePrice, err := c.getCachedPrice(ctx, symbol, source)
 			if err != nil {
 				return PriceInfo{}, fmt.Errorf("failed to retrieve base price for %s from %s: %w", symbol, source, err)
 			}
@@ -152,9 +271,15 @@ func fetchPrice(ctx context.Context, base, source, quote, sourceUsdt string) (Pr
 				return PriceInfo{}, fmt.Errorf("invalid base price for %s: %f", symbol, basePrice.Price)
 			}
 
+			// A composed price is only as fresh as its oldest leg.
 			priceInfo = PriceInfo{
-				Price:     basePrice.Price * usdtPrice,
-				Timestamp: basePrice.Timestamp,
+				Price:     basePrice.Price * usdtInfo.Price,
+				Timestamp: oldestTimestamp(basePrice.Timestamp, usdtInfo.Timestamp),
+			}
+			// Cached stats are shared, so the converted ones are a copy.
+			if basePrice.Stats != nil {
+				stats := basePrice.Stats.Convert(usdtInfo.Price)
+				priceInfo.Stats = &stats
 			}
 		}
 
@@ -169,114 +294,48 @@ func fetchPrice(ctx context.Context, base, source, quote, sourceUsdt string) (Pr
 	return priceInfo, nil
 }
 
-func buildPriceResponse(base, source, quote, sourceUsdt string, priceInfo PriceInfo) PriceResponse {
-	elapsed := time.Since(priceInfo.Timestamp).Seconds()
-	symbol := base + "USDT"
-
-	response := PriceResponse{
-		Symbol:     symbol,
-		Source:     source,
-		Price:      priceInfo.Price,
-		Elapsed:    elapsed,
-		SourceUsdt: sourceUsdt,
-		Quote:      quote,
-	}
-
-	if elapsed > PRICE_FRESHNESS_THRESHOLD.Seconds() { // Price freshness threshold
-		response.Note = "Price may be outdated."
-	}
-
-	return response
-}
-
-// getPriceFromRedis retrieves the price of a symbol from Redis.
-func getPriceFromRedis(ctx context.Context, symbol, source string) (PriceInfo, error) {
-	var priceInfo PriceInfo
-
-	rdb, err := db.GetRedisClient()
-	if err != nil {
-		return priceInfo, fmt.Errorf("failed to get Redis client: %w", err)
-	}
-
-	// Short-term keys
-	shortTermKey := fmt.Sprintf("%s:%s:short", source, symbol)
-	shortTermTimeKey := fmt.Sprintf("%s:%s:short:time", source, symbol)
-
-	// Attempt to get the price from the short-term key
-	price, err := rdb.Get(ctx, shortTermKey).Result()
-	if err == redis.Nil {
-		// Fall back to the long-term price
-		longTermKey := fmt.Sprintf("%s:%s:long", source, symbol)
-		longTermTimeKey := fmt.Sprintf("%s:%s:long:time", source, symbol)
-
-		price, err = rdb.Get(ctx, longTermKey).Result()
-		if err != nil {
-			if err == redis.Nil {
-				return priceInfo, fmt.Errorf("price not available for %s from %s", symbol, source)
-			}
-			return priceInfo, fmt.Errorf("error retrieving long-term price from Redis: %v", err)
-		}
-
-		// Get the timestamp from the long-term time key
-		timestamp, err := rdb.Get(ctx, longTermTimeKey).Int64()
-		if err != nil {
-			return priceInfo, fmt.Errorf("timestamp not available for %s from %s", symbol, source)
-		}
-		priceInfo.Timestamp = time.Unix(timestamp, 0)
-	} else if err != nil {
-		// Error retrieving short-term price
-		return priceInfo, fmt.Errorf("error retrieving short-term price from Redis: %v", err)
-	} else {
-		// Successfully retrieved short-term price, get the timestamp
-		timestamp, err := rdb.Get(ctx, shortTermTimeKey).Int64()
-		if err != nil {
-			// If timestamp is not available, use the current time
-			priceInfo.Timestamp = time.Now()
-		} else {
-			priceInfo.Timestamp = time.Unix(timestamp, 0)
-		}
-	}
-
-	// Parse the price
-	priceInfo.Price, err = strconv.ParseFloat(price, 64)
+// getStoredPrice retrieves the latest price of a symbol from the price store.
+func (c *Controller) getStoredPrice(ctx context.Context, symbol, source string) (PriceInfo, error) {
+	point, err := c.store.GetPrice(ctx, source, symbol)
 	if err != nil {
-		return priceInfo, fmt.Errorf("error parsing price for %s from %s: %v", symbol, source, err)
+		return PriceInfo{}, err
 	}
 
-	return priceInfo, nil
+	return PriceInfo{
+		Price:     point.Price,
+		Timestamp: point.Timestamp,
+		Stats:     point.Stats,
+	}, nil
 }
 
-// getUsdtIrrFromRedis retrieves the USDT to IRR conversion rate from Redis.
-func getUsdtIrrFromRedis(ctx context.Context, sourceUsdt string) (float64, error) {
+// getStoredUsdtIrr retrieves the USDT to IRR conversion rate from the price store.
+// The returned timestamp is the time the rate was computed by the ingestion job.
+func (c *Controller) getStoredUsdtIrr(ctx context.Context, sourceUsdt string) (PriceInfo, error) {
 	if sourceUsdt == "" {
-		return -1, fmt.Errorf("sourceUsdt cannot be empty")
+		return PriceInfo{}, fmt.Errorf("sourceUsdt cannot be empty")
 	}
 
-	rdb, err := db.GetRedisClient()
+	priceStruct, err := c.store.GetUsdtIrr(ctx, sourceUsdt)
 	if err != nil {
-		return -1, fmt.Errorf("failed to get Redis client: %w", err)
+		return PriceInfo{}, fmt.Errorf("USDT/%s conversion rate not available: %w", strings.ToUpper(sourceUsdt), err)
 	}
 
-	usdtIrrKey := fmt.Sprintf("usdtirr:%s", sourceUsdt)
-
-	value, err := rdb.Get(ctx, usdtIrrKey).Result()
-	if err != nil {
-		if err == redis.Nil {
-			return -1, fmt.Errorf("USDT/%s conversion rate not available in cache (key: %s)", strings.ToUpper(sourceUsdt), usdtIrrKey)
-		}
-		return -1, fmt.Errorf("failed to retrieve USDT/%s rate from Redis: %w", strings.ToUpper(sourceUsdt), err)
+	if priceStruct.WeightedMean <= 0 {
+		return PriceInfo{}, fmt.Errorf("invalid USDT/%s conversion rate: %f (must be positive)", strings.ToUpper(sourceUsdt), priceStruct.WeightedMean)
 	}
 
-	var priceStruct models.MarketSourceResult
-	if err := json.Unmarshal([]byte(value), &priceStruct); err != nil {
-		return -1, fmt.Errorf("failed to parse USDT/%s rate data from Redis: %w", strings.ToUpper(sourceUsdt), err)
-	}
+	return PriceInfo{
+		Price:     priceStruct.WeightedMean,
+		Timestamp: priceStruct.ComputedAt,
+	}, nil
+}
 
-	if priceStruct.WeightedMean <= 0 {
-		return -1, fmt.Errorf("invalid USDT/%s conversion rate: %f (must be positive)", strings.ToUpper(sourceUsdt), priceStruct.WeightedMean)
+// oldestTimestamp returns the earlier of two timestamps.
+func oldestTimestamp(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
 	}
-
-	return priceStruct.WeightedMean, nil
+	return a
 }
 
 func isValidSource(source string) bool {
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"context"
+	"crypto_price/pkg/api"
+	"crypto_price/pkg/auth"
+	"crypto_price/pkg/db"
+	"crypto_price/pkg/exchanges"
+	"crypto_price/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	adminSymbolsPath   = "/v1/admin/symbols"
+	symbolFailuresPath = "/v1/admin/symbol-failures"
+	symbolAuditLimit   = 100
+	adminQueryTimeout  = 15 * time.Second
+)
+
+type addSymbolRequest struct {
+	Asset     string            `json:"asset"`
+	Exchanges map[string]string `json:"exchanges"`
+	Name      string            `json:"name"`
+	Decimals  int               `json:"decimals"`
+	Aliases   []string          `json:"aliases"`
+	Quotes    []string          `json:"quotes"`
+}
+
+// HandleAdminSymbols serves /v1/admin/symbols: GET lists tracked symbols and
+// POST adds one.
+func (c *Controller) HandleAdminSymbols(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), adminQueryTimeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		symbols, err := c.mongo.ListTrackedSymbols(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error listing tracked symbols", "error", err)
+			api.WriteError(w, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error(), nil)
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, map[string]interface{}{"symbols": symbols})
+
+	case http.MethodPost:
+		c.addSymbol(ctx, w, r)
+
+	default:
+		api.MethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// HandleAdminSymbol serves /v1/admin/symbols/{asset}[/enable|/disable|/audit].
+func (c *Controller) HandleAdminSymbol(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), adminQueryTimeout)
+	defer cancel()
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, adminSymbolsPath), "/"), "/")
+	asset := strings.ToUpper(parts[0])
+	if !isValidSymbol(asset) || len(parts) > 2 {
+		api.NotFound(w, r)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			api.MethodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		symbol, err := c.mongo.GetTrackedSymbol(ctx, asset)
+		if err != nil {
+			writeSymbolError(ctx, w, err)
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, symbol)
+
+	case "enable", "disable":
+		if r.Method != http.MethodPost {
+			api.MethodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		symbol, err := c.mongo.SetTrackedSymbolEnabled(ctx, asset, action == "enable", actorFromRequest(r))
+		if err != nil {
+			writeSymbolError(ctx, w, err)
+			return
+		}
+		// Enabling also lifts a suppression after repeated failures.
+		if kucoin, ok := symbol.Exchanges[exchanges.KuCoin]; ok && action == "enable" {
+			if _, err := c.ingestion.ResetSymbol(ctx, kucoin); err != nil {
+				slog.WarnContext(ctx, "Error resetting symbol failures", "symbol", kucoin, "error", err)
+			}
+		}
+		c.assets.Invalidate()
+		api.WriteJSON(w, http.StatusOK, symbol)
+
+	case "audit":
+		if r.Method != http.MethodGet {
+			api.MethodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		entries, err := c.mongo.ListSymbolAudit(ctx, asset, symbolAuditLimit)
+		if err != nil {
+			writeSymbolError(ctx, w, err)
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, map[string]interface{}{"entries": entries})
+
+	default:
+		api.NotFound(w, r)
+	}
+}
+
+// HandleSymbolFailures serves /v1/admin/symbol-failures: the KuCoin symbols
+// whose latest fetches failed, including the suppressed ones.
+func (c *Controller) HandleSymbolFailures(w http.ResponseWriter, r *http.Request) {
+	failures, err := c.ingestion.SymbolFailures(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading symbol failures", "error", err)
+		api.WriteError(w, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error(), nil)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{"symbols": failures})
+}
+
+// HandleSymbolFailure serves POST /v1/admin/symbol-failures/{symbol}/reset,
+// which forgets the failures of a KuCoin symbol and resumes its ingestion.
+func (c *Controller) HandleSymbolFailure(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, symbolFailuresPath), "/"), "/")
+	symbol := strings.ToUpper(parts[0])
+	if !isValidSymbol(symbol) || len(parts) != 2 || parts[1] != "reset" {
+		api.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		api.MethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	reset, err := c.ingestion.ResetSymbol(r.Context(), symbol)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting symbol failures", "symbol", symbol, "error", err)
+		api.WriteError(w, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error(), nil)
+		return
+	}
+	if !reset {
+		api.WriteError(w, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("no failures recorded for %s", symbol), nil)
+		return
+	}
+	slog.InfoContext(r.Context(), "Symbol failures reset", "symbol", symbol, "actor", actorFromRequest(r))
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{"symbol": symbol, "reset": true})
+}
+
+func (c *Controller) addSymbol(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req addSymbolRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, api.CodeInvalidArgument,
+			fmt.Sprintf("invalid request body: %v", err), nil)
+		return
+	}
+
+	symbol, err := c.validateSymbolRequest(ctx, req)
+	if err != nil {
+		var paramErr *ParamError
+		if errors.As(err, &paramErr) {
+			api.WriteError(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error(),
+				map[string]interface{}{"parameter": paramErr.Param})
+			return
+		}
+		slog.ErrorContext(ctx, "Error validating symbol", "asset", req.Asset, "error", err)
+		api.WriteError(w, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error(), nil)
+		return
+	}
+
+	symbol, err = c.mongo.AddTrackedSymbol(ctx, symbol, actorFromRequest(r))
+	if err != nil {
+		writeSymbolError(ctx, w, err)
+		return
+	}
+	c.assets.Invalidate()
+	api.WriteJSON(w, http.StatusCreated, symbol)
+}
+
+// validateSymbolRequest normalises the request and checks every exchange
+// mapping against the exchange's current symbol list.
+func (c *Controller) validateSymbolRequest(ctx context.Context, req addSymbolRequest) (models.TrackedSymbol, error) {
+	asset := strings.ToUpper(req.Asset)
+	if asset == "" || !isValidSymbol(asset) {
+		return models.TrackedSymbol{}, &ParamError{Param: "asset", Message: "invalid 'asset' parameter"}
+	}
+	if len(req.Exchanges) == 0 {
+		return models.TrackedSymbol{}, &ParamError{Param: "exchanges", Message: "at least one exchange mapping is required"}
+	}
+
+	if req.Decimals < 0 || req.Decimals > 18 {
+		return models.TrackedSymbol{}, &ParamError{Param: "decimals", Message: "decimals must be between 0 and 18"}
+	}
+
+	aliases := make([]string, 0, len(req.Aliases))
+	for _, alias := range req.Aliases {
+		alias = strings.ToUpper(alias)
+		if !isValidSymbol(alias) {
+			return models.TrackedSymbol{}, &ParamError{Param: "aliases", Message: fmt.Sprintf("invalid alias %q", alias)}
+		}
+		aliases = append(aliases, alias)
+	}
+
+	quotes := make([]string, 0, len(req.Quotes))
+	for _, quote := range req.Quotes {
+		quote = strings.ToLower(quote)
+		if !isValidQuote(quote) {
+			return models.TrackedSymbol{}, &ParamError{Param: "quotes", Message: fmt.Sprintf("unsupported quote %q", quote)}
+		}
+		quotes = append(quotes, quote)
+	}
+
+	mappings := make(map[string]string, len(req.Exchanges))
+	for exchange, symbol := range req.Exchanges {
+		exchange = strings.ToLower(exchange)
+		symbol = strings.ToUpper(symbol)
+		param := "exchanges." + exchange
+
+		if !isValidSource(exchange) {
+			return models.TrackedSymbol{}, &ParamError{Param: param, Message: fmt.Sprintf("unsupported exchange %q", exchange)}
+		}
+		if !isValidSymbol(symbol) {
+			return models.TrackedSymbol{}, &ParamError{Param: param, Message: fmt.Sprintf("invalid symbol %q for %s", symbol, exchange)}
+		}
+
+		listed, err := c.exchanges.HasUSDTMarket(ctx, exchange, symbol)
+		if err != nil {
+			return models.TrackedSymbol{}, fmt.Errorf("failed to validate %s on %s: %w", symbol, exchange, err)
+		}
+		if !listed {
+			market, _ := exchanges.USDTMarket(exchange, symbol)
+			return models.TrackedSymbol{}, &ParamError{Param: param, Message: fmt.Sprintf("%s does not list %s", exchange, market)}
+		}
+
+		mappings[exchange] = symbol
+	}
+
+	return models.TrackedSymbol{
+		Asset:     asset,
+		Exchanges: mappings,
+		Name:      req.Name,
+		Decimals:  req.Decimals,
+		Aliases:   aliases,
+		Quotes:    quotes,
+	}, nil
+}
+
+func writeSymbolError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrSymbolNotFound):
+		api.WriteError(w, http.StatusNotFound, api.CodeNotFound, err.Error(), nil)
+	case errors.Is(err, db.ErrSymbolExists):
+		api.WriteError(w, http.StatusConflict, api.CodeAlreadyExists, err.Error(), nil)
+	default:
+		slog.ErrorContext(ctx, "Error updating tracked symbols", "error", err)
+		api.WriteError(w, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error(), nil)
+	}
+}
+
+func actorFromRequest(r *http.Request) string {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return key.Name
+	}
+	return "unknown"
+}
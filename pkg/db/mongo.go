@@ -3,80 +3,198 @@ package db
 import (
 	"context"
 	"crypto_price/pkg/config"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/models"
+	"crypto_price/pkg/tracing"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var (
-	mongoClient     *mongo.Client
-	mongoClientOnce sync.Once
-	mongoClientErr  error
-)
-
-// GetMongoClient returns a singleton MongoDB client with connection pooling
-func GetMongoClient() (*mongo.Client, error) {
-	mongoClientOnce.Do(func() {
-		cfg := config.GetConfigs()
-
-		// Configure connection pool
-		clientOptions := options.Client().ApplyURI(cfg.MongoHost).
-			SetMaxPoolSize(10).     
-			SetMinPoolSize(2).
-			SetMaxConnIdleTime(30 * time.Minute).
-			SetServerSelectionTimeout(5 * time.Second).
-			SetConnectTimeout(10 * time.Second)
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		client, err := mongo.Connect(ctx, clientOptions)
-		if err != nil {
-			mongoClientErr = fmt.Errorf("failed to connect to MongoDB: %w", err)
-			return
-		}
+// Mongo owns the MongoDB client. When the initial connection fails, for
+// example because the seed list cannot be resolved yet, the next call to
+// Client tries again instead of failing for the lifetime of the process.
+type Mongo struct {
+	cfg *config.Config
 
-		// Ping to verify connection
-		err = client.Ping(ctx, nil)
-		if err != nil {
-			mongoClientErr = fmt.Errorf("failed to ping MongoDB: %w", err)
-			return
-		}
+	mu          sync.Mutex
+	client      *mongo.Client
+	lastErr     error
+	lastAttempt time.Time
+}
 
-		mongoClient = client
-		log.Println("MongoDB connection pool initialized successfully")
-	})
+// NewMongo makes the first connection attempt. A failure is logged and
+// retried by later calls to Client.
+func NewMongo(cfg *config.Config) *Mongo {
+	m := &Mongo{cfg: cfg}
 
-	return mongoClient, mongoClientErr
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Mongo.ConnectTimeout)
+	defer cancel()
+	if _, err := m.Client(ctx); err != nil {
+		slog.Warn("MongoDB unavailable, will retry", "error", err)
+	}
+	return m
 }
 
+// Client returns the connected client, reconnecting if the previous attempt
+// failed more than mongo.reconnect_interval ago.
+func (m *Mongo) Client(ctx context.Context) (*mongo.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.client != nil {
+		return m.client, nil
+	}
+	mc := m.cfg.Mongo
+	if m.lastErr != nil && time.Since(m.lastAttempt) < mc.ReconnectInterval {
+		return nil, m.lastErr
+	}
+	m.lastAttempt = time.Now()
 
-func CreateMongoClient(ctx context.Context, uri string) (*mongo.Client, error) {
-	client, err := GetMongoClient()
+	// Configure connection pool
+	clientOptions := options.Client().ApplyURI(mc.URI).
+		SetMaxPoolSize(uint64(mc.MaxPoolSize)).
+		SetMinPoolSize(uint64(mc.MinPoolSize)).
+		SetMaxConnIdleTime(30 * time.Minute).
+		SetServerSelectionTimeout(mc.ServerSelectionTimeout).
+		SetConnectTimeout(mc.ConnectTimeout).
+		SetMonitor(commandMonitors(metrics.MongoMonitor(), tracing.MongoMonitor()))
+	if mc.Username != "" {
+		clientOptions.SetAuth(options.Credential{
+			Username: mc.Username,
+			Password: mc.Password,
+		})
+	}
+
+	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		return nil, err
+		m.lastErr = fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return nil, m.lastErr
 	}
+
+	m.client, m.lastErr = client, nil
+	slog.Info("MongoDB connection pool initialized successfully")
 	return client, nil
 }
 
+// Ping checks that the primary can be reached.
+func (m *Mongo) Ping(ctx context.Context) error {
+	client, err := m.Client(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Ping(ctx, nil)
+}
+
+// Disconnect closes the client, if one was connected.
+func (m *Mongo) Disconnect(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
+	if m.client == nil {
+		return nil
+	}
+	err := m.client.Disconnect(ctx)
+	m.client = nil
+	return err
+}
 
-func GetKucoinSymbolsFromDB() ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// LastTrades returns the collection of recent trades the USDT/IRR rate is
+// computed from.
+func (m *Mongo) LastTrades(ctx context.Context) (*mongo.Collection, error) {
+	client, err := m.Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MongoDB client: %w", err)
+	}
+	return client.Database(m.cfg.Mongo.TradeDatabase).Collection(m.cfg.Mongo.LastTradeCollection), nil
+}
 
-	cfg := config.GetConfigs()
-	client, err := GetMongoClient()
+// EnsureLastTradeIndex creates the index the USDT/IRR batch query needs to
+// read the most recent trades of a market and source without sorting in
+// memory. It is a no-op when the index already exists.
+func (m *Mongo) EnsureLastTradeIndex(ctx context.Context) error {
+	collection, err := m.LastTrades(ctx)
+	if err != nil {
+		return err
+	}
+
+	index := mongo.IndexModel{Keys: bson.D{
+		{Key: "market_name", Value: 1},
+		{Key: "source", Value: 1},
+		{Key: "time", Value: -1},
+	}}
+	if _, err := collection.Indexes().CreateOne(ctx, index); err != nil {
+		return fmt.Errorf("failed to create last trade index: %w", err)
+	}
+	return nil
+}
+
+// WatchLastTrades opens a change stream of the trades inserted into the last
+// trade collection, resuming after resumeToken unless it is nil. Each call
+// to TryNext waits at most a second for new trades.
+func (m *Mongo) WatchLastTrades(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	collection, err := m.LastTrades(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"operationType": "insert"}}}}
+	opts := options.ChangeStream().SetMaxAwaitTime(time.Second)
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	}
+	stream, err := collection.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open change stream: %w", err)
+	}
+	return stream, nil
+}
+
+// Collection returns a collection of the market database.
+func (m *Mongo) Collection(ctx context.Context, name string) (*mongo.Collection, error) {
+	client, err := m.Client(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get MongoDB client: %w", err)
 	}
+	return client.Database(m.cfg.Mongo.MarketDatabase).Collection(name), nil
+}
+
+// ExchangeSymbols returns the symbols tracked on each exchange, keyed by
+// exchange: the KuCoin symbols to ingest and the Binance symbols whose
+// prices are monitored.
+func (m *Mongo) ExchangeSymbols(ctx context.Context) (map[string][]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
 
-	collection := client.Database(cfg.MarketDatabase).Collection(cfg.ConfigCollection)
+	legacy, err := m.LegacyKucoinSymbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked, err := m.ListTrackedSymbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]string{
+		"kucoin":  mergeKucoinSymbols(legacy, tracked),
+		"binance": binanceSymbols(legacy, tracked),
+	}, nil
+}
+
+// LegacyKucoinSymbols returns the kucoin_symbol of every document in the
+// market-making config collection.
+func (m *Mongo) LegacyKucoinSymbols(ctx context.Context) ([]string, error) {
+	collection, err := m.Collection(ctx, m.cfg.Mongo.ConfigCollection)
+	if err != nil {
+		return nil, err
+	}
 
 	var results []bson.M
 	cursor, err := collection.Find(ctx, bson.M{})
@@ -98,4 +216,121 @@ func GetKucoinSymbolsFromDB() ([]string, error) {
 	}
 
 	return symbols, nil
-}
\ No newline at end of file
+}
+
+// WatchSymbolChanges opens a change stream reporting writes to the legacy
+// config collection and the tracked symbols. It fails on deployments without
+// change streams, such as standalone servers.
+func (m *Mongo) WatchSymbolChanges(ctx context.Context) (*mongo.ChangeStream, error) {
+	client, err := m.Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MongoDB client: %w", err)
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"ns.coll": bson.M{"$in": []string{m.cfg.Mongo.ConfigCollection, m.cfg.Mongo.SymbolCollection}},
+	}}}}
+	stream, err := client.Database(m.cfg.Mongo.MarketDatabase).Watch(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open change stream: %w", err)
+	}
+	return stream, nil
+}
+
+// mergeKucoinSymbols combines symbols from the legacy config documents with
+// those managed through the admin API. Enabled tracked symbols are added and
+// disabled ones are removed, even when a legacy document still lists them.
+func mergeKucoinSymbols(legacy []string, tracked []models.TrackedSymbol) []string {
+	disabled := make(map[string]bool)
+	for _, t := range tracked {
+		if !t.Enabled {
+			if symbol, ok := t.Exchanges["kucoin"]; ok {
+				disabled[symbol] = true
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(symbol string) {
+		if symbol == "" || seen[symbol] || disabled[symbol] {
+			return
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+
+	for _, symbol := range legacy {
+		add(symbol)
+	}
+	for _, t := range tracked {
+		if t.Enabled {
+			add(t.Exchanges["kucoin"])
+		}
+	}
+
+	return symbols
+}
+
+// binanceSymbols returns the Binance symbols of the enabled tracked symbols.
+// Symbols only listed in the legacy config documents have no Binance mapping
+// and are assumed to use their KuCoin symbol.
+func binanceSymbols(legacy []string, tracked []models.TrackedSymbol) []string {
+	mapped := make(map[string]bool)
+	for _, t := range tracked {
+		if symbol, ok := t.Exchanges["kucoin"]; ok {
+			mapped[symbol] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(symbol string) {
+		if symbol == "" || seen[symbol] {
+			return
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+
+	for _, symbol := range legacy {
+		if !mapped[symbol] {
+			add(symbol)
+		}
+	}
+	for _, t := range tracked {
+		if t.Enabled {
+			add(t.Exchanges["binance"])
+		}
+	}
+
+	return symbols
+}
+
+// commandMonitors combines monitors into one, as the client takes a single
+// command monitor.
+func commandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,26 @@
+package db
+
+import (
+	"crypto_price/pkg/models"
+	"reflect"
+	"testing"
+)
+
+func TestExchangeSymbolLists(t *testing.T) {
+	legacy := []string{"ETH", "XBT", "DOGE"}
+	tracked := []models.TrackedSymbol{
+		{Asset: "BTC", Exchanges: map[string]string{"kucoin": "XBT", "binance": "BTC"}, Enabled: true},
+		{Asset: "SOL", Exchanges: map[string]string{"kucoin": "SOL"}, Enabled: true},
+		{Asset: "PEPE", Exchanges: map[string]string{"binance": "1000PEPE"}, Enabled: true},
+		{Asset: "DOGE", Exchanges: map[string]string{"kucoin": "DOGE", "binance": "DOGE"}, Enabled: false},
+	}
+
+	if got, want := mergeKucoinSymbols(legacy, tracked), []string{"ETH", "XBT", "SOL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeKucoinSymbols = %v, want %v", got, want)
+	}
+	// ETH only has a legacy document, so Binance falls back to its KuCoin
+	// symbol; XBT and DOGE are covered by tracked symbols.
+	if got, want := binanceSymbols(legacy, tracked), []string{"ETH", "BTC", "1000PEPE"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("binanceSymbols = %v, want %v", got, want)
+	}
+}
@@ -2,96 +2,143 @@ package db
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"crypto_price/pkg/config"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/tracing"
 	"fmt"
-	"log"
-	"sync"
+	"log/slog"
+	"os"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-var (
-	redisClient     *redis.Client
-	redisClientOnce sync.Once
-	redisClientErr  error
+// Redis deployment modes selectable with redis.mode. An empty mode
+// lets go-redis pick: Sentinel when a master name is set, Cluster when more
+// than one address is given, a single node otherwise.
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
 )
 
-func GetRedisClient() (*redis.Client, error) {
-	redisClientOnce.Do(func() {
-		cfg := config.GetConfigs()
-		redisClient = redis.NewClient(&redis.Options{
-			Addr:         cfg.RedisHost,
-			Password:     "",
-			DB:           0,
-			PoolSize:     10,
-			MinIdleConns: 2,  
-			MaxConnAge:   30 * time.Minute,
-			IdleTimeout:  5 * time.Minute,
-		})
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		_, err := redisClient.Ping(ctx).Result()
+// NewRedisClient builds the Redis client described by cfg. It only fails on
+// invalid configuration: go-redis dials lazily and re-dials broken pool
+// connections, so a Redis outage surfaces as command errors that clear up
+// once the server is reachable again.
+func NewRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.AddHook(tracing.RedisHook{})
+	client.AddHook(metrics.RedisHook{})
+	client.AddHook(redisLogHook{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		slog.Warn("Redis unavailable, will retry", "error", err)
+	} else {
+		slog.Info("Redis connection pool initialized successfully", "client", fmt.Sprintf("%T", client))
+	}
+
+	return client, nil
+}
+
+func newRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	rc := cfg.Redis
+	opts := &redis.UniversalOptions{
+		Addrs:            rc.Addrs,
+		Username:         rc.Username,
+		Password:         rc.Password,
+		DB:               rc.DB,
+		MasterName:       rc.MasterName,
+		SentinelPassword: rc.SentinelPassword,
+		PoolSize:         rc.PoolSize,
+		MinIdleConns:     rc.MinIdleConns,
+		MaxConnAge:       30 * time.Minute,
+		IdleTimeout:      5 * time.Minute,
+	}
+
+	if rc.TLS {
+		tlsConfig, err := redisTLSConfig(rc.TLSCAFile)
 		if err != nil {
-			redisClientErr = fmt.Errorf("failed to connect to Redis: %w", err)
-			return
+			return nil, err
 		}
+		opts.TLSConfig = tlsConfig
+	}
 
-		log.Println("Redis connection pool initialized successfully")
-	})
-
-	return redisClient, redisClientErr
+	switch rc.Mode {
+	case "":
+		return redis.NewUniversalClient(opts), nil
+	case RedisModeStandalone:
+		return redis.NewClient(opts.Simple()), nil
+	case RedisModeSentinel:
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("redis.master_name is required in %s mode", RedisModeSentinel)
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case RedisModeCluster:
+		if opts.DB != 0 {
+			return nil, fmt.Errorf("redis.db must be 0 in %s mode", RedisModeCluster)
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q (expected %s, %s or %s)",
+			rc.Mode, RedisModeStandalone, RedisModeSentinel, RedisModeCluster)
+	}
 }
 
-// CreateRedisClient is kept for backward compatibility but marked as deprecated
-// Use GetRedisClient() instead for better performance
-func CreateRedisClient() *redis.Client {
-	client, err := GetRedisClient()
+func redisTLSConfig(caFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
 	if err != nil {
-		log.Printf("Redis connection error: %v", err)
-		return nil
+		return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
 	}
-	return client
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in Redis CA file %s", caFile)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
 }
 
+// redisLogHook logs every Redis command at debug level with the context it
+// was issued with, so commands can be traced back to the request or job run
+// that sent them.
+type redisLogHook struct{}
 
-// Redis expiration constants
-const (
-	RedisLongTermExpiration  = 10 * time.Minute
-	RedisShortTermExpiration = 20 * time.Second
-)
+func (redisLogHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
 
-func StorePricesInRedis(client *redis.Client, prices map[string]float64, source string) error {
-	if client == nil {
-		return fmt.Errorf("Redis client is nil")
-	}
+func (redisLogHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	logRedisCommand(ctx, cmd.Name(), cmd.Err())
+	return nil
+}
 
-	now := time.Now()
-	ctx := context.Background()
-	for symbol, price := range prices {
-		// Long-term key with expiration
-		longTermKey := fmt.Sprintf("%s:%s:long", source, symbol)
-		longTermTimeKey := fmt.Sprintf("%s:%s:long:time", source, symbol)
-		// Short-term key with expiration
-		shortTermKey := fmt.Sprintf("%s:%s:short", source, symbol)
-		shortTermTimeKey := fmt.Sprintf("%s:%s:short:time", source, symbol)
-
-		// Use a transaction to set both keys atomically
-		_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
-			pipe.Set(ctx, longTermKey, price, RedisLongTermExpiration)
-			pipe.Set(ctx, shortTermKey, price, RedisShortTermExpiration)
-			pipe.Set(ctx, longTermTimeKey, now.Unix(), RedisLongTermExpiration)
-			pipe.Set(ctx, shortTermTimeKey, now.Unix(), RedisShortTermExpiration)
-			return nil
-		})
+func (redisLogHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
 
-		if err != nil {
-			log.Printf("Error storing price for %s:%s: %v", source, symbol, err)
-			return fmt.Errorf("failed to store price for %s:%s: %w", source, symbol, err)
-		}
+func (redisLogHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		logRedisCommand(ctx, cmd.Name(), cmd.Err())
 	}
-
 	return nil
-}
\ No newline at end of file
+}
+
+func logRedisCommand(ctx context.Context, command string, err error) {
+	if err != nil && err != redis.Nil {
+		slog.DebugContext(ctx, "Redis command failed", "command", command, "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "Redis command", "command", command)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SettingsDocumentID is the _id of the settings document in the settings
+// collection. Its settings field uses the nested config file format, e.g.
+// {_id: "crypto_price", settings: {jobs: {kucoin_interval: "30s"}}}.
+const SettingsDocumentID = "crypto_price"
+
+// Settings returns the settings field of the settings document, or nil when
+// the document does not exist.
+func (m *Mongo) Settings(ctx context.Context) (map[string]interface{}, error) {
+	collection, err := m.Collection(ctx, m.cfg.Mongo.SettingsCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Settings bson.D `bson:"settings"`
+	}
+	err = collection.FindOne(ctx, bson.M{"_id": SettingsDocumentID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find settings document: %w", err)
+	}
+
+	settings, _ := plainValue(doc.Settings).(map[string]interface{})
+	return settings, nil
+}
+
+// ReadSettings returns the settings document with a connection of its own,
+// for use before the application is built. It matches config.SettingsReader.
+func ReadSettings(ctx context.Context, cfg *config.Config) (map[string]interface{}, error) {
+	m := &Mongo{cfg: cfg}
+	defer m.Disconnect(context.Background())
+	return m.Settings(ctx)
+}
+
+// plainValue converts BSON documents and arrays to maps and slices, so the
+// settings can be decoded like a config file.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case primitive.D:
+		out := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			out[e.Key] = plainValue(e.Value)
+		}
+		return out
+	case primitive.A:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = plainValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
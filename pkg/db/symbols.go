@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"crypto_price/pkg/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrSymbolExists   = errors.New("symbol is already tracked")
+	ErrSymbolNotFound = errors.New("symbol is not tracked")
+)
+
+func (m *Mongo) symbolCollections(ctx context.Context) (*mongo.Collection, *mongo.Collection, error) {
+	symbols, err := m.Collection(ctx, m.cfg.Mongo.SymbolCollection)
+	if err != nil {
+		return nil, nil, err
+	}
+	audit, err := m.Collection(ctx, m.cfg.Mongo.SymbolAuditCollection)
+	if err != nil {
+		return nil, nil, err
+	}
+	return symbols, audit, nil
+}
+
+// ListTrackedSymbols returns every tracked symbol, enabled or not, sorted by asset.
+func (m *Mongo) ListTrackedSymbols(ctx context.Context) ([]models.TrackedSymbol, error) {
+	symbols, _, err := m.symbolCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := symbols.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tracked symbols: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.TrackedSymbol{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode tracked symbols: %w", err)
+	}
+	return results, nil
+}
+
+// GetTrackedSymbol returns the tracked symbol for asset or ErrSymbolNotFound.
+func (m *Mongo) GetTrackedSymbol(ctx context.Context, asset string) (models.TrackedSymbol, error) {
+	symbols, _, err := m.symbolCollections(ctx)
+	if err != nil {
+		return models.TrackedSymbol{}, err
+	}
+
+	var symbol models.TrackedSymbol
+	err = symbols.FindOne(ctx, bson.M{"_id": asset}).Decode(&symbol)
+	if err == mongo.ErrNoDocuments {
+		return models.TrackedSymbol{}, fmt.Errorf("%w: %s", ErrSymbolNotFound, asset)
+	}
+	if err != nil {
+		return models.TrackedSymbol{}, fmt.Errorf("failed to find tracked symbol %s: %w", asset, err)
+	}
+	return symbol, nil
+}
+
+// AddTrackedSymbol inserts a new enabled symbol and records it in the audit log.
+func (m *Mongo) AddTrackedSymbol(ctx context.Context, symbol models.TrackedSymbol, actor string) (models.TrackedSymbol, error) {
+	symbols, audit, err := m.symbolCollections(ctx)
+	if err != nil {
+		return models.TrackedSymbol{}, err
+	}
+
+	now := time.Now().UTC()
+	symbol.Enabled = true
+	symbol.CreatedAt = now
+	symbol.UpdatedAt = now
+
+	if _, err := symbols.InsertOne(ctx, symbol); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return models.TrackedSymbol{}, fmt.Errorf("%w: %s", ErrSymbolExists, symbol.Asset)
+		}
+		return models.TrackedSymbol{}, fmt.Errorf("failed to insert tracked symbol %s: %w", symbol.Asset, err)
+	}
+
+	if err := insertSymbolAudit(ctx, audit, models.SymbolAuditEntry{
+		Asset:  symbol.Asset,
+		Action: models.SymbolActionAdd,
+		Actor:  actor,
+		After:  &symbol,
+		At:     now,
+	}); err != nil {
+		return symbol, err
+	}
+	return symbol, nil
+}
+
+// SetTrackedSymbolEnabled enables or disables a tracked symbol and records the
+// change in the audit log.
+func (m *Mongo) SetTrackedSymbolEnabled(ctx context.Context, asset string, enabled bool, actor string) (models.TrackedSymbol, error) {
+	symbols, audit, err := m.symbolCollections(ctx)
+	if err != nil {
+		return models.TrackedSymbol{}, err
+	}
+
+	now := time.Now().UTC()
+	var before models.TrackedSymbol
+	err = symbols.FindOneAndUpdate(ctx,
+		bson.M{"_id": asset},
+		bson.M{"$set": bson.M{"enabled": enabled, "updated_at": now}},
+	).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return models.TrackedSymbol{}, fmt.Errorf("%w: %s", ErrSymbolNotFound, asset)
+	}
+	if err != nil {
+		return models.TrackedSymbol{}, fmt.Errorf("failed to update tracked symbol %s: %w", asset, err)
+	}
+
+	after := before
+	after.Enabled = enabled
+	after.UpdatedAt = now
+
+	action := models.SymbolActionDisable
+	if enabled {
+		action = models.SymbolActionEnable
+	}
+	if err := insertSymbolAudit(ctx, audit, models.SymbolAuditEntry{
+		Asset:  asset,
+		Action: action,
+		Actor:  actor,
+		Before: &before,
+		After:  &after,
+		At:     now,
+	}); err != nil {
+		return after, err
+	}
+	return after, nil
+}
+
+// ListSymbolAudit returns the audit log of asset, newest first.
+func (m *Mongo) ListSymbolAudit(ctx context.Context, asset string, limit int64) ([]models.SymbolAuditEntry, error) {
+	_, audit, err := m.symbolCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"at": -1}).SetLimit(limit)
+	cursor, err := audit.Find(ctx, bson.M{"asset": asset}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find audit log for %s: %w", asset, err)
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.SymbolAuditEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode audit log for %s: %w", asset, err)
+	}
+	return entries, nil
+}
+
+func insertSymbolAudit(ctx context.Context, audit *mongo.Collection, entry models.SymbolAuditEntry) error {
+	if _, err := audit.InsertOne(ctx, entry); err != nil {
+		return fmt.Errorf("failed to write audit log for %s: %w", entry.Asset, err)
+	}
+	return nil
+}
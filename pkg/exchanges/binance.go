@@ -1,25 +1,44 @@
 package exchanges
 
 import (
+	"context"
+	"crypto_price/pkg/logging"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
+// Request weights of the Binance endpoints.
+const (
+	binanceTicker24hWeight    = 80
+	binanceExchangeInfoWeight = 20
+	binanceKlinesWeight       = 2
+)
+
+// BinanceTicker is an entry of the 24h ticker response. Decimals are
+// strings in the JSON response and parsed later.
 type BinanceTicker struct {
-	Symbol string  `json:"symbol"`
-	Price  string  `json:"price"` // Adjusted to string to match the JSON response and parse it later
+	Symbol             string `json:"symbol"`
+	LastPrice          string `json:"lastPrice"`
+	PriceChange        string `json:"priceChange"`
+	PriceChangePercent string `json:"priceChangePercent"`
+	BidPrice           string `json:"bidPrice"`
+	AskPrice           string `json:"askPrice"`
+	HighPrice          string `json:"highPrice"`
+	LowPrice           string `json:"lowPrice"`
+	Volume             string `json:"volume"`
+	QuoteVolume        string `json:"quoteVolume"`
 }
 
-func GetAllBinancePrices() (map[string]float64, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	url := "https://api.binance.com/api/v3/ticker/price"
+// GetAllBinancePrices fetches the price and 24h ticker of every Binance
+// market in one request. Results are keyed by market, e.g. "BTCUSDT", in
+// both Symbol and Market. Markets without a valid price are left out.
+func (c *Client) GetAllBinancePrices(ctx context.Context) (PriceResults, error) {
+	url := c.binanceURL + "/api/v3/ticker/24hr"
 
-	resp, err := client.Get(url)
+	resp, err := c.binance.get(ctx, url, binanceTicker24hWeight)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Binance API: %w", err)
 	}
@@ -38,32 +57,90 @@ func GetAllBinancePrices() (map[string]float64, error) {
 		return nil, fmt.Errorf("binance API returned empty ticker list")
 	}
 
-	prices := make(map[string]float64)
+	results := make(PriceResults, 0, len(tickers))
 	for _, ticker := range tickers {
 		if ticker.Symbol == "" {
 			continue
 		}
 
-		price, err := strconv.ParseFloat(ticker.Price, 64)
+		// A bad ticker only drops its own symbol.
+		price, err := strconv.ParseFloat(ticker.LastPrice, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse price for symbol %s (value: %s): %w", ticker.Symbol, ticker.Price, err)
+			logging.Sampled(ctx, slog.LevelWarn, "binance:"+ticker.Symbol, "Invalid Binance price", "symbol", ticker.Symbol, "value", ticker.LastPrice, "error", err)
+			continue
 		}
 
+		// Markets that are halted or delisted report a zero price.
 		if price <= 0 {
-			return nil, fmt.Errorf("invalid price for symbol %s: %f", ticker.Symbol, price)
+			continue
 		}
 
-		prices[ticker.Symbol] = price
+		results = append(results, PriceResult{
+			Symbol: ticker.Symbol,
+			Market: ticker.Symbol,
+			Price:  price,
+			Ticker: &Ticker{
+				Bid:         parseOptional(ticker.BidPrice),
+				Ask:         parseOptional(ticker.AskPrice),
+				High:        parseOptional(ticker.HighPrice),
+				Low:         parseOptional(ticker.LowPrice),
+				Volume:      parseOptional(ticker.Volume),
+				QuoteVolume: parseOptional(ticker.QuoteVolume),
+				Change:      parseOptional(ticker.PriceChange),
+				ChangeRate:  parseOptional(ticker.PriceChangePercent) / 100,
+			},
+		})
 	}
 
-	if len(prices) == 0 {
+	if len(results) == 0 {
 		return nil, fmt.Errorf("no valid prices found in Binance API response")
 	}
 
-	return prices, nil
+	return results, nil
+}
+
+type binanceExchangeInfo struct {
+	Symbols []struct {
+		Symbol string `json:"symbol"`
+		Status string `json:"status"`
+	} `json:"symbols"`
 }
 
-func GetNLastCandlesOfBinance(symbol string, limit int) ([]map[string]interface{}, error) {
+// GetBinanceSymbols returns the set of symbols (e.g. "BTCUSDT") currently
+// trading on Binance.
+func (c *Client) GetBinanceSymbols(ctx context.Context) (map[string]bool, error) {
+	url := c.binanceURL + "/api/v3/exchangeInfo"
+
+	resp, err := c.binance.get(ctx, url, binanceExchangeInfoWeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Binance API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("binance API returned HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	var info binanceExchangeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response from Binance API: %w", err)
+	}
+
+	symbols := make(map[string]bool)
+	for _, s := range info.Symbols {
+		if s.Status == "TRADING" {
+			symbols[s.Symbol] = true
+		}
+	}
+
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("binance API returned no trading symbols")
+	}
+
+	return symbols, nil
+}
+
+func (c *Client) GetNLastCandlesOfBinance(ctx context.Context, symbol string, limit int) ([]map[string]interface{}, error) {
 	if symbol == "" {
 		return nil, fmt.Errorf("symbol cannot be empty")
 	}
@@ -72,12 +149,9 @@ func GetNLastCandlesOfBinance(symbol string, limit int) ([]map[string]interface{
 		return nil, fmt.Errorf("limit must be between 1 and 1000, got %d", limit)
 	}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	url := fmt.Sprintf("https://api.binance.com/api/v3/klines?symbol=%s&interval=1m&limit=%d", symbol, limit)
+	url := fmt.Sprintf("%s/api/v3/klines?symbol=%s&interval=1m&limit=%d", c.binanceURL, symbol, limit)
 
-	resp, err := client.Get(url)
+	resp, err := c.binance.get(ctx, url, binanceKlinesWeight)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Binance API for symbol %s: %w", symbol, err)
 	}
@@ -100,9 +174,18 @@ func GetNLastCandlesOfBinance(symbol string, limit int) ([]map[string]interface{
 	if len(candles) != limit {
 		// This might not be an error if Binance doesn't have enough historical data
 		// Just log a warning but don't fail
-		fmt.Printf("Warning: Expected %d candles for %s, got %d\n", limit, symbol, len(candles))
+		slog.Warn("Binance returned fewer candles than requested", "symbol", symbol, "expected", limit, "got", len(candles))
 	}
 
 	return candles, nil
 }
 
+
+// PingBinance checks that the Binance API is reachable, without downloading
+// market data.
+func (c *Client) PingBinance(ctx context.Context) error {
+	if err := c.ping(ctx, c.binance, c.binanceURL+"/api/v3/ping"); err != nil {
+		return fmt.Errorf("binance ping failed: %w", err)
+	}
+	return nil
+}
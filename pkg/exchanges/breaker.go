@@ -0,0 +1,133 @@
+package exchanges
+
+import (
+	"crypto_price/pkg/metrics"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitHalfOpen = "half_open"
+	CircuitOpen     = "open"
+)
+
+// ErrCircuitOpen is returned for requests to an exchange whose circuit is
+// open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitStatus is the circuit breaker state of an exchange.
+type CircuitStatus struct {
+	State string `json:"state"`
+	// Failures counts the consecutive failed requests.
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	// RetryAt is when an open circuit lets a probe request through.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+// breaker stops requests to an exchange after threshold consecutive
+// failures. Once cooldown has passed, a single probe is let through: the
+// circuit closes if it succeeds and opens again if it fails.
+type breaker struct {
+	exchange  string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(exchange string, threshold int, cooldown time.Duration) *breaker {
+	b := &breaker{exchange: exchange, threshold: threshold, cooldown: cooldown, state: CircuitClosed}
+	metrics.SetExchangeCircuitState(exchange, 0)
+	return b
+}
+
+// allow reports whether a request may be sent, returning ErrCircuitOpen if
+// not. A request allowed while half-open is the probe and must be followed
+// by success or failure.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cooldown {
+		b.setState(CircuitHalfOpen)
+	}
+	switch {
+	case b.state == CircuitOpen:
+		return fmt.Errorf("%w until %s", ErrCircuitOpen, b.openedAt.Add(b.cooldown).Format(time.RFC3339))
+	case b.state == CircuitHalfOpen && b.probing:
+		return fmt.Errorf("%w, probe in flight", ErrCircuitOpen)
+	case b.state == CircuitHalfOpen:
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	if b.state != CircuitClosed {
+		b.setState(CircuitClosed)
+	}
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.setState(CircuitOpen)
+	}
+}
+
+// abort ends a request that neither succeeded nor failed, e.g. one canceled
+// by its caller, so another probe may be sent.
+func (b *breaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// setState moves the breaker to state; b.mu must be held.
+func (b *breaker) setState(state string) {
+	previous := b.state
+	b.state = state
+	switch state {
+	case CircuitClosed:
+		metrics.SetExchangeCircuitState(b.exchange, 0)
+		slog.Info("Exchange circuit closed", "exchange", b.exchange)
+	case CircuitHalfOpen:
+		metrics.SetExchangeCircuitState(b.exchange, 1)
+	case CircuitOpen:
+		metrics.SetExchangeCircuitState(b.exchange, 2)
+		slog.Warn("Exchange circuit opened", "exchange", b.exchange, "previous", previous,
+			"failures", b.failures, "cooldown", b.cooldown)
+	}
+}
+
+func (b *breaker) status() CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := CircuitStatus{State: b.state, Failures: b.failures}
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cooldown {
+		status.State = CircuitHalfOpen
+	}
+	if status.State != CircuitClosed {
+		opened, retry := b.openedAt, b.openedAt.Add(b.cooldown)
+		status.OpenedAt, status.RetryAt = &opened, &retry
+	}
+	return status
+}
@@ -0,0 +1,70 @@
+package exchanges
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// expire moves the opening of b past its cooldown.
+func expire(b *breaker) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openedAt = time.Now().Add(-b.cooldown)
+}
+
+func TestBreaker(t *testing.T) {
+	b := newBreaker("test", 2, time.Minute)
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("closed circuit: allow = %v", err)
+	}
+	b.failure()
+	if s := b.status(); s.State != CircuitClosed || s.Failures != 1 {
+		t.Fatalf("after one failure: %+v, want closed with 1 failure", s)
+	}
+	b.failure()
+	if s := b.status(); s.State != CircuitOpen || s.RetryAt == nil {
+		t.Fatalf("after threshold failures: %+v, want open with a retry time", s)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open circuit: allow = %v, want ErrCircuitOpen", err)
+	}
+
+	// Past the cooldown, a single probe is let through.
+	expire(b)
+	if s := b.status(); s.State != CircuitHalfOpen {
+		t.Fatalf("after cooldown: state = %s, want half_open", s.State)
+	}
+	if err := b.allow(); err != nil {
+		t.Fatalf("half-open circuit: probe allow = %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("half-open circuit with a probe in flight: allow = %v, want ErrCircuitOpen", err)
+	}
+
+	// A failed probe opens the circuit again right away.
+	b.failure()
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after failed probe: allow = %v, want ErrCircuitOpen", err)
+	}
+
+	// An aborted probe lets another one through.
+	expire(b)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe allow = %v", err)
+	}
+	b.abort()
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe after abort: allow = %v", err)
+	}
+
+	// A successful probe closes the circuit.
+	b.success()
+	if s := b.status(); s.State != CircuitClosed || s.Failures != 0 || s.OpenedAt != nil {
+		t.Fatalf("after successful probe: %+v, want closed without failures", s)
+	}
+	if err := b.allow(); err != nil {
+		t.Fatalf("closed circuit: allow = %v", err)
+	}
+}
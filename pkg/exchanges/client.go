@@ -0,0 +1,95 @@
+package exchanges
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/tracing"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client fetches market data from the supported exchanges over a shared
+// HTTP client, so connections are reused across requests. Requests to each
+// exchange are rate limited, retried and circuit broken independently.
+type Client struct {
+	binance *outbound
+	kucoin  *outbound
+
+	binanceURL string
+	kucoinURL  string
+	// priceTimeout bounds each per-symbol price request.
+	priceTimeout time.Duration
+}
+
+// NewClient returns a Client for the exchange endpoints and timeouts of cfg.
+// Requests are traced and recorded in the exchange metrics; the outbound
+// limits of each exchange come from cfg.Binance and cfg.Kucoin.
+func NewClient(cfg config.ExchangesConfig) *Client {
+	var transport http.RoundTripper = &instrumentedTransport{
+		base: http.DefaultTransport,
+		exchanges: map[string]string{
+			cfg.BinanceURL: Binance,
+			cfg.KucoinURL:  KuCoin,
+		},
+	}
+	transport = tracing.Transport(transport)
+	client := &http.Client{Timeout: cfg.Timeout, Transport: transport}
+	return &Client{
+		binance:      newOutbound(Binance, client, cfg.Binance),
+		kucoin:       newOutbound(KuCoin, client, cfg.Kucoin),
+		binanceURL:   cfg.BinanceURL,
+		kucoinURL:    cfg.KucoinURL,
+		priceTimeout: cfg.PriceTimeout,
+	}
+}
+
+// Circuit returns the circuit breaker state of exchange (Binance or KuCoin).
+func (c *Client) Circuit(exchange string) CircuitStatus {
+	if exchange == Binance {
+		return c.binance.circuit()
+	}
+	return c.kucoin.circuit()
+}
+
+// ping checks that url answers 200 OK. It is meant for the cheap status
+// endpoints of the exchanges.
+func (c *Client) ping(ctx context.Context, o *outbound, url string) error {
+	resp, err := o.get(ctx, url, 1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// instrumentedTransport records the latency and failures of exchange
+// requests. Requests are attributed to the exchange whose base URL they
+// start with; the endpoint is the request path, which never holds symbols.
+type instrumentedTransport struct {
+	base      http.RoundTripper
+	exchanges map[string]string
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	exchange := "unknown"
+	for baseURL, name := range t.exchanges {
+		if strings.HasPrefix(req.URL.String(), baseURL) {
+			exchange = name
+			break
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	metrics.ObserveExchangeRequest(exchange, req.URL.Path, start, err != nil || resp.StatusCode >= http.StatusBadRequest)
+	return resp, err
+}
@@ -1,12 +1,14 @@
 package exchanges
 
 import (
+	"context"
+	"crypto_price/pkg/logging"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"sync"
-	"time"
 )
 
 type PriceResponse struct {
@@ -15,75 +17,243 @@ type PriceResponse struct {
 	} `json:"data"`
 }
 
+// Request weights of the KuCoin endpoints.
 const (
-	baseURL = "https://api.kucoin.com/api/v1/market/orderbook/level1"
+	kucoinLevel1Weight     = 2
+	kucoinSymbolsWeight    = 4
+	kucoinAllTickersWeight = 15
 )
 
-func GetPricesKucoin(cryptoList []string) (map[string]float64, error) {
-	var wg sync.WaitGroup
-	cryptoPrices := make(map[string]float64)
-	var cryptoPricesMutex sync.Mutex
-	var errors []error
-	var errorsMutex sync.Mutex
+type kucoinAllTickersResponse struct {
+	Data struct {
+		Ticker []kucoinTicker `json:"ticker"`
+	} `json:"data"`
+}
+
+// kucoinTicker is an entry of the all-tickers response. Fields without a
+// value, e.g. the best bid of an empty book, are null.
+type kucoinTicker struct {
+	Symbol      string `json:"symbol"`
+	Last        string `json:"last"`
+	Buy         string `json:"buy"`
+	Sell        string `json:"sell"`
+	ChangeRate  string `json:"changeRate"`
+	ChangePrice string `json:"changePrice"`
+	High        string `json:"high"`
+	Low         string `json:"low"`
+	Vol         string `json:"vol"`
+	VolValue    string `json:"volValue"`
+}
+
+// GetPricesKucoin fetches the USDT price of every symbol in cryptoList and
+// returns one result per symbol, in the same order. Prices and 24h tickers
+// come from a single all-tickers request; symbols missing from it, or all of
+// them if it fails, are fetched one by one without a ticker, at most
+// exchanges.kucoin.max_concurrency at once. A failing symbol does not affect
+// the others; each failure is logged, sampled per symbol.
+func (c *Client) GetPricesKucoin(ctx context.Context, cryptoList []string) PriceResults {
+	results := make(PriceResults, len(cryptoList))
+	if len(cryptoList) == 0 {
+		return results
+	}
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	tickers, err := c.getAllTickersKucoin(ctx)
+	if err != nil {
+		logging.Sampled(ctx, slog.LevelWarn, "kucoin:all_tickers", "KuCoin all-tickers request failed, fetching symbols one by one", "error", err)
+	}
+	var missing []int
+	for i, crypto := range cryptoList {
+		if ticker, ok := tickers[crypto+"-USDT"]; ok {
+			if result, err := ticker.result(crypto); err == nil {
+				results[i] = result
+				continue
+			}
+		}
+		missing = append(missing, i)
+	}
+	if len(missing) > 0 && err == nil {
+		slog.DebugContext(ctx, "Fetching KuCoin symbols missing from all tickers", "count", len(missing))
 	}
 
-	for _, crypto := range cryptoList {
+	c.fillPricesKucoin(ctx, cryptoList, missing, results)
+	return results
+}
+
+// fillPricesKucoin fetches the symbols of cryptoList at the indexes
+// missing one by one, storing their results in results.
+func (c *Client) fillPricesKucoin(ctx context.Context, cryptoList []string, missing []int, results PriceResults) {
+	queue := make(chan int)
+	workers := cap(c.kucoin.slots)
+	if workers > len(missing) {
+		workers = len(missing)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go func(crypto string) {
+		go func() {
 			defer wg.Done()
-
-			url := fmt.Sprintf("%s?symbol=%s-USDT", baseURL, crypto)
-			resp, err := client.Get(url)
-			if err != nil {
-				errorsMutex.Lock()
-				errors = append(errors, fmt.Errorf("failed to fetch %s: %w", crypto, err))
-				errorsMutex.Unlock()
-				return
+			for i := range queue {
+				crypto := cryptoList[i]
+				price, err := c.getPriceKucoin(ctx, crypto)
+				if err != nil {
+					logging.Sampled(ctx, slog.LevelWarn, "kucoin:"+crypto, "KuCoin price request failed", "symbol", crypto, "error", err)
+				}
+				results[i] = PriceResult{Symbol: crypto, Market: crypto + "USDT", Price: price, Err: err}
 			}
-			defer resp.Body.Close()
+		}()
+	}
+	for _, i := range missing {
+		queue <- i
+	}
+	close(queue)
 
-			if resp.StatusCode != http.StatusOK {
-				errorsMutex.Lock()
-				errors = append(errors, fmt.Errorf("failed to retrieve price for %s: HTTP %d", crypto, resp.StatusCode))
-				errorsMutex.Unlock()
-				return
-			}
+	wg.Wait()
+}
 
-			var priceResp PriceResponse
-			if err := json.NewDecoder(resp.Body).Decode(&priceResp); err != nil {
-				errorsMutex.Lock()
-				errors = append(errors, fmt.Errorf("failed to decode response for %s: %w", crypto, err))
-				errorsMutex.Unlock()
-				return
-			}
+// getAllTickersKucoin fetches the 24h tickers of every KuCoin market, keyed
+// by market, e.g. "BTC-USDT".
+func (c *Client) getAllTickersKucoin(ctx context.Context) (map[string]kucoinTicker, error) {
+	resp, err := c.kucoin.get(ctx, c.kucoinURL+"/api/v1/market/allTickers", kucoinAllTickersWeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch KuCoin tickers: %w", err)
+	}
+	defer resp.Body.Close()
 
-			price, err := strconv.ParseFloat(priceResp.Data.Price, 64)
-			if err != nil {
-				errorsMutex.Lock()
-				errors = append(errors, fmt.Errorf("failed to parse price for %s: %w", crypto, err))
-				errorsMutex.Unlock()
-				return
-			}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kucoin API returned HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
 
-			cryptoPricesMutex.Lock()
-			cryptoPrices[fmt.Sprintf("%sUSDT", crypto)] = price
-			cryptoPricesMutex.Unlock()
-		}(crypto)
+	var tickersResp kucoinAllTickersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tickersResp); err != nil {
+		return nil, fmt.Errorf("failed to decode KuCoin tickers: %w", err)
+	}
+	if len(tickersResp.Data.Ticker) == 0 {
+		return nil, fmt.Errorf("kucoin API returned no tickers")
 	}
 
-	wg.Wait()
+	tickers := make(map[string]kucoinTicker, len(tickersResp.Data.Ticker))
+	for _, ticker := range tickersResp.Data.Ticker {
+		tickers[ticker.Symbol] = ticker
+	}
+	return tickers, nil
+}
+
+// result converts the ticker of crypto. Only the last price is required.
+func (t kucoinTicker) result(crypto string) (PriceResult, error) {
+	price, err := strconv.ParseFloat(t.Last, 64)
+	if err != nil || price <= 0 {
+		return PriceResult{}, fmt.Errorf("invalid KuCoin last price for %s: %q", crypto, t.Last)
+	}
+	return PriceResult{
+		Symbol: crypto,
+		Market: crypto + "USDT",
+		Price:  price,
+		Ticker: &Ticker{
+			Bid:         parseOptional(t.Buy),
+			Ask:         parseOptional(t.Sell),
+			High:        parseOptional(t.High),
+			Low:         parseOptional(t.Low),
+			Volume:      parseOptional(t.Vol),
+			QuoteVolume: parseOptional(t.VolValue),
+			Change:      parseOptional(t.ChangePrice),
+			ChangeRate:  parseOptional(t.ChangeRate),
+		},
+	}, nil
+}
+
+// parseOptional parses an optional decimal field, 0 when missing or
+// invalid.
+func parseOptional(value string) float64 {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
+
+// getPriceKucoin fetches the USDT price of crypto, bounded by
+// exchanges.price_timeout including retries.
+func (c *Client) getPriceKucoin(ctx context.Context, crypto string) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.priceTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/api/v1/market/orderbook/level1?symbol=%s-USDT", c.kucoinURL, crypto)
+	resp, err := c.kucoin.get(ctx, url, kucoinLevel1Weight)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch %s: %w", crypto, err)
+	}
+	defer resp.Body.Close()
 
-	if len(errors) > 0 {
-		for _, err := range errors {
-			fmt.Printf("KuCoin API error: %v\n", err)
+	if resp.StatusCode >= http.StatusInternalServerError || rateLimited(resp) {
+		return 0, fmt.Errorf("%w: HTTP %d for %s", ErrUnavailable, resp.StatusCode, crypto)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to retrieve price for %s: HTTP %d", crypto, resp.StatusCode)
+	}
+
+	var priceResp PriceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&priceResp); err != nil {
+		return 0, fmt.Errorf("failed to decode response for %s: %w", crypto, err)
+	}
+
+	// Unknown and delisted symbols are answered with no data.
+	if priceResp.Data.Price == "" {
+		return 0, fmt.Errorf("no KuCoin price for %s", crypto)
+	}
+	price, err := strconv.ParseFloat(priceResp.Data.Price, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse price for %s: %w", crypto, err)
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("invalid price for %s: %f", crypto, price)
+	}
+	return price, nil
+}
+
+type kucoinSymbolsResponse struct {
+	Data []struct {
+		Symbol        string `json:"symbol"`
+		EnableTrading bool   `json:"enableTrading"`
+	} `json:"data"`
+}
+
+// GetKucoinSymbols returns the set of symbols (e.g. "BTC-USDT") currently
+// tradable on KuCoin.
+func (c *Client) GetKucoinSymbols(ctx context.Context) (map[string]bool, error) {
+	resp, err := c.kucoin.get(ctx, c.kucoinURL+"/api/v2/symbols", kucoinSymbolsWeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to KuCoin API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kucoin API returned HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	var symbolsResp kucoinSymbolsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&symbolsResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response from KuCoin API: %w", err)
+	}
+
+	symbols := make(map[string]bool)
+	for _, s := range symbolsResp.Data {
+		if s.EnableTrading {
+			symbols[s.Symbol] = true
 		}
+	}
 
-		// Return a compound error with all issues
-		return cryptoPrices, fmt.Errorf("encountered %d errors while fetching KuCoin prices: %v", len(errors), errors[0])
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("kucoin API returned no tradable symbols")
 	}
 
-	return cryptoPrices, nil
+	return symbols, nil
+}
+
+// PingKucoin checks that the KuCoin API is reachable, without downloading
+// market data.
+func (c *Client) PingKucoin(ctx context.Context) error {
+	if err := c.ping(ctx, c.kucoin, c.kucoinURL+"/api/v1/timestamp"); err != nil {
+		return fmt.Errorf("kucoin ping failed: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,173 @@
+package exchanges
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKucoin serves the all-tickers and level1 endpoints from fixed prices
+// and records the symbols requested one by one.
+type fakeKucoin struct {
+	// tickers is the all-tickers response; allTickersStatus replaces it
+	// with an error when set.
+	tickers          string
+	allTickersStatus int
+	// level1 holds the level1 prices; other symbols get no data.
+	level1 map[string]string
+
+	mu     sync.Mutex
+	single []string
+}
+
+func (f *fakeKucoin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/v1/market/allTickers":
+		if f.allTickersStatus != 0 {
+			w.WriteHeader(f.allTickersStatus)
+			return
+		}
+		fmt.Fprint(w, f.tickers)
+	case "/api/v1/market/orderbook/level1":
+		symbol := r.URL.Query().Get("symbol")
+		f.mu.Lock()
+		f.single = append(f.single, symbol)
+		f.mu.Unlock()
+		if price, ok := f.level1[symbol]; ok {
+			fmt.Fprintf(w, `{"code":"200000","data":{"price":%q}}`, price)
+			return
+		}
+		fmt.Fprint(w, `{"code":"200000","data":null}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeKucoin) singleRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	single := append([]string(nil), f.single...)
+	sort.Strings(single)
+	return single
+}
+
+func newTestKucoinClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	outbound := config.OutboundConfig{
+		MaxConcurrency:   4,
+		RateLimit:        1000,
+		Burst:            100,
+		RetryBackoff:     time.Millisecond,
+		MaxRetryWait:     time.Second,
+		BreakerThreshold: 100,
+		BreakerCooldown:  time.Minute,
+	}
+	return NewClient(config.ExchangesConfig{
+		Timeout:      time.Second,
+		PriceTimeout: time.Second,
+		BinanceURL:   server.URL + "/binance",
+		KucoinURL:    server.URL,
+		Binance:      outbound,
+		Kucoin:       outbound,
+	})
+}
+
+func TestGetPricesKucoin(t *testing.T) {
+	const tickers = `{"code":"200000","data":{"time":1,"ticker":[
+		{"symbol":"BTC-USDT","last":"60000","buy":"59999","sell":"60001","changeRate":"0.01","changePrice":"600","high":"61000","low":"59000","vol":"10","volValue":"600000"},
+		{"symbol":"ETH-USDT","last":"3000","buy":null,"sell":null,"changeRate":null,"changePrice":null,"high":null,"low":null,"vol":null,"volValue":null},
+		{"symbol":"DOGE-USDT","last":null}
+	]}}`
+
+	tests := []struct {
+		name             string
+		allTickersStatus int
+		symbols          []string
+		// want maps symbols to their price, 0 for a failed result.
+		want       map[string]float64
+		wantTicker []string
+		wantSingle []string
+	}{
+		{
+			name:       "bulk",
+			symbols:    []string{"BTC", "ETH"},
+			want:       map[string]float64{"BTC": 60000, "ETH": 3000},
+			wantTicker: []string{"BTC", "ETH"},
+		},
+		{
+			name:       "missing from bulk",
+			symbols:    []string{"BTC", "SOL", "DOGE", "LUNA"},
+			want:       map[string]float64{"BTC": 60000, "SOL": 150, "DOGE": 0.1, "LUNA": 0},
+			wantTicker: []string{"BTC"},
+			wantSingle: []string{"DOGE-USDT", "LUNA-USDT", "SOL-USDT"},
+		},
+		{
+			name:             "bulk failure",
+			allTickersStatus: http.StatusBadRequest,
+			symbols:          []string{"BTC", "SOL"},
+			want:             map[string]float64{"BTC": 59000, "SOL": 150},
+			wantSingle:       []string{"BTC-USDT", "SOL-USDT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeKucoin{
+				tickers:          tickers,
+				allTickersStatus: tt.allTickersStatus,
+				level1:           map[string]string{"BTC-USDT": "59000", "SOL-USDT": "150", "DOGE-USDT": "0.1"},
+			}
+			c := newTestKucoinClient(t, fake)
+
+			results := c.GetPricesKucoin(context.Background(), tt.symbols)
+			if len(results) != len(tt.symbols) {
+				t.Fatalf("got %d results for %d symbols", len(results), len(tt.symbols))
+			}
+			var withTicker []string
+			for i, result := range results {
+				symbol := tt.symbols[i]
+				if result.Symbol != symbol || result.Market != symbol+"USDT" {
+					t.Errorf("result %d is %s/%s, want %s/%sUSDT", i, result.Symbol, result.Market, symbol, symbol)
+				}
+				if want := tt.want[symbol]; want == 0 {
+					if result.Err == nil {
+						t.Errorf("%s: price %v, want an error", symbol, result.Price)
+					}
+				} else if result.Err != nil || result.Price != want {
+					t.Errorf("%s: price %v, error %v; want %v", symbol, result.Price, result.Err, want)
+				}
+				if result.Ticker != nil {
+					withTicker = append(withTicker, symbol)
+				}
+			}
+			if strings.Join(withTicker, ",") != strings.Join(tt.wantTicker, ",") {
+				t.Errorf("symbols with a ticker = %v, want %v", withTicker, tt.wantTicker)
+			}
+			if single := fake.singleRequests(); strings.Join(single, ",") != strings.Join(tt.wantSingle, ",") {
+				t.Errorf("symbols fetched one by one = %v, want %v", single, tt.wantSingle)
+			}
+		})
+	}
+}
+
+func TestGetPricesKucoinTicker(t *testing.T) {
+	fake := &fakeKucoin{tickers: `{"data":{"ticker":[
+		{"symbol":"BTC-USDT","last":"60000","buy":"59999","sell":"60001","changeRate":"0.01","changePrice":"600","high":"61000","low":"59000","vol":"10","volValue":"600000"}
+	]}}`}
+	c := newTestKucoinClient(t, fake)
+
+	results := c.GetPricesKucoin(context.Background(), []string{"BTC"})
+	want := Ticker{Bid: 59999, Ask: 60001, High: 61000, Low: 59000, Volume: 10, QuoteVolume: 600000, Change: 600, ChangeRate: 0.01}
+	if results[0].Ticker == nil || *results[0].Ticker != want {
+		t.Errorf("ticker = %+v, want %+v", results[0].Ticker, want)
+	}
+}
@@ -0,0 +1,90 @@
+package exchanges
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errRateLimited is returned when the rate limit of an exchange would hold a
+// request back past its deadline.
+var errRateLimited = errors.New("rate limited")
+
+// tokenBucket limits the request weight spent on an exchange. Tokens refill
+// at rate per second up to burst; a request takes as many tokens as its
+// weight and waits until they are available.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// pausedUntil holds every request back after the exchange answered
+	// with a Retry-After.
+	pausedUntil time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait takes weight tokens, blocking until they are available. It fails
+// right away when they would only be available after the deadline of ctx.
+func (b *tokenBucket) wait(ctx context.Context, weight int) error {
+	cost := float64(weight)
+	if cost > b.burst {
+		cost = b.burst
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// Tokens are taken up front, possibly going negative, so concurrent
+	// requests queue behind each other instead of racing for the refill.
+	b.tokens -= cost
+	delay := time.Duration(0)
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	if paused := b.pausedUntil.Sub(now); paused > delay {
+		delay = paused
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		b.tokens += cost
+		b.mu.Unlock()
+		return fmt.Errorf("%w for %s", errRateLimited, delay.Round(time.Millisecond))
+	}
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens += cost
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// pause holds every request back for d.
+func (b *tokenBucket) pause(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := time.Now().Add(d); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
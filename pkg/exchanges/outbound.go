@@ -0,0 +1,187 @@
+package exchanges
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/metrics"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrUnavailable is returned when an exchange answers a request with a
+// server error or a rate limit.
+var ErrUnavailable = errors.New("exchange unavailable")
+
+// outbound sends the requests to one exchange. It bounds how many are in
+// flight, spends the exchange's request weight through a token bucket,
+// retries failures with jittered exponential backoff and stops sending
+// requests while the exchange keeps failing.
+type outbound struct {
+	exchange string
+	http     *http.Client
+
+	slots   chan struct{}
+	bucket  *tokenBucket
+	breaker *breaker
+
+	maxRetries   int
+	retryBackoff time.Duration
+	maxRetryWait time.Duration
+}
+
+func newOutbound(exchange string, client *http.Client, cfg config.OutboundConfig) *outbound {
+	return &outbound{
+		exchange:     exchange,
+		http:         client,
+		slots:        make(chan struct{}, cfg.MaxConcurrency),
+		bucket:       newTokenBucket(cfg.RateLimit, cfg.Burst),
+		breaker:      newBreaker(exchange, cfg.BreakerThreshold, cfg.BreakerCooldown),
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+		maxRetryWait: cfg.MaxRetryWait,
+	}
+}
+
+// get requests url, which costs weight on the exchange. Network errors, 429,
+// 418 and 5xx responses are retried; the last response is returned as is,
+// so callers still check its status.
+func (o *outbound) get(ctx context.Context, url string, weight int) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := o.attempt(ctx, url, weight)
+		if attempt == o.maxRetries || !retryable(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay := o.backoff(attempt)
+		if resp != nil {
+			if wait, ok := retryAfter(resp); ok {
+				if wait > o.maxRetryWait {
+					return resp, nil
+				}
+				delay = wait
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		metrics.ExchangeRetried(o.exchange)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt sends a single request once the breaker, a concurrency slot and
+// the rate limit allow it.
+func (o *outbound) attempt(ctx context.Context, url string, weight int) (*http.Response, error) {
+	if err := o.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	select {
+	case o.slots <- struct{}{}:
+	case <-ctx.Done():
+		o.breaker.abort()
+		return nil, ctx.Err()
+	}
+	defer func() { <-o.slots }()
+
+	if err := o.bucket.wait(ctx, weight); err != nil {
+		o.breaker.abort()
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		o.breaker.abort()
+		return nil, err
+	}
+	resp, err := o.http.Do(req)
+	switch {
+	case errors.Is(err, context.Canceled):
+		o.breaker.abort()
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		o.breaker.failure()
+	default:
+		// A rate-limited exchange is up; the bucket backs off instead.
+		o.breaker.success()
+	}
+	if err == nil && rateLimited(resp) {
+		if wait, ok := retryAfter(resp); ok {
+			o.bucket.pause(wait)
+		}
+	}
+	return resp, err
+}
+
+// backoff returns the delay before retry attempt+1: the base delay doubled
+// for every earlier retry, with full jitter on its upper half.
+func (o *outbound) backoff(attempt int) time.Duration {
+	d := o.retryBackoff << attempt
+	if d <= 0 || d > o.maxRetryWait {
+		d = o.maxRetryWait
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (o *outbound) circuit() CircuitStatus {
+	return o.breaker.status()
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// Open circuits and rate limits that outlast the deadline are not
+		// worth retrying within the same call.
+		return !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, context.Canceled) && !errors.Is(err, errRateLimited)
+	}
+	return rateLimited(resp) || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// Unavailable reports whether err is a failure of the exchange as a whole,
+// such as an open circuit, a rate limit, a server error, a timeout or a
+// network error, rather than of the symbol requested.
+func Unavailable(err error) bool {
+	var urlErr *url.Error
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, errRateLimited) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.As(err, &urlErr)
+}
+
+// rateLimited reports a 429, or the 418 Binance answers once an IP keeps
+// ignoring 429s.
+func rateLimited(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot
+}
+
+// retryAfter parses the Retry-After header of resp, given either in seconds
+// or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,175 @@
+package exchanges
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "missing"},
+		{name: "seconds", value: "3", want: 3 * time.Second, wantOK: true},
+		{name: "zero", value: "0", wantOK: true},
+		{name: "negative", value: "-1"},
+		{name: "past date", value: "Mon, 02 Jan 2006 15:04:05 GMT", wantOK: true},
+		{name: "invalid", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.value != "" {
+				resp.Header.Set("Retry-After", tt.value)
+			}
+			got, ok := retryAfter(resp)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if got, ok := retryAfter(resp); !ok || got <= 50*time.Second || got > time.Minute {
+		t.Errorf("retryAfter of a date a minute away = %v, %v", got, ok)
+	}
+}
+
+func TestOutboundRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		// responses are answered in order, the last one repeatedly.
+		responses    []int
+		retryAfter   string
+		wantStatus   int
+		wantRequests int32
+	}{
+		{name: "success", responses: []int{200}, wantStatus: 200, wantRequests: 1},
+		{name: "client error", responses: []int{404}, wantStatus: 404, wantRequests: 1},
+		{name: "server error", responses: []int{503, 200}, wantStatus: 200, wantRequests: 2},
+		{name: "retries exhausted", responses: []int{500}, wantStatus: 500, wantRequests: 3},
+		{name: "rate limited", responses: []int{429, 200}, retryAfter: "0", wantStatus: 200, wantRequests: 2},
+		{name: "retry after past max wait", responses: []int{429, 200}, retryAfter: "60", wantStatus: 429, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1))
+				status := tt.responses[min(n, len(tt.responses))-1]
+				if tt.retryAfter != "" && status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			o := newOutbound("test", server.Client(), config.OutboundConfig{
+				MaxConcurrency:   1,
+				RateLimit:        1000,
+				Burst:            10,
+				MaxRetries:       2,
+				RetryBackoff:     time.Millisecond,
+				MaxRetryWait:     time.Second,
+				BreakerThreshold: 10,
+				BreakerCooldown:  time.Minute,
+			})
+			resp, err := o.get(context.Background(), server.URL, 1)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus || requests.Load() != tt.wantRequests {
+				t.Errorf("status %d after %d requests, want %d after %d", resp.StatusCode, requests.Load(), tt.wantStatus, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestOutboundPausesOnRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	o := newOutbound("test", server.Client(), config.OutboundConfig{
+		MaxConcurrency: 1, RateLimit: 1000, Burst: 10,
+		MaxRetryWait: time.Second, BreakerThreshold: 10, BreakerCooldown: time.Minute,
+	})
+	resp, err := o.get(context.Background(), server.URL, 1)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+
+	// The exchange asked for a minute, longer than any request deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := o.get(ctx, server.URL, 1); !errors.Is(err, errRateLimited) {
+		t.Errorf("get during Retry-After = %v, want errRateLimited", err)
+	}
+}
+
+func TestOutboundOpensCircuit(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	o := newOutbound("test", server.Client(), config.OutboundConfig{
+		MaxConcurrency: 1, RateLimit: 1000, Burst: 10,
+		MaxRetryWait: time.Second, BreakerThreshold: 2, BreakerCooldown: time.Minute,
+	})
+	for i := 0; i < 2; i++ {
+		resp, err := o.get(context.Background(), server.URL, 1)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := o.get(context.Background(), server.URL, 1); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("get with open circuit = %v, want ErrCircuitOpen", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("exchange received %d requests, want 2", requests.Load())
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil"},
+		{name: "circuit open", err: fmt.Errorf("failed to fetch BTC: %w", ErrCircuitOpen), want: true},
+		{name: "rate limited", err: fmt.Errorf("%w for 1s", errRateLimited), want: true},
+		{name: "server error", err: fmt.Errorf("%w: HTTP 503 for BTC", ErrUnavailable), want: true},
+		{name: "timeout", err: context.DeadlineExceeded, want: true},
+		{name: "network", err: &url.Error{Op: "Get", URL: "https://api.kucoin.com", Err: errors.New("connection refused")}, want: true},
+		{name: "no price", err: errors.New("no KuCoin price for LUNA")},
+		{name: "client error", err: errors.New("failed to retrieve price for LUNA: HTTP 400")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unavailable(tt.err); got != tt.want {
+				t.Errorf("Unavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
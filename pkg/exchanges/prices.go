@@ -0,0 +1,58 @@
+package exchanges
+
+// PriceResult is the USDT price fetched for one symbol, or the reason it
+// could not be fetched.
+type PriceResult struct {
+	// Symbol is the symbol as requested, e.g. "BTC", and Market the key
+	// its price is stored under, e.g. "BTCUSDT".
+	Symbol string
+	Market string
+	Price  float64
+	// Ticker holds the 24h market data when the exchange returned it
+	// along with the price.
+	Ticker *Ticker
+	Err    error
+}
+
+// Ticker is the 24h market data of a symbol, in its quote currency.
+// Missing values are 0.
+type Ticker struct {
+	Bid  float64
+	Ask  float64
+	High float64
+	Low  float64
+	// Volume is the traded volume in the base currency and QuoteVolume in
+	// the quote currency.
+	Volume      float64
+	QuoteVolume float64
+	// Change is the price change and ChangeRate the same as a fraction of
+	// the opening price, e.g. 0.0123 for +1.23%.
+	Change     float64
+	ChangeRate float64
+}
+
+// PriceResults are the results of a multi-symbol fetch, one per requested
+// symbol.
+type PriceResults []PriceResult
+
+// Prices returns the fetched prices keyed by market.
+func (r PriceResults) Prices() map[string]float64 {
+	prices := make(map[string]float64, len(r))
+	for _, result := range r {
+		if result.Err == nil {
+			prices[result.Market] = result.Price
+		}
+	}
+	return prices
+}
+
+// Failed returns the results of the symbols that could not be fetched.
+func (r PriceResults) Failed() PriceResults {
+	var failed PriceResults
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
@@ -1,6 +0,0 @@
-package exchanges
-
-
-func GetCryptoPrice(source string, base string, quote string) (float64, error){
-	return 0.1, nil
-}
\ No newline at end of file
@@ -0,0 +1,49 @@
+package exchanges
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Exchanges prices can be ingested from.
+const (
+	Binance = "binance"
+	KuCoin  = "kucoin"
+)
+
+// ErrUnsupportedExchange is returned for exchanges without an adapter.
+var ErrUnsupportedExchange = fmt.Errorf("unsupported exchange")
+
+// USDTMarket returns the exchange's name for the symbol/USDT market.
+func USDTMarket(exchange, symbol string) (string, error) {
+	switch strings.ToLower(exchange) {
+	case Binance:
+		return symbol + "USDT", nil
+	case KuCoin:
+		return symbol + "-USDT", nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedExchange, exchange)
+	}
+}
+
+// HasUSDTMarket reports whether exchange currently lists a symbol/USDT market.
+func (c *Client) HasUSDTMarket(ctx context.Context, exchange, symbol string) (bool, error) {
+	market, err := USDTMarket(exchange, symbol)
+	if err != nil {
+		return false, err
+	}
+
+	var symbols map[string]bool
+	switch strings.ToLower(exchange) {
+	case Binance:
+		symbols, err = c.GetBinanceSymbols(ctx)
+	case KuCoin:
+		symbols, err = c.GetKucoinSymbols(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return symbols[market], nil
+}
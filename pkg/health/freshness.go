@@ -0,0 +1,237 @@
+package health
+
+import (
+	"context"
+	"crypto_price/pkg/api"
+	"crypto_price/pkg/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TierMissing marks a symbol without a stored price in the freshness report.
+const TierMissing = "missing"
+
+// FreshnessReport lists the age of every tracked price and USDT/IRR rate.
+type FreshnessReport struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Summary   FreshnessSummary   `json:"summary"`
+	Symbols   []SymbolFreshness  `json:"symbols"`
+	UsdtIrr   []UsdtIrrFreshness `json:"usdt_irr"`
+	// Error explains why the report is incomplete, e.g. when the tracked
+	// symbols could not be loaded.
+	Error string `json:"error,omitempty"`
+}
+
+// FreshnessSummary counts the entries of a FreshnessReport.
+type FreshnessSummary struct {
+	Entries int `json:"entries"`
+	Short   int `json:"short"`
+	Long    int `json:"long"`
+	Missing int `json:"missing"`
+	// Stale counts prices older than thresholds.price_freshness, missing
+	// ones excluded.
+	Stale int `json:"stale"`
+
+	UsdtIrrSources      int `json:"usdt_irr_sources"`
+	UsdtIrrInsufficient int `json:"usdt_irr_insufficient"`
+}
+
+// SymbolFreshness is the latest stored price of a tracked symbol on a source.
+type SymbolFreshness struct {
+	Symbol string `json:"symbol"`
+	Source string `json:"source"`
+	// Tier is the store tier the price was read from: short, long or
+	// missing.
+	Tier       string     `json:"tier"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	AgeSeconds float64    `json:"age_seconds,omitempty"`
+	Stale      bool       `json:"stale"`
+}
+
+// UsdtIrrFreshness is the latest stored USDT/IRR rate of a source.
+type UsdtIrrFreshness struct {
+	Source     string     `json:"source"`
+	ComputedAt *time.Time `json:"computed_at,omitempty"`
+	AgeSeconds float64    `json:"age_seconds,omitempty"`
+	Trades     int        `json:"trades"`
+	// Insufficient is set when the rate is missing or was computed from
+	// fewer than health.min_usdt_irr_trades trades.
+	Insufficient bool   `json:"insufficient"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// freshnessReport reads every tracked price and USDT/IRR rate from the
+// store.
+func (c *Checker) freshnessReport(ctx context.Context, s settings) FreshnessReport {
+	now := time.Now()
+	report := FreshnessReport{
+		Timestamp: now,
+		Symbols:   []SymbolFreshness{},
+		UsdtIrr:   []UsdtIrrFreshness{},
+	}
+	var errs []error
+
+	// Rates are read first: they only need the price store, while loading
+	// the tracked symbols may wait on MongoDB.
+	for _, source := range s.usdtIrrSources {
+		report.UsdtIrr = append(report.UsdtIrr, c.usdtIrrFreshness(ctx, s, source, now))
+	}
+
+	sources := []string{"kucoin"}
+	if s.binanceEnabled {
+		sources = append(sources, "binance")
+	}
+	symbols, err := c.ingestion.TrackedSymbols(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to load tracked symbols: %w", err))
+	}
+	for _, source := range sources {
+		entries, err := c.symbolFreshness(ctx, s, source, symbols[source], now)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		report.Symbols = append(report.Symbols, entries...)
+	}
+
+	report.Summary = summarize(report)
+	if err := errors.Join(errs...); err != nil {
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// symbolFreshness reads the prices of the symbols tracked on source. Each
+// exchange stores prices under its own symbol, e.g. "XBTUSDT" on KuCoin and
+// "BTCUSDT" on Binance.
+func (c *Checker) symbolFreshness(ctx context.Context, s settings, source string, symbols []string, now time.Time) ([]SymbolFreshness, error) {
+	keys := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		keys[i] = symbol + "USDT"
+	}
+	points, err := c.store.GetPrices(ctx, source, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s prices: %w", source, err)
+	}
+
+	entries := make([]SymbolFreshness, 0, len(keys))
+	for _, key := range keys {
+		entry := SymbolFreshness{Symbol: key, Source: source, Tier: TierMissing}
+		if point, ok := points[key]; ok {
+			updated := point.Timestamp
+			age := now.Sub(updated)
+			entry.Tier = point.Tier
+			entry.UpdatedAt = &updated
+			entry.AgeSeconds = age.Seconds()
+			entry.Stale = age > s.priceFreshness
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (c *Checker) usdtIrrFreshness(ctx context.Context, s settings, source string, now time.Time) UsdtIrrFreshness {
+	entry := UsdtIrrFreshness{Source: source}
+	result, err := c.store.GetUsdtIrr(ctx, source)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		entry.Insufficient = true
+		entry.Reason = "no rate stored"
+		return entry
+	case err != nil:
+		entry.Insufficient = true
+		entry.Reason = err.Error()
+		return entry
+	}
+
+	entry.Trades = result.Trades
+	computed := result.ComputedAt
+	entry.ComputedAt = &computed
+	entry.AgeSeconds = now.Sub(computed).Seconds()
+	if result.Trades < s.minUsdtIrrTrades {
+		entry.Insufficient = true
+		entry.Reason = fmt.Sprintf("computed from %d trades, %d required", result.Trades, s.minUsdtIrrTrades)
+	}
+	return entry
+}
+
+func summarize(report FreshnessReport) FreshnessSummary {
+	summary := FreshnessSummary{
+		Entries:        len(report.Symbols),
+		UsdtIrrSources: len(report.UsdtIrr),
+	}
+	for _, entry := range report.Symbols {
+		switch entry.Tier {
+		case store.TierShort:
+			summary.Short++
+		case store.TierLong:
+			summary.Long++
+		case TierMissing:
+			summary.Missing++
+		}
+		if entry.Stale {
+			summary.Stale++
+		}
+	}
+	for _, entry := range report.UsdtIrr {
+		if entry.Insufficient {
+			summary.UsdtIrrInsufficient++
+		}
+	}
+	return summary
+}
+
+// checkPrices builds the freshness report and keeps it for
+// HandleFreshness. The check fails when no tracked price is fresh or no
+// USDT/IRR source is sufficient, and is degraded when any entry is missing,
+// stale or insufficient.
+func (c *Checker) checkPrices(ctx context.Context) HealthCheck {
+	s, _ := c.current()
+	report := c.freshnessReport(ctx, s)
+	c.mu.Lock()
+	c.freshness = &report
+	c.mu.Unlock()
+
+	check := HealthCheck{
+		Name:      CheckPrices,
+		Timestamp: time.Now(),
+		Status:    StatusHealthy,
+	}
+	summary := report.Summary
+	fresh := summary.Entries - summary.Missing - summary.Stale
+	sufficient := summary.UsdtIrrSources - summary.UsdtIrrInsufficient
+	message := fmt.Sprintf("%d of %d prices fresh, %d of %d USDT/IRR sources sufficient",
+		fresh, summary.Entries, sufficient, summary.UsdtIrrSources)
+
+	switch {
+	case report.Error != "":
+		check.Status = StatusUnhealthy
+		message = report.Error
+	case (summary.Entries > 0 && fresh == 0) || (summary.UsdtIrrSources > 0 && sufficient == 0):
+		check.Status = StatusUnhealthy
+	case fresh < summary.Entries || sufficient < summary.UsdtIrrSources:
+		check.Status = StatusDegraded
+	}
+	check.Message = message
+	return check
+}
+
+// HandleFreshness serves the freshness report of the latest health check
+// run.
+func (c *Checker) HandleFreshness(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	report := c.freshness
+	c.mu.RUnlock()
+	if report == nil {
+		c.refresh(r.Context())
+		c.mu.RLock()
+		report = c.freshness
+		c.mu.RUnlock()
+	}
+	if report == nil {
+		api.WriteError(w, http.StatusServiceUnavailable, api.CodeUnavailable, "freshness report not available yet", nil)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, report)
+}
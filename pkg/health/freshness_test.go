@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/store"
+	"testing"
+	"time"
+)
+
+type fakeIngestion map[string][]string
+
+func (fakeIngestion) LastWrite(string) time.Time { return time.Time{} }
+
+func (f fakeIngestion) TrackedSymbols(context.Context) (map[string][]string, error) {
+	return f, nil
+}
+
+func TestFreshnessUsesExchangeSymbols(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.Jobs.BinanceEnabled = true
+	cfg.Jobs.UsdtIrrSources = nil
+
+	ps := store.NewMemoryStore(store.Expirations{ShortTerm: time.Minute, LongTerm: time.Hour, UsdtIrr: time.Hour})
+	now := time.Now()
+	if err := ps.PutPrices(ctx, "kucoin", map[string]float64{"XBTUSDT": 60000}, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.PutPrices(ctx, "binance", map[string]float64{"BTCUSDT": 60000}, nil, now); err != nil {
+		t.Fatal(err)
+	}
+
+	ingestion := fakeIngestion{"kucoin": {"XBT"}, "binance": {"BTC", "ETH"}}
+	c := NewChecker(cfg, ps, nil, nil, ingestion)
+	report := c.freshnessReport(ctx, c.settings)
+	if report.Error != "" {
+		t.Fatalf("report error: %s", report.Error)
+	}
+
+	got := make(map[string]string)
+	for _, entry := range report.Symbols {
+		got[entry.Source+":"+entry.Symbol] = entry.Tier
+	}
+	want := map[string]string{
+		"kucoin:XBTUSDT":  store.TierShort,
+		"binance:BTCUSDT": store.TierShort,
+		"binance:ETHUSDT": TierMissing,
+	}
+	if len(got) != len(want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	for key, tier := range want {
+		if got[key] != tier {
+			t.Errorf("%s tier = %q, want %q", key, got[key], tier)
+		}
+	}
+}
@@ -2,13 +2,16 @@ package health
 
 import (
 	"context"
+	"crypto_price/pkg/api"
 	"crypto_price/pkg/config"
 	"crypto_price/pkg/db"
 	"crypto_price/pkg/exchanges"
-	"encoding/json"
+	"crypto_price/pkg/store"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"runtime"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -22,12 +25,29 @@ const (
 	StatusUnhealthy HealthStatus = "unhealthy"
 )
 
+// Check names, as listed in health.critical.
+const (
+	CheckPriceStore  = "price_store"
+	CheckMongoDB     = "mongodb"
+	CheckKuCoin      = "kucoin"
+	CheckBinance     = "binance"
+	CheckKucoinData  = "kucoin_data"
+	CheckBinanceData = "binance_data"
+	CheckUsdtIrrData = "usdt_irr_data"
+	CheckPrices      = "prices"
+)
+
 type HealthCheck struct {
 	Name         string        `json:"name"`
 	Status       HealthStatus  `json:"status"`
 	Message      string        `json:"message,omitempty"`
 	ResponseTime time.Duration `json:"response_time"`
 	Timestamp    time.Time     `json:"timestamp"`
+	// Critical checks make the service unhealthy when they fail; the others
+	// only degrade it.
+	Critical bool `json:"critical"`
+	// Circuit is the circuit breaker state of the exchange checked.
+	Circuit *exchanges.CircuitStatus `json:"circuit,omitempty"`
 }
 
 type HealthResponse struct {
@@ -41,10 +61,11 @@ type HealthResponse struct {
 }
 
 type ServiceHealth struct {
-	Redis   HealthCheck `json:"redis"`
-	MongoDB HealthCheck `json:"mongodb"`
-	Binance HealthCheck `json:"binance"`
-	KuCoin  HealthCheck `json:"kucoin"`
+	PriceStore HealthCheck `json:"price_store"`
+	MongoDB    HealthCheck `json:"mongodb"`
+	// Binance is only checked while Binance ingestion is enabled.
+	Binance *HealthCheck `json:"binance,omitempty"`
+	KuCoin  HealthCheck  `json:"kucoin"`
 }
 
 type SystemHealth struct {
@@ -63,90 +84,239 @@ type MemoryStats struct {
 
 var startTime = time.Now()
 
-func CheckHealth(ctx context.Context) HealthResponse {
-	response := HealthResponse{
-		Status:    StatusHealthy,
-		Timestamp: time.Now(),
-		Uptime:    time.Since(startTime),
-		Version:   "1.0.0", 
-		Checks:    []HealthCheck{},
+// Ingestion reports what the ingestion jobs track and store.
+type Ingestion interface {
+	// LastWrite returns when data of source (kucoin, binance or usdt_irr)
+	// was last stored, or the zero time.
+	LastWrite(source string) time.Time
+	// TrackedSymbols returns the symbols tracked on each exchange, keyed by
+	// exchange, e.g. {"binance": ["BTC"]}.
+	TrackedSymbols(ctx context.Context) (map[string][]string, error)
+}
+
+// Checker reports the health of the service and its dependencies. The
+// checks run in the background (see Run); requests are answered from their
+// latest results, so probes never wait on a slow dependency.
+type Checker struct {
+	cfg       *config.Config
+	store     store.PriceStore
+	mongo     *db.Mongo
+	exchanges *exchanges.Client
+	ingestion Ingestion
+
+	mu        sync.RWMutex
+	settings  settings
+	results   []HealthCheck
+	freshness *FreshnessReport
+}
+
+// settings are the reloadable parts of the configuration used by the checks.
+type settings struct {
+	critical         map[string]bool
+	maxPriceAge      time.Duration
+	maxUsdtIrrAge    time.Duration
+	minUsdtIrrTrades int
+	priceFreshness   time.Duration
+	binanceEnabled   bool
+	usdtIrrSources   []string
+}
+
+func settingsFromConfig(cfg *config.Config) settings {
+	critical := make(map[string]bool, len(cfg.Health.Critical))
+	for _, name := range cfg.Health.Critical {
+		critical[name] = true
 	}
+	return settings{
+		critical:         critical,
+		maxPriceAge:      cfg.Health.MaxPriceAge,
+		maxUsdtIrrAge:    cfg.Health.MaxUsdtIrrAge,
+		minUsdtIrrTrades: cfg.Health.MinUsdtIrrTrades,
+		priceFreshness:   cfg.Thresholds.PriceFreshness,
+		binanceEnabled:   cfg.Jobs.BinanceEnabled,
+		usdtIrrSources:   cfg.Jobs.UsdtIrrSources,
+	}
+}
 
-	response.Services = checkServices(ctx)
-	response.System = checkSystem()
+// NewChecker returns a Checker for the given dependencies.
+func NewChecker(cfg *config.Config, ps store.PriceStore, mongo *db.Mongo, exchangeClient *exchanges.Client, ingestion Ingestion) *Checker {
+	return &Checker{
+		cfg:       cfg,
+		store:     ps,
+		mongo:     mongo,
+		exchanges: exchangeClient,
+		ingestion: ingestion,
+		settings:  settingsFromConfig(cfg),
+	}
+}
 
-	overallStatus := determineOverallStatus(response.Services)
-	response.Status = overallStatus
+// Reconfigure applies the reloadable health and job settings of cfg. They
+// take effect on the next check, criticality immediately.
+func (c *Checker) Reconfigure(cfg *config.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settings = settingsFromConfig(cfg)
+}
 
-	return response
+func (c *Checker) current() (settings, []HealthCheck) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings, c.results
 }
 
-func checkServices(ctx context.Context) ServiceHealth {
-	return ServiceHealth{
-		Redis:   checkRedis(ctx),
-		MongoDB: checkMongoDB(ctx),
-		Binance: checkBinance(ctx),
-		KuCoin:  checkKuCoin(ctx),
+// Run checks the dependencies every health.interval until ctx is done.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Health.Interval)
+	defer ticker.Stop()
+	for {
+		c.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
-func checkRedis(ctx context.Context) HealthCheck {
-	check := HealthCheck{
-		Name:      "redis",
+// refresh runs every check concurrently and stores the results, logging the
+// checks whose status changed.
+func (c *Checker) refresh(ctx context.Context) []HealthCheck {
+	s, previous := c.current()
+
+	checks := []func(context.Context) HealthCheck{
+		c.checkPriceStore,
+		c.checkMongoDB,
+		c.checkKuCoin,
+		c.checkWrites(CheckKucoinData, "kucoin", "KuCoin", s.maxPriceAge),
+		c.checkWrites(CheckUsdtIrrData, "usdt_irr", "USDT/IRR", s.maxUsdtIrrAge),
+		c.checkPrices,
+	}
+	if s.binanceEnabled {
+		checks = append(checks, c.checkBinance, c.checkWrites(CheckBinanceData, "binance", "Binance", s.maxPriceAge))
+	}
+
+	results := make([]HealthCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check func(context.Context) HealthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.cfg.Health.Timeout)
+			defer cancel()
+			start := time.Now()
+			results[i] = check(checkCtx)
+			results[i].ResponseTime = time.Since(start)
+		}(i, check)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		// Checks cut short by shutdown say nothing about the dependencies.
+		return previous
+	}
+	logChanges(ctx, previous, results)
+
+	c.mu.Lock()
+	c.results = results
+	c.mu.Unlock()
+	return results
+}
+
+func logChanges(ctx context.Context, previous, results []HealthCheck) {
+	was := make(map[string]HealthStatus, len(previous))
+	for _, check := range previous {
+		was[check.Name] = check.Status
+	}
+	for _, check := range results {
+		before, ok := was[check.Name]
+		switch {
+		case check.Status == before || (!ok && check.Status == StatusHealthy):
+		case check.Status == StatusHealthy:
+			slog.InfoContext(ctx, "Health check recovered", "check", check.Name, "message", check.Message)
+		default:
+			slog.WarnContext(ctx, "Health check failing", "check", check.Name, "status", check.Status, "message", check.Message)
+		}
+	}
+}
+
+// checks returns the latest results, marked with their criticality. Until
+// the first background run completes, the checks are run inline.
+func (c *Checker) checks(ctx context.Context) []HealthCheck {
+	s, results := c.current()
+	if results == nil {
+		results = c.refresh(ctx)
+	}
+
+	out := make([]HealthCheck, len(results))
+	for i, check := range results {
+		check.Critical = s.critical[check.Name]
+		out[i] = check
+	}
+	return out
+}
+
+func (c *Checker) CheckHealth(ctx context.Context) HealthResponse {
+	checks := c.checks(ctx)
+	response := HealthResponse{
+		Status:    overallStatus(checks),
 		Timestamp: time.Now(),
+		Uptime:    time.Since(startTime),
+		Version:   "1.0.0",
+		Checks:    checks,
+		System:    checkSystem(),
 	}
 
-	start := time.Now()
-	defer func() { check.ResponseTime = time.Since(start) }()
+	for i, check := range checks {
+		switch check.Name {
+		case CheckPriceStore:
+			response.Services.PriceStore = check
+		case CheckMongoDB:
+			response.Services.MongoDB = check
+		case CheckKuCoin:
+			response.Services.KuCoin = check
+		case CheckBinance:
+			response.Services.Binance = &checks[i]
+		}
+	}
+	return response
+}
 
-	client, err := db.GetRedisClient()
-	if err != nil {
-		check.Status = StatusUnhealthy
-		check.Message = fmt.Sprintf("failed to get Redis client: %v", err)
-		return check
+func (c *Checker) checkPriceStore(ctx context.Context) HealthCheck {
+	check := HealthCheck{
+		Name:      CheckPriceStore,
+		Timestamp: time.Now(),
 	}
 
-	_, err = client.Ping(ctx).Result()
-	if err != nil {
+	if err := c.store.Ping(ctx); err != nil {
 		check.Status = StatusUnhealthy
-		check.Message = fmt.Sprintf("Redis ping failed: %v", err)
+		check.Message = fmt.Sprintf("%s price store ping failed: %v", c.store.Name(), err)
 		return check
 	}
 
 	check.Status = StatusHealthy
-	check.Message = "Redis connection successful"
+	check.Message = fmt.Sprintf("%s price store reachable", c.store.Name())
 	return check
 }
 
-func checkMongoDB(ctx context.Context) HealthCheck {
+func (c *Checker) checkMongoDB(ctx context.Context) HealthCheck {
 	check := HealthCheck{
-		Name:      "mongodb",
+		Name:      CheckMongoDB,
 		Timestamp: time.Now(),
 	}
 
-	start := time.Now()
-	defer func() { check.ResponseTime = time.Since(start) }()
-
-	client, err := db.GetMongoClient()
-	if err != nil {
+	if err := c.mongo.Ping(ctx); err != nil {
 		check.Status = StatusUnhealthy
-		check.Message = fmt.Sprintf("failed to get MongoDB client: %v", err)
+		check.Message = fmt.Sprintf("MongoDB ping failed: %v", err)
 		return check
 	}
 
-	err = client.Ping(ctx, nil)
+	collection, err := c.mongo.Collection(ctx, c.cfg.Mongo.ConfigCollection)
 	if err != nil {
 		check.Status = StatusUnhealthy
-		check.Message = fmt.Sprintf("MongoDB ping failed: %v", err)
+		check.Message = err.Error()
 		return check
 	}
 
-	cfg := config.GetConfigs()
-	db := client.Database(cfg.MarketDatabase)
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	count, err := db.Collection(cfg.ConfigCollection).CountDocuments(ctx, bson.M{})
+	count, err := collection.CountDocuments(ctx, bson.M{})
 	if err != nil {
 		check.Status = StatusDegraded
 		check.Message = fmt.Sprintf("MongoDB query failed: %v", err)
@@ -158,61 +328,73 @@ func checkMongoDB(ctx context.Context) HealthCheck {
 	return check
 }
 
-func checkBinance(ctx context.Context) HealthCheck {
+func (c *Checker) checkBinance(ctx context.Context) HealthCheck {
 	check := HealthCheck{
-		Name:      "binance",
+		Name:      CheckBinance,
 		Timestamp: time.Now(),
 	}
 
-	start := time.Now()
-	defer func() { check.ResponseTime = time.Since(start) }()
-
-	prices, err := exchanges.GetAllBinancePrices()
+	err := c.exchanges.PingBinance(ctx)
+	circuit := c.exchanges.Circuit(exchanges.Binance)
+	check.Circuit = &circuit
 	if err != nil {
 		check.Status = StatusUnhealthy
 		check.Message = fmt.Sprintf("Binance API check failed: %v", err)
 		return check
 	}
 
-	if len(prices) == 0 {
-		check.Status = StatusDegraded
-		check.Message = "Binance API returned no price data"
-		return check
-	}
-
 	check.Status = StatusHealthy
-	check.Message = fmt.Sprintf("Binance API healthy, returned %d prices", len(prices))
+	check.Message = "Binance API reachable"
 	return check
 }
 
-func checkKuCoin(ctx context.Context) HealthCheck {
+func (c *Checker) checkKuCoin(ctx context.Context) HealthCheck {
 	check := HealthCheck{
-		Name:      "kucoin",
+		Name:      CheckKuCoin,
 		Timestamp: time.Now(),
 	}
 
-	start := time.Now()
-	defer func() { check.ResponseTime = time.Since(start) }()
-
-	symbols := []string{"BTC"}
-	prices, err := exchanges.GetPricesKucoin(symbols)
+	err := c.exchanges.PingKucoin(ctx)
+	circuit := c.exchanges.Circuit(exchanges.KuCoin)
+	check.Circuit = &circuit
 	if err != nil {
 		check.Status = StatusUnhealthy
 		check.Message = fmt.Sprintf("KuCoin API check failed: %v", err)
 		return check
 	}
 
-	if len(prices) == 0 {
-		check.Status = StatusDegraded
-		check.Message = "KuCoin API returned no price data"
-		return check
-	}
-
 	check.Status = StatusHealthy
-	check.Message = fmt.Sprintf("KuCoin API healthy, returned %d prices", len(prices))
+	check.Message = "KuCoin API reachable"
 	return check
 }
 
+// checkWrites returns a check that fails when the jobs have not stored data
+// of source for longer than maxAge. label names the data in messages.
+func (c *Checker) checkWrites(name, source, label string, maxAge time.Duration) func(context.Context) HealthCheck {
+	return func(context.Context) HealthCheck {
+		check := HealthCheck{
+			Name:      name,
+			Timestamp: time.Now(),
+			Status:    StatusHealthy,
+		}
+
+		last := c.ingestion.LastWrite(source)
+		switch age := time.Since(last); {
+		case last.IsZero() && time.Since(startTime) < maxAge:
+			check.Message = fmt.Sprintf("Waiting for the first %s write", label)
+		case last.IsZero():
+			check.Status = StatusUnhealthy
+			check.Message = fmt.Sprintf("No %s write since startup", label)
+		case age > maxAge:
+			check.Status = StatusUnhealthy
+			check.Message = fmt.Sprintf("No %s write in %s (last %s ago)", label, maxAge, age.Round(time.Second))
+		default:
+			check.Message = fmt.Sprintf("%s data written %s ago", label, age.Round(time.Second))
+		}
+		return check
+	}
+}
+
 func checkSystem() SystemHealth {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
@@ -230,98 +412,73 @@ func checkSystem() SystemHealth {
 	}
 }
 
-func determineOverallStatus(services ServiceHealth) HealthStatus {
-	checks := []HealthCheck{
-		services.Redis,
-		services.MongoDB,
-		services.Binance,
-		services.KuCoin,
-	}
-
-	unhealthyCount := 0
-	degradedCount := 0
-
+// overallStatus is unhealthy when a critical check fails, and degraded when
+// any other check fails or is degraded.
+func overallStatus(checks []HealthCheck) HealthStatus {
+	status := StatusHealthy
 	for _, check := range checks {
-		switch check.Status {
-		case StatusUnhealthy:
-			unhealthyCount++
-		case StatusDegraded:
-			degradedCount++
+		switch {
+		case check.Status == StatusUnhealthy && check.Critical:
+			return StatusUnhealthy
+		case check.Status != StatusHealthy:
+			status = StatusDegraded
 		}
 	}
-
-	if unhealthyCount > 0 {
-		return StatusUnhealthy
-	}
-	if degradedCount > 0 {
-		return StatusDegraded
-	}
-	return StatusHealthy
+	return status
 }
 
-func HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
+// HandleHealthCheck reports every check. It answers 503 when the service is
+// unhealthy, and 200 otherwise, degraded included.
+func (c *Checker) HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	response := c.CheckHealth(r.Context())
 
-	response := CheckHealth(ctx)
-
-	switch response.Status {
-	case StatusHealthy:
-		w.WriteHeader(http.StatusOK)
-	case StatusDegraded:
-		w.WriteHeader(http.StatusOK) 
-		w.WriteHeader(http.StatusServiceUnavailable)
+	status := http.StatusOK
+	if response.Status == StatusUnhealthy {
+		status = http.StatusServiceUnavailable
 	}
-
-	json.NewEncoder(w).Encode(response)
+	api.WriteJSON(w, status, response)
 }
 
 func HandleLiveness(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
 	response := map[string]interface{}{
 		"status":    "alive",
 		"timestamp": time.Now(),
 		"uptime":    time.Since(startTime).String(),
 	}
-
-	json.NewEncoder(w).Encode(response)
+	api.WriteJSON(w, http.StatusOK, response)
 }
 
-func HandleReadiness(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	// Check critical services for readiness
-	redisCheck := checkRedis(ctx)
-	mongoCheck := checkMongoDB(ctx)
-
-	if redisCheck.Status == StatusUnhealthy || mongoCheck.Status == StatusUnhealthy {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		response := map[string]interface{}{
-			"status": "not ready",
-			"reason": "critical services unhealthy",
-			"services": map[string]string{
-				"redis":   string(redisCheck.Status),
-				"mongodb": string(mongoCheck.Status),
-			},
+// HandleReadiness reports whether every critical check passes, along with
+// the summary of the freshness report. Stale data only fails readiness when
+// the prices check is critical.
+func (c *Checker) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	services := make(map[string]string)
+	ready := true
+	for _, check := range c.checks(r.Context()) {
+		if !check.Critical {
+			continue
+		}
+		services[check.Name] = string(check.Status)
+		if check.Status == StatusUnhealthy {
+			ready = false
 		}
-		json.NewEncoder(w).Encode(response)
-		return
 	}
 
-	w.WriteHeader(http.StatusOK)
 	response := map[string]interface{}{
-		"status": "ready",
-		"services": map[string]string{
-			"redis":   string(redisCheck.Status),
-			"mongodb": string(mongoCheck.Status),
-		},
+		"status":   "ready",
+		"services": services,
+	}
+	c.mu.RLock()
+	if c.freshness != nil {
+		response["freshness"] = c.freshness.Summary
+	}
+	c.mu.RUnlock()
+
+	if !ready {
+		response["status"] = "not ready"
+		response["reason"] = "critical services unhealthy"
+		api.WriteJSON(w, http.StatusServiceUnavailable, response)
+		return
 	}
-	json.NewEncoder(w).Encode(response)
+	api.WriteJSON(w, http.StatusOK, response)
 }
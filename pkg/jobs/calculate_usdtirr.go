@@ -3,16 +3,18 @@ package jobs
 import (
 	"context"
 	"crypto_price/pkg/config"
-	"crypto_price/pkg/db"
-	"encoding/json"
+	"crypto_price/pkg/logging"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/models"
+	"crypto_price/pkg/store"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"sort"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -21,87 +23,147 @@ type Transaction struct {
 	Amount float64 `bson:"amount"`
 }
 
-type MarketSourceResult struct {
-	MarketName   string
-	Source       string
-	Median       float64
-	WeightedMean float64
-	StdDev       float64
-	SumAmounts   float64
+// trade is a document of the last trade collection.
+type trade struct {
+	ID          interface{} `bson:"_id"`
+	MarketName  string      `bson:"market_name"`
+	Source      string      `bson:"source"`
+	Time        string      `bson:"time"`
+	Transaction `bson:",inline"`
 }
 
-const (
-	TIME_INTERVAL_TO_LOAD         = 30
-	THRESHOLD_FOR_BIG_TRANSACTION = 50
-	USDTIRR_TTL                   = 300
-)
+// tradeTimeLayout is the format of trade.Time.
+const tradeTimeLayout = "2006-01-02T15:04:05"
 
-func calculateUsdtIrrPriceJob() ([]MarketSourceResult, error) {
-	var results []MarketSourceResult
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// usdtIrrTradeLimit is the number of most recent trades a USDT/IRR rate is
+// computed from.
+const usdtIrrTradeLimit = 100
 
-	cfg := config.GetConfigs()
-	client, err := db.GetMongoClient()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get MongoDB client: %w", err)
-	}
+// exchangeClockSkew compensates for sources that record trade times in
+// Tehran local time rather than UTC.
+const exchangeClockSkew = 210 * time.Minute
 
-	_ = ctx // ctx is used implicitly in MongoDB operations
+// usdtIrrMarket describes the USDT market of a source.
+type usdtIrrMarket struct {
+	MarketName string
+	ClockSkew  time.Duration
+}
 
-	collection := client.Database(cfg.TradeDatabase).Collection(cfg.LastTradeCollection)
+// windowStart returns the time of the oldest trade considered at now.
+func windowStart(cfg config.JobsConfig, now time.Time) time.Time {
+	return now.Add(-cfg.UsdtIrrWindow)
+}
 
-	marketSources := []struct {
-		MarketName string
-		Source     string
-	}{
-		{"USDTIRT", "wallex"},
-		{"USDTIRT", "nobitex"},
-		{"USDTIRT", "bitpin"},
-		{"USDTIRR", "ramzinex"},
-	}
+// sourceTime converts t to the clock the source records trade times in.
+func (m usdtIrrMarket) sourceTime(t time.Time) time.Time {
+	return t.Add(-m.ClockSkew)
+}
 
-	for _, ms := range marketSources {
-		timeToLoadLastTrades := time.Now()
-		if ms.Source == "wallex" || ms.Source == "ramzinex" {
-			timeToLoadLastTrades = timeToLoadLastTrades.Add(-TIME_INTERVAL_TO_LOAD * time.Minute).Add(-210 * time.Minute)
-		} else {
-			timeToLoadLastTrades = timeToLoadLastTrades.Add(-TIME_INTERVAL_TO_LOAD * time.Minute)
-		}
+// usdtIrrMarkets describes the USDT market of every source in
+// config.UsdtIrrSources.
+var usdtIrrMarkets = map[string]usdtIrrMarket{
+	"wallex":   {"USDTIRT", exchangeClockSkew},
+	"nobitex":  {"USDTIRT", 0},
+	"bitpin":   {"USDTIRT", 0},
+	"ramzinex": {"USDTIRR", exchangeClockSkew},
+}
+
+func (r *Runner) calculateUsdtIrrPriceJob(ctx context.Context) ([]models.MarketSourceResult, error) {
+	var results []models.MarketSourceResult
+	cfg, _ := r.config()
+	ctx, cancel := context.WithTimeout(ctx, cfg.UsdtIrrTimeout)
+	defer cancel()
+
+	collection, err := r.mongo.LastTrades(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.ensureTradeIndex(ctx)
 
-		query := bson.M{
-			"market_name": ms.MarketName,
-			"source":      ms.Source,
-			"time":        bson.M{"$gte": timeToLoadLastTrades.Format("2006-01-02T15:04:05")},
-			"amount":      bson.M{"$gt": THRESHOLD_FOR_BIG_TRANSACTION},
+	for _, source := range cfg.UsdtIrrSources {
+		market, ok := usdtIrrMarkets[source]
+		if !ok {
+			slog.WarnContext(ctx, "Skipping unknown USDT/IRR source", "source", source)
+			continue
 		}
 
-		cursor, err := collection.Find(ctx, query, options.Find().SetLimit(100))
+		now := time.Now()
+		since := windowStart(cfg, now)
+		trades, err := loadTrades(ctx, collection, cfg, source, market, since)
 		if err != nil {
-			log.Printf("Failed to query %s:%s transactions: %v", ms.MarketName, ms.Source, err)
-			return nil, fmt.Errorf("failed to query transactions for %s:%s: %w", ms.MarketName, ms.Source, err)
+			return nil, err
 		}
-		defer cursor.Close(ctx)
 
-		var transactions []Transaction
-		if err = cursor.All(ctx, &transactions); err != nil {
-			log.Printf("Failed to decode %s:%s transactions: %v", ms.MarketName, ms.Source, err)
-			return nil, fmt.Errorf("failed to decode transactions for %s:%s: %w", ms.MarketName, ms.Source, err)
+		transactions := make([]Transaction, len(trades))
+		for i, t := range trades {
+			transactions[i] = t.Transaction
 		}
+		results = append(results, usdtIrrResult(source, market, transactions, since, now))
+	}
+	return results, nil
+}
 
-		median, weightedMean, stdDev, sumAmounts := calculateStatistics(transactions)
+// ensureTradeIndex creates the index loadTrades relies on, once per
+// process. Failing to create it, for example without the createIndex
+// privilege, only slows the query down, so it is logged and retried on the
+// next run.
+func (r *Runner) ensureTradeIndex(ctx context.Context) {
+	if r.tradeIndexed.Load() {
+		return
+	}
+	if err := r.mongo.EnsureLastTradeIndex(ctx); err != nil {
+		logging.Sampled(ctx, slog.LevelWarn, "last_trade_index", "Error creating the last trade index", "error", err)
+		return
+	}
+	r.tradeIndexed.Store(true)
+}
 
-		results = append(results, MarketSourceResult{
-			MarketName:   ms.MarketName,
-			Source:       ms.Source,
-			Median:       median,
-			WeightedMean: weightedMean,
-			StdDev:       stdDev,
-			SumAmounts:   sumAmounts,
-		})
+// loadTrades returns the most recent trades of source since since that are
+// larger than jobs.usdt_irr_min_amount, newest first. since is in real time
+// and converted to the clock of the source for the query.
+func loadTrades(ctx context.Context, collection *mongo.Collection, cfg config.JobsConfig, source string, market usdtIrrMarket, since time.Time) ([]trade, error) {
+	query := bson.M{
+		"market_name": market.MarketName,
+		"source":      source,
+		"time":        bson.M{"$gte": market.sourceTime(since).Format(tradeTimeLayout)},
+		"amount":      bson.M{"$gt": cfg.UsdtIrrMinAmount},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}}).
+		SetLimit(usdtIrrTradeLimit)
+
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions for %s:%s: %w", market.MarketName, source, err)
+	}
+	defer cursor.Close(ctx)
+
+	var trades []trade
+	if err = cursor.All(ctx, &trades); err != nil {
+		return nil, fmt.Errorf("failed to decode transactions for %s:%s: %w", market.MarketName, source, err)
+	}
+	return trades, nil
+}
+
+// usdtIrrResult computes the rate of source from transactions. It sorts
+// transactions.
+func usdtIrrResult(source string, market usdtIrrMarket, transactions []Transaction, since, now time.Time) models.MarketSourceResult {
+	median, weightedMean, stdDev, sumAmounts := calculateStatistics(transactions)
+
+	return models.MarketSourceResult{
+		MarketName:   market.MarketName,
+		Source:       source,
+		Median:       median,
+		WeightedMean: weightedMean,
+		StdDev:       stdDev,
+		SumAmounts:   sumAmounts,
+		Trades:       len(transactions),
+		ComputedAt:   now,
+		WindowStart:  since,
+		WindowEnd:    now,
 	}
-	return results, nil
 }
+
 func calculateStatistics(transactions []Transaction) (median float64, weightedMean float64, stdDev float64, sumAmounts float64) {
 	n := len(transactions)
 	if n == 0 {
@@ -139,21 +201,14 @@ func calculateStatistics(transactions []Transaction) (median float64, weightedMe
 
 	return median, weightedMean, stdDev, sumAmounts
 }
-func StoreUsdtIrrPricesInRedis(rdb *redis.Client, results []MarketSourceResult) error {
-	if rdb == nil {
-		return fmt.Errorf("redis client is nil")
-	}
 
-	ctx := context.Background()
+// StoreUsdtIrrPrices writes the computed USDT/IRR rates to the price store.
+func StoreUsdtIrrPrices(ctx context.Context, ps store.PriceStore, results []models.MarketSourceResult) error {
 	for _, result := range results {
-		key := fmt.Sprintf("usdtirr:%s", result.Source)
-		value, err := json.Marshal(result)
-		if err != nil {
-			return fmt.Errorf("failed to marshal result for %s: %w", result.Source, err)
-		}
-		if err := rdb.Set(ctx, key, value, time.Second*USDTIRR_TTL).Err(); err != nil {
-			return fmt.Errorf("failed to store USDTIRR price for %s: %w", result.Source, err)
+		if err := ps.PutUsdtIrr(ctx, result); err != nil {
+			return err
 		}
+		metrics.SetUsdtIrrRate(result.Source, result.WeightedMean)
 	}
 	return nil
 }
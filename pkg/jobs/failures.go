@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"crypto_price/pkg/exchanges"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// symbolFailuresKeyPrefix is followed by the source in the key of the Redis
+// hash holding its failing symbols, e.g. "symbol_failures:kucoin".
+const symbolFailuresKeyPrefix = "symbol_failures:"
+
+// symbolFailuresAttempts bounds how often an update conflicting with another
+// replica is tried, waiting up to symbolFailuresBackoff more before each
+// retry.
+const (
+	symbolFailuresAttempts = 10
+	symbolFailuresBackoff  = 5 * time.Millisecond
+)
+
+// symbolFailures tracks the consecutive failed fetches of the symbols of a
+// source and suppresses those failing persistently.
+//
+// The state is shared by every replica through a Redis hash mapping each
+// failing symbol to its models.SymbolFailure as JSON. Counts add up the
+// fetches of all replicas, a suppressed symbol is skipped by all of them,
+// and the admin API reads and resets the same state whichever replica
+// serves it. Suppressions survive restarts.
+type symbolFailures struct {
+	source string
+	key    string
+	rdb    redis.UniversalClient
+}
+
+func newSymbolFailures(source string, rdb redis.UniversalClient) *symbolFailures {
+	return &symbolFailures{source: source, key: symbolFailuresKeyPrefix + source, rdb: rdb}
+}
+
+// load returns the failing symbols, keyed by symbol.
+func (f *symbolFailures) load(ctx context.Context, c redis.Cmdable) (map[string]*models.SymbolFailure, error) {
+	values, err := c.HGetAll(ctx, f.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read symbol failures: %w", err)
+	}
+	entries := make(map[string]*models.SymbolFailure, len(values))
+	for symbol, value := range values {
+		var entry models.SymbolFailure
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode symbol failure of %s: %w", symbol, err)
+		}
+		entries[symbol] = &entry
+	}
+	return entries, nil
+}
+
+// active returns the symbols that are not suppressed. Every symbol is
+// returned when the failures cannot be read.
+func (f *symbolFailures) active(ctx context.Context, symbols []string) []string {
+	entries, err := f.load(ctx, f.rdb)
+	if err != nil {
+		slog.WarnContext(ctx, "Fetching every symbol, suppressions unavailable", "source", f.source, "error", err)
+		return symbols
+	}
+	active := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		if entry, ok := entries[symbol]; !ok || !entry.Suppressed {
+			active = append(active, symbol)
+		}
+	}
+	return active
+}
+
+// record updates the failure counts from results and suppresses symbols
+// that failed suppressAfter times in a row; 0 never suppresses. Failures of
+// the exchange as a whole, see exchanges.Unavailable, say nothing about the
+// symbol: they neither count nor reset its failures.
+func (f *symbolFailures) record(ctx context.Context, results exchanges.PriceResults, suppressAfter int) {
+	for attempt := 1; attempt <= symbolFailuresAttempts; attempt++ {
+		var recovered, suppressed []models.SymbolFailure
+		var entries map[string]*models.SymbolFailure
+		err := f.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			var err error
+			if entries, err = f.load(ctx, tx); err != nil {
+				return err
+			}
+			recovered, suppressed = nil, nil
+
+			now := time.Now()
+			var deleted []string
+			updated := make(map[string]interface{})
+			for _, result := range results {
+				if exchanges.Unavailable(result.Err) {
+					continue
+				}
+				entry, ok := entries[result.Symbol]
+				if result.Err == nil {
+					if ok {
+						recovered = append(recovered, *entry)
+						deleted = append(deleted, result.Symbol)
+						delete(entries, result.Symbol)
+					}
+					continue
+				}
+
+				if !ok {
+					entry = &models.SymbolFailure{Source: f.source, Symbol: result.Symbol, FirstFailedAt: now}
+					entries[result.Symbol] = entry
+				}
+				entry.ConsecutiveFailures++
+				entry.LastError = result.Err.Error()
+				entry.LastFailedAt = now
+				if suppressAfter > 0 && entry.ConsecutiveFailures >= suppressAfter && !entry.Suppressed {
+					suppressedAt := now
+					entry.Suppressed, entry.SuppressedAt = true, &suppressedAt
+					suppressed = append(suppressed, *entry)
+				}
+				data, err := json.Marshal(entry)
+				if err != nil {
+					return err
+				}
+				updated[result.Symbol] = data
+			}
+			if len(deleted) == 0 && len(updated) == 0 {
+				return nil
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if len(deleted) > 0 {
+					pipe.HDel(ctx, f.key, deleted...)
+				}
+				if len(updated) > 0 {
+					pipe.HSet(ctx, f.key, updated)
+				}
+				return nil
+			})
+			return err
+		}, f.key)
+		if errors.Is(err, redis.TxFailedErr) {
+			// Another replica recorded its results in the meantime.
+			time.Sleep(time.Duration(rand.Int63n(int64(attempt) * int64(symbolFailuresBackoff))))
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Error recording symbol failures", "source", f.source, "error", err)
+			return
+		}
+
+		for _, entry := range recovered {
+			slog.InfoContext(ctx, "Symbol recovered", "source", f.source, "symbol", entry.Symbol,
+				"failures", entry.ConsecutiveFailures)
+		}
+		for _, entry := range suppressed {
+			slog.WarnContext(ctx, "Suppressing symbol after consecutive failures", "source", f.source,
+				"symbol", entry.Symbol, "failures", entry.ConsecutiveFailures, "error", entry.LastError)
+		}
+		metrics.SetSuppressedSymbols(f.source, countSuppressed(entries))
+		return
+	}
+	slog.WarnContext(ctx, "Symbol failures not recorded, updated concurrently", "source", f.source,
+		"attempts", symbolFailuresAttempts)
+}
+
+// list returns the failing symbols, sorted.
+func (f *symbolFailures) list(ctx context.Context) ([]models.SymbolFailure, error) {
+	entries, err := f.load(ctx, f.rdb)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]models.SymbolFailure, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Symbol < list[j].Symbol })
+	return list, nil
+}
+
+// reset forgets the failures of symbol, resuming its ingestion if it was
+// suppressed. It reports whether symbol had failures.
+func (f *symbolFailures) reset(ctx context.Context, symbol string) (bool, error) {
+	removed, err := f.rdb.HDel(ctx, f.key, symbol).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to reset symbol failures: %w", err)
+	}
+	if entries, err := f.load(ctx, f.rdb); err == nil {
+		metrics.SetSuppressedSymbols(f.source, countSuppressed(entries))
+	}
+	return removed > 0, nil
+}
+
+func countSuppressed(entries map[string]*models.SymbolFailure) int {
+	count := 0
+	for _, entry := range entries {
+		if entry.Suppressed {
+			count++
+		}
+	}
+	return count
+}
+
+// SymbolFailures returns the KuCoin symbols whose latest fetches failed,
+// including the suppressed ones.
+func (r *Runner) SymbolFailures(ctx context.Context) ([]models.SymbolFailure, error) {
+	return r.kucoinFailures.list(ctx)
+}
+
+// ResetSymbol forgets the failures of a KuCoin symbol, e.g. "BTC", and
+// resumes its ingestion if it was suppressed. It reports whether the symbol
+// had failures.
+func (r *Runner) ResetSymbol(ctx context.Context, symbol string) (bool, error) {
+	return r.kucoinFailures.reset(ctx, symbol)
+}
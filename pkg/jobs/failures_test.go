@@ -0,0 +1,244 @@
+package jobs
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/exchanges"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	errDelisted = errors.New("no KuCoin price")
+	errDown     = fmt.Errorf("failed to fetch: %w", exchanges.ErrCircuitOpen)
+)
+
+// newTestFailures returns the KuCoin symbol failures of a replica sharing
+// its state through a new miniredis server.
+func newTestFailures(t *testing.T) (*symbolFailures, redis.UniversalClient) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return newSymbolFailures(JobKucoin, rdb), rdb
+}
+
+// fetchResults returns one result per symbol, failing with the error given
+// for it, if any.
+func fetchResults(symbols []string, errs map[string]error) exchanges.PriceResults {
+	results := make(exchanges.PriceResults, len(symbols))
+	for i, symbol := range symbols {
+		results[i] = exchanges.PriceResult{Symbol: symbol, Market: symbol + "USDT", Price: 1, Err: errs[symbol]}
+		if results[i].Err != nil {
+			results[i].Price = 0
+		}
+	}
+	return results
+}
+
+// failureCounts describes the failures of f as "SYMBOL:count" entries, with
+// a trailing "!" for suppressed symbols.
+func failureCounts(t *testing.T, f *symbolFailures) string {
+	t.Helper()
+	list, err := f.list(context.Background())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var counts []string
+	for _, entry := range list {
+		count := fmt.Sprintf("%s:%d", entry.Symbol, entry.ConsecutiveFailures)
+		if entry.Suppressed {
+			count += "!"
+		}
+		counts = append(counts, count)
+	}
+	return strings.Join(counts, " ")
+}
+
+func TestSymbolFailures(t *testing.T) {
+	symbols := []string{"BTC", "ETH", "LUNA"}
+	tests := []struct {
+		name string
+		// runs are the errors of consecutive fetches of symbols.
+		runs          []map[string]error
+		suppressAfter int
+		want          string
+		wantActive    string
+	}{
+		{
+			name:       "no failures",
+			runs:       []map[string]error{nil, nil},
+			wantActive: "BTC ETH LUNA",
+		},
+		{
+			name:          "below threshold",
+			runs:          []map[string]error{{"LUNA": errDelisted}, {"LUNA": errDelisted}},
+			suppressAfter: 3,
+			want:          "LUNA:2",
+			wantActive:    "BTC ETH LUNA",
+		},
+		{
+			name:          "single symbol suppressed",
+			runs:          []map[string]error{{"LUNA": errDelisted}, {"LUNA": errDelisted}, {"LUNA": errDelisted}},
+			suppressAfter: 3,
+			want:          "LUNA:3!",
+			wantActive:    "BTC ETH",
+		},
+		{
+			name:          "suppression disabled",
+			runs:          []map[string]error{{"LUNA": errDelisted}, {"LUNA": errDelisted}, {"LUNA": errDelisted}},
+			suppressAfter: 0,
+			want:          "LUNA:3",
+			wantActive:    "BTC ETH LUNA",
+		},
+		{
+			name:          "recovery resets the count",
+			runs:          []map[string]error{{"LUNA": errDelisted}, {"LUNA": errDelisted}, nil, {"LUNA": errDelisted}},
+			suppressAfter: 3,
+			want:          "LUNA:1",
+			wantActive:    "BTC ETH LUNA",
+		},
+		{
+			name: "exchange down is not counted",
+			runs: []map[string]error{
+				{"BTC": errDown, "ETH": errDown, "LUNA": errDown},
+				{"BTC": errDown, "ETH": errDown, "LUNA": errDown},
+				{"BTC": errDown, "ETH": errDown, "LUNA": errDown},
+			},
+			suppressAfter: 2,
+			wantActive:    "BTC ETH LUNA",
+		},
+		{
+			name: "exchange down does not reset the count",
+			runs: []map[string]error{
+				{"LUNA": errDelisted},
+				{"BTC": errDown, "ETH": errDown, "LUNA": errDown},
+				{"LUNA": errDelisted},
+			},
+			suppressAfter: 2,
+			want:          "LUNA:2!",
+			wantActive:    "BTC ETH",
+		},
+		{
+			name: "transport errors and server errors",
+			runs: []map[string]error{
+				{"LUNA": &url.Error{Op: "Get", URL: "https://api.kucoin.com", Err: errors.New("connection reset")}},
+				{"LUNA": fmt.Errorf("%w: HTTP 503 for LUNA", exchanges.ErrUnavailable)},
+				{"LUNA": context.DeadlineExceeded},
+			},
+			suppressAfter: 1,
+			wantActive:    "BTC ETH LUNA",
+		},
+		{
+			name:          "every symbol failing on its own",
+			runs:          []map[string]error{{"BTC": errDelisted, "ETH": errDelisted, "LUNA": errDelisted}},
+			suppressAfter: 1,
+			want:          "BTC:1! ETH:1! LUNA:1!",
+			wantActive:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := newTestFailures(t)
+			ctx := context.Background()
+			for _, errs := range tt.runs {
+				f.record(ctx, fetchResults(f.active(ctx, symbols), errs), tt.suppressAfter)
+			}
+			if got := failureCounts(t, f); got != tt.want {
+				t.Errorf("failures = %q, want %q", got, tt.want)
+			}
+			if got := strings.Join(f.active(ctx, symbols), " "); got != tt.wantActive {
+				t.Errorf("active = %q, want %q", got, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestSymbolFailuresReset(t *testing.T) {
+	f, _ := newTestFailures(t)
+	ctx := context.Background()
+	f.record(ctx, fetchResults([]string{"BTC", "LUNA"}, map[string]error{"LUNA": errDelisted}), 1)
+
+	if reset, err := f.reset(ctx, "BTC"); err != nil || reset {
+		t.Errorf("reset of a symbol without failures = %v, %v; want false", reset, err)
+	}
+	if reset, err := f.reset(ctx, "LUNA"); err != nil || !reset {
+		t.Fatalf("reset of a suppressed symbol = %v, %v; want true", reset, err)
+	}
+	if got := strings.Join(f.active(ctx, []string{"BTC", "LUNA"}), " "); got != "BTC LUNA" {
+		t.Errorf("active after reset = %q, want both symbols", got)
+	}
+	if got := failureCounts(t, f); got != "" {
+		t.Errorf("failures after reset = %q, want none", got)
+	}
+}
+
+func TestSymbolFailuresShared(t *testing.T) {
+	f, rdb := newTestFailures(t)
+	replica := newSymbolFailures(JobKucoin, rdb)
+	ctx := context.Background()
+	symbols := []string{"BTC", "LUNA"}
+
+	// Both replicas fetch every run; their failures add up.
+	var wg sync.WaitGroup
+	for _, r := range []*symbolFailures{f, replica} {
+		wg.Add(1)
+		go func(r *symbolFailures) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				r.record(ctx, fetchResults(symbols, map[string]error{"LUNA": errDelisted}), 0)
+			}
+		}(r)
+	}
+	wg.Wait()
+	if got := failureCounts(t, replica); got != "LUNA:10" {
+		t.Errorf("failures = %q, want the 10 failures of both replicas", got)
+	}
+
+	// A suppression by one replica applies to the other.
+	f.record(ctx, fetchResults(symbols, map[string]error{"LUNA": errDelisted}), 11)
+	if got := strings.Join(replica.active(ctx, symbols), " "); got != "BTC" {
+		t.Errorf("active on the other replica = %q, want BTC", got)
+	}
+	if reset, err := replica.reset(ctx, "LUNA"); err != nil || !reset {
+		t.Fatalf("reset = %v, %v", reset, err)
+	}
+	if got := strings.Join(f.active(ctx, symbols), " "); got != "BTC LUNA" {
+		t.Errorf("active after a reset on the other replica = %q, want BTC LUNA", got)
+	}
+}
+
+func TestSymbolFailuresUnavailable(t *testing.T) {
+	f, rdb := newTestFailures(t)
+	ctx := context.Background()
+	f.record(ctx, fetchResults([]string{"LUNA"}, map[string]error{"LUNA": errDelisted}), 1)
+
+	rdb.Close()
+	if got := strings.Join(f.active(ctx, []string{"BTC", "LUNA"}), " "); got != "BTC LUNA" {
+		t.Errorf("active without Redis = %q, want every symbol", got)
+	}
+	if _, err := f.list(ctx); err == nil {
+		t.Error("list without Redis succeeded")
+	}
+}
+
+func TestDroppedSymbolsForgetFailures(t *testing.T) {
+	_, rdb := newTestFailures(t)
+	r := NewRunner(config.JobsConfig{}, nil, rdb, nil, nil)
+	ctx := context.Background()
+	r.setSymbols(ctx, map[string][]string{exchanges.KuCoin: {"BTC", "ETH", "LUNA"}})
+	r.kucoinFailures.record(ctx, fetchResults([]string{"BTC", "ETH", "LUNA"},
+		map[string]error{"ETH": errDelisted, "LUNA": errDelisted}), 1)
+
+	r.setSymbols(ctx, map[string][]string{exchanges.KuCoin: {"BTC", "ETH"}})
+	if got := failureCounts(t, r.kucoinFailures); got != "ETH:1!" {
+		t.Errorf("failures after LUNA was dropped = %q, want ETH:1!", got)
+	}
+}
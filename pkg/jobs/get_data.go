@@ -1,81 +1,313 @@
 package jobs
 
 import (
+	"context"
+	"crypto_price/pkg/config"
 	"crypto_price/pkg/db"
 	"crypto_price/pkg/exchanges"
-	"log"
+	"crypto_price/pkg/logging"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/models"
+	"crypto_price/pkg/reporting"
+	"crypto_price/pkg/store"
+	"crypto_price/pkg/tracing"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Jobs reported in the crypto_price_job_* metrics and the job log attribute.
+const (
+	JobKucoin  = "kucoin"
+	JobBinance = "binance"
+	JobUsdtIrr = "usdt_irr"
+	// JobSymbols only tags the logs of the symbol watcher.
+	JobSymbols = "symbols"
 )
 
-func GetData(){
-	tickerCalculateUsdtirr := time.NewTicker(2 * time.Minute)
-	tickerKucoin := time.NewTicker(15 * time.Second)
-	// tickerBinance := time.NewTicker(15 * time.Second)
-
-
-	go func(){
-        for range tickerCalculateUsdtirr.C {
-            log.Println("Starting to calculate usdtirr")
-            results, err := calculateUsdtIrrPriceJob()
-            if err != nil {
-                log.Println("Error calculating USDTIRR price:", err)
-                continue
-            }
-
-            log.Printf("Calculated USDTIRR results: %+v", results)
-
-            // Store results in Redis using pooled connection
-            rdb, err := db.GetRedisClient()
-            if err != nil {
-                log.Println("Error getting Redis client:", err)
-                continue
-            }
-
-            if err := StoreUsdtIrrPricesInRedis(rdb, results); err != nil {
-                log.Println("Error storing USDTIRR prices in Redis:", err)
-            }
-        }
-    }()
-	
+// Runner periodically ingests exchange prices and USDT/IRR rates into the
+// price store.
+type Runner struct {
+	mongo     *db.Mongo
+	store     store.PriceStore
+	exchanges *exchanges.Client
+
+	mu  sync.Mutex
+	cfg config.JobsConfig
+	// reconfigured is closed when cfg changes, waking up the loops so a new
+	// interval applies immediately.
+	reconfigured chan struct{}
+
+	// symbols are the tracked symbols of each exchange, kept up to date by
+	// watchSymbols.
+	symbolsMu     sync.RWMutex
+	symbols       map[string][]string
+	symbolsLoaded bool
+
+	// writes holds the time of the last successful write of each source
+	// (kucoin, binance, usdt_irr).
+	writesMu sync.Mutex
+	writes   map[string]time.Time
+
+	kucoinFailures *symbolFailures
+
+	// tradeIndexed is set once the last trade index is known to exist.
+	tradeIndexed atomic.Bool
+}
+
+// NewRunner returns a Runner writing to ps on the schedule of cfg. Symbol
+// failures are shared with the other replicas through rdb.
+func NewRunner(cfg config.JobsConfig, mongo *db.Mongo, rdb redis.UniversalClient, ps store.PriceStore, exchangeClient *exchanges.Client) *Runner {
+	return &Runner{
+		mongo:        mongo,
+		store:        ps,
+		exchanges:    exchangeClient,
+		cfg:          cfg,
+		reconfigured: make(chan struct{}),
+		writes:       make(map[string]time.Time),
+
+		kucoinFailures: newSymbolFailures(JobKucoin, rdb),
+	}
+}
+
+// Reconfigure replaces the schedule and settings of the jobs. Running jobs
+// finish with the previous settings.
+func (r *Runner) Reconfigure(cfg config.JobsConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cfg = cfg
+	close(r.reconfigured)
+	r.reconfigured = make(chan struct{})
+}
+
+// LastWrite returns when the jobs last stored data of source: kucoin,
+// binance or usdt_irr. It is the zero time until the first write.
+func (r *Runner) LastWrite(source string) time.Time {
+	r.writesMu.Lock()
+	defer r.writesMu.Unlock()
+	return r.writes[source]
+}
+
+func (r *Runner) recordWrite(source string, at time.Time) {
+	r.writesMu.Lock()
+	defer r.writesMu.Unlock()
+	r.writes[source] = at
+}
+
+func (r *Runner) config() (config.JobsConfig, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg, r.reconfigured
+}
+
+// Run starts the ingestion loops and returns once ctx is done.
+func (r *Runner) Run(ctx context.Context) {
+	loops := []struct {
+		interval func(config.JobsConfig) time.Duration
+		run      func(context.Context)
+	}{
+		{func(cfg config.JobsConfig) time.Duration { return cfg.KucoinInterval }, r.job(JobKucoin, nil, r.updateKucoinPrices)},
+		{func(cfg config.JobsConfig) time.Duration { return cfg.BinanceInterval }, r.job(JobBinance, binanceEnabled, r.updateBinancePrices)},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(loops) + 2)
+	go func() {
+		defer wg.Done()
+		r.watchSymbols(ctx)
+	}()
 	go func() {
-        for range tickerKucoin.C {
-            symbols, err := db.GetKucoinSymbolsFromDB()
-            if err != nil {
-                log.Println("Error fetching symbols from DB:", err)
-                continue
-            }
-
-            prices, err := exchanges.GetPricesKucoin(symbols)
-            if err != nil {
-                log.Println("Error fetching Kucoin prices:", err)
-                continue
-            }
-
-            // Store results in Redis using pooled connection
-            rdb, err := db.GetRedisClient()
-            if err != nil {
-                log.Println("Error getting Redis client:", err)
-                continue
-            }
-
-            if err := db.StorePricesInRedis(rdb, prices, "kucoin"); err != nil {
-                log.Println("Error storing prices in Redis:", err)
-            }
-        }
-    }()
-
-    // go func() {
-    //     for range tickerBinance.C {
-    //         rdb := db.CreateRedisClient()
-    //         prices, err := exchanges.GetAllBinancePrices()
-    //         if err != nil {
-    //             log.Println("Error fetching Binance prices:", err)
-    //         } else {
-    //             if err := db.StorePricesInRedis(rdb, prices, "binance"); err != nil {
-    //                 log.Println("Error storing prices in Redis:", err)
-    //             }
-    //         }
-    //         rdb.Close()
-    //     }
-    // }()
+		defer wg.Done()
+		if cfg, _ := r.config(); cfg.UsdtIrrMode == UsdtIrrModeStream {
+			r.streamUsdtIrr(ctx)
+		} else {
+			r.every(ctx, usdtIrrInterval, r.job(JobUsdtIrr, nil, r.updateUsdtIrr))
+		}
+	}()
+	for _, loop := range loops {
+		loop := loop
+		go func() {
+			defer wg.Done()
+			r.every(ctx, loop.interval, loop.run)
+		}()
+	}
+	wg.Wait()
+}
+
+// job wraps run to record its duration and result under name, in the
+// metrics, as a Sentry transaction and as a trace, and tags its logs with a
+// new run ID. While enabled reports false the run is skipped and nothing is
+// recorded; a nil enabled means the job is always on.
+func (r *Runner) job(name string, enabled func(config.JobsConfig) bool, run func(context.Context) error) func(context.Context) {
+	return func(ctx context.Context) {
+		if cfg, _ := r.config(); enabled != nil && !enabled(cfg) {
+			return
+		}
+
+		ctx = logging.WithRun(ctx, name)
+		ctx, finish := reporting.StartJob(ctx, name)
+		ctx, span := tracing.Start(ctx, "job "+name)
+		start := time.Now()
+		err := run(ctx)
+		tracing.End(span, err)
+		finish(err)
+		metrics.ObserveJob(name, start, err)
+	}
+}
+
+func usdtIrrInterval(cfg config.JobsConfig) time.Duration {
+	return cfg.UsdtIrrInterval
+}
+
+func binanceEnabled(cfg config.JobsConfig) bool {
+	return cfg.BinanceEnabled
+}
+
+// every calls run each interval until ctx is done. The interval is read from
+// the current configuration, so a change is applied to the time remaining.
+func (r *Runner) every(ctx context.Context, interval func(config.JobsConfig) time.Duration, run func(context.Context)) {
+	last := time.Now()
+	for {
+		cfg, reconfigured := r.config()
+		timer := time.NewTimer(time.Until(last.Add(interval(cfg))))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-reconfigured:
+			timer.Stop()
+		case <-timer.C:
+			last = time.Now()
+			run(ctx)
+		}
+	}
+}
+
+// pollFor calls run each interval for d, starting immediately, or until ctx
+// is done. It stands in for event-driven updates that are unavailable.
+func (r *Runner) pollFor(ctx context.Context, d time.Duration, interval func(config.JobsConfig) time.Duration, run func(context.Context)) {
+	deadline := time.Now().Add(d)
+	for {
+		run(ctx)
+
+		cfg, _ := r.config()
+		wait := min(interval(cfg), time.Until(deadline))
+		if wait <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (r *Runner) updateUsdtIrr(ctx context.Context) error {
+	slog.DebugContext(ctx, "Calculating USDT/IRR rates")
+	results, err := r.calculateUsdtIrrPriceJob(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error calculating USDT/IRR rates", "error", err)
+		return err
+	}
+
+	for _, result := range results {
+		slog.InfoContext(ctx, "Calculated USDT/IRR rate", "source", result.Source, "market", result.MarketName,
+			"weighted_mean", result.WeightedMean, "median", result.Median, "std_dev", result.StdDev, "sum_amounts", result.SumAmounts)
+	}
+
+	if err := StoreUsdtIrrPrices(ctx, r.store, results); err != nil {
+		slog.ErrorContext(ctx, "Error storing USDT/IRR rates", "error", err)
+		return err
+	}
+	if len(results) > 0 {
+		r.recordWrite(JobUsdtIrr, time.Now())
+	}
+	return nil
+}
+
+// updateKucoinPrices stores the prices of every symbol that could be
+// fetched. It only fails when no price could be fetched or stored.
+func (r *Runner) updateKucoinPrices(ctx context.Context) error {
+	symbols, err := r.kucoinSymbols(ctx)
+	if err != nil {
+		return err
+	}
+
+	reporting.SetTag(ctx, reporting.TagSource, exchanges.KuCoin)
+	cfg, _ := r.config()
+	results := r.exchanges.GetPricesKucoin(ctx, r.kucoinFailures.active(ctx, symbols))
+	r.kucoinFailures.record(ctx, results, cfg.SuppressAfter)
+
+	if failed := results.Failed(); len(failed) > 0 {
+		fetched := len(results) - len(failed)
+		slog.WarnContext(ctx, "Some KuCoin prices could not be fetched", "failed", len(failed),
+			"fetched", fetched, "error", failed[0].Err)
+		if fetched == 0 {
+			return fmt.Errorf("failed to fetch all %d KuCoin prices: %w", len(failed), failed[0].Err)
+		}
+	}
+
+	return r.storePrices(ctx, "kucoin", results)
+}
+
+func (r *Runner) updateBinancePrices(ctx context.Context) error {
+	reporting.SetTag(ctx, reporting.TagSource, exchanges.Binance)
+	results, err := r.exchanges.GetAllBinancePrices(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching Binance prices", "error", err)
+		return err
+	}
+
+	return r.storePrices(ctx, "binance", results)
+}
+
+// storePrices writes the fetched prices of source, with their 24h
+// statistics where the exchange reported them, and records their update
+// time when there was at least one. Failed results are skipped.
+func (r *Runner) storePrices(ctx context.Context, source string, results exchanges.PriceResults) error {
+	reporting.SetTag(ctx, reporting.TagSource, source)
+	prices := results.Prices()
+	stats := make(map[string]models.MarketStats)
+	for _, result := range results {
+		if result.Err == nil && result.Ticker != nil {
+			stats[result.Market] = marketStats(result.Ticker)
+		}
+	}
+
+	now := time.Now()
+	if err := r.store.PutPrices(ctx, source, prices, stats, now); err != nil {
+		slog.ErrorContext(ctx, "Error storing prices", "source", source, "error", err)
+		return err
+	}
+
+	if len(prices) == 0 {
+		return nil
+	}
+	symbols := make([]string, 0, len(prices))
+	for symbol := range prices {
+		symbols = append(symbols, symbol)
+	}
+	metrics.PricesUpdated(source, symbols, now)
+	r.recordWrite(source, now)
+	return nil
+}
+
+// marketStats converts an exchange ticker to the stored statistics.
+func marketStats(ticker *exchanges.Ticker) models.MarketStats {
+	return models.MarketStats{
+		High:          ticker.High,
+		Low:           ticker.Low,
+		Change:        ticker.Change,
+		ChangePercent: ticker.ChangeRate * 100,
+		Volume:        ticker.Volume,
+		QuoteVolume:   ticker.QuoteVolume,
+		Bid:           ticker.Bid,
+		Ask:           ticker.Ask,
+	}
 }
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/exchanges"
+	"crypto_price/pkg/store"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobSkipsDisabled(t *testing.T) {
+	r := NewRunner(config.JobsConfig{}, nil, nil, nil, nil)
+	runs := 0
+	run := r.job("test", binanceEnabled, func(context.Context) error {
+		runs++
+		return nil
+	})
+
+	run(context.Background())
+	if runs != 0 {
+		t.Fatalf("disabled job ran %d times", runs)
+	}
+
+	r.Reconfigure(config.JobsConfig{BinanceEnabled: true})
+	run(context.Background())
+	if runs != 1 {
+		t.Errorf("enabled job ran %d times, want 1", runs)
+	}
+}
+
+func TestStorePricesRecordsWrites(t *testing.T) {
+	r := NewRunner(config.JobsConfig{}, nil, nil, store.NewMemoryStore(store.Expirations{
+		ShortTerm: time.Minute, LongTerm: time.Hour, UsdtIrr: time.Minute,
+	}), nil)
+	ctx := context.Background()
+
+	failed := exchanges.PriceResults{{Symbol: "BTC", Market: "BTCUSDT", Err: errors.New("timeout")}}
+	if err := r.storePrices(ctx, JobKucoin, failed); err != nil {
+		t.Fatalf("storePrices: %v", err)
+	}
+	if at := r.LastWrite(JobKucoin); !at.IsZero() {
+		t.Errorf("last write = %v after storing no price, want zero", at)
+	}
+
+	fetched := exchanges.PriceResults{{Symbol: "BTC", Market: "BTCUSDT", Price: 60000}}
+	if err := r.storePrices(ctx, JobKucoin, fetched); err != nil {
+		t.Fatalf("storePrices: %v", err)
+	}
+	if at := r.LastWrite(JobKucoin); at.IsZero() {
+		t.Error("last write not recorded after storing a price")
+	}
+}
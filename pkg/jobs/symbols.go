@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/exchanges"
+	"crypto_price/pkg/logging"
+	"crypto_price/pkg/metrics"
+	"log/slog"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// changeStreamRetryInterval is how long symbols are polled before opening a
+// change stream is tried again.
+const changeStreamRetryInterval = 5 * time.Minute
+
+// watchSymbols keeps the tracked symbols in memory until ctx is done. They are
+// reloaded whenever a change stream reports a write to the legacy config
+// collection or the tracked symbols. Deployments without change streams, such
+// as standalone servers, are polled every jobs.symbol_poll_interval instead.
+func (r *Runner) watchSymbols(ctx context.Context) {
+	ctx = logging.WithRun(ctx, JobSymbols)
+	for ctx.Err() == nil {
+		stream, err := r.mongo.WatchSymbolChanges(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.WarnContext(ctx, "Symbol change stream unavailable, polling instead", "error", err)
+				r.pollFor(ctx, changeStreamRetryInterval, symbolPollInterval, r.pollSymbols)
+			}
+			continue
+		}
+
+		slog.InfoContext(ctx, "Watching symbol changes with a change stream")
+		// The stream only reports writes made after it was opened.
+		r.reloadSymbols(ctx)
+		err = r.followSymbolChanges(ctx, stream)
+		stream.Close(context.Background())
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "Symbol change stream closed, polling before reopening", "error", err)
+			cfg, _ := r.config()
+			r.pollFor(ctx, cfg.SymbolPollInterval, symbolPollInterval, r.pollSymbols)
+		}
+	}
+}
+
+// followSymbolChanges reloads the symbols after every batch of changes until
+// the stream fails or ctx is done.
+func (r *Runner) followSymbolChanges(ctx context.Context, stream *mongo.ChangeStream) error {
+	for stream.Next(ctx) {
+		// Writes that arrive together, e.g. from a bulk update, trigger a
+		// single reload.
+		for stream.TryNext(ctx) {
+		}
+		r.reloadSymbols(ctx)
+	}
+	return stream.Err()
+}
+
+func symbolPollInterval(cfg config.JobsConfig) time.Duration {
+	return cfg.SymbolPollInterval
+}
+
+func (r *Runner) pollSymbols(ctx context.Context) {
+	r.reloadSymbols(ctx)
+}
+
+// reloadSymbols replaces the symbols in memory with those stored in MongoDB
+// and limits the price age metrics to them. On failure the previous symbols
+// are kept.
+func (r *Runner) reloadSymbols(ctx context.Context) error {
+	symbols, err := r.mongo.ExchangeSymbols(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching symbols from MongoDB", "error", err)
+		return err
+	}
+	r.setSymbols(ctx, symbols)
+	return nil
+}
+
+// setSymbols replaces the tracked symbols of every exchange and forgets the
+// failures of the KuCoin symbols no longer tracked.
+func (r *Runner) setSymbols(ctx context.Context, symbols map[string][]string) {
+	for _, list := range symbols {
+		sort.Strings(list)
+	}
+
+	r.symbolsMu.Lock()
+	previous, loaded := r.symbols, r.symbolsLoaded
+	r.symbols, r.symbolsLoaded = symbols, true
+	r.symbolsMu.Unlock()
+
+	for _, exchange := range []string{exchanges.KuCoin, exchanges.Binance} {
+		metrics.TrackPrices(exchange, priceKeys(symbols[exchange]))
+		if !loaded {
+			slog.InfoContext(ctx, "Loaded symbols", "exchange", exchange, "count", len(symbols[exchange]))
+		} else if added, removed := diffSymbols(previous[exchange], symbols[exchange]); len(added) > 0 || len(removed) > 0 {
+			slog.InfoContext(ctx, "Symbols changed", "exchange", exchange, "added", added, "removed", removed)
+			if exchange == exchanges.KuCoin {
+				for _, symbol := range removed {
+					if _, err := r.kucoinFailures.reset(ctx, symbol); err != nil {
+						slog.WarnContext(ctx, "Error forgetting failures of a dropped symbol", "symbol", symbol, "error", err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TrackedSymbols returns the symbols tracked on each exchange, keyed by
+// exchange, e.g. {"kucoin": ["BTC"], "binance": ["BTC"]}. They are loaded
+// first if the watcher has not done so yet. The map must not be modified.
+func (r *Runner) TrackedSymbols(ctx context.Context) (map[string][]string, error) {
+	r.symbolsMu.RLock()
+	symbols, loaded := r.symbols, r.symbolsLoaded
+	r.symbolsMu.RUnlock()
+	if loaded {
+		return symbols, nil
+	}
+
+	if err := r.reloadSymbols(ctx); err != nil {
+		return nil, err
+	}
+	r.symbolsMu.RLock()
+	defer r.symbolsMu.RUnlock()
+	return r.symbols, nil
+}
+
+// kucoinSymbols returns the KuCoin symbols to ingest.
+func (r *Runner) kucoinSymbols(ctx context.Context) ([]string, error) {
+	symbols, err := r.TrackedSymbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return symbols[exchanges.KuCoin], nil
+}
+
+// priceKeys returns the symbols under which the prices of the USDT markets
+// of symbols are stored, e.g. "BTCUSDT".
+func priceKeys(symbols []string) []string {
+	keys := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		keys[i] = symbol + "USDT"
+	}
+	return keys
+}
+
+// diffSymbols returns the symbols only in next and only in previous. Both
+// must be sorted.
+func diffSymbols(previous, next []string) (added, removed []string) {
+	i, j := 0, 0
+	for i < len(previous) || j < len(next) {
+		switch {
+		case j == len(next) || (i < len(previous) && previous[i] < next[j]):
+			removed = append(removed, previous[i])
+			i++
+		case i == len(previous) || next[j] < previous[i]:
+			added = append(added, next[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,279 @@
+package jobs
+
+import (
+	"context"
+	"crypto_price/pkg/logging"
+	"crypto_price/pkg/models"
+	"crypto_price/pkg/reporting"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// USDT/IRR modes selectable with jobs.usdt_irr_mode.
+const (
+	UsdtIrrModeBatch  = "batch"
+	UsdtIrrModeStream = "stream"
+)
+
+// streamRetryDelay is how long to wait before reopening an interrupted
+// trade stream.
+const streamRetryDelay = time.Second
+
+// tradeWindow holds the most recent qualifying trades of a source, oldest
+// first.
+type tradeWindow struct {
+	trades []windowTrade
+	ids    map[string]struct{}
+}
+
+type windowTrade struct {
+	id string
+	at time.Time
+	Transaction
+}
+
+func newTradeWindow() *tradeWindow {
+	return &tradeWindow{ids: make(map[string]struct{})}
+}
+
+// add inserts t in time order, keeping at most usdtIrrTradeLimit trades. It
+// reports whether t was new.
+func (w *tradeWindow) add(t trade) bool {
+	id := fmt.Sprint(t.ID)
+	if _, ok := w.ids[id]; ok {
+		return false
+	}
+	at, err := time.Parse(tradeTimeLayout, t.Time)
+	if err != nil {
+		slog.Warn("Skipping trade with invalid time", "source", t.Source, "time", t.Time)
+		return false
+	}
+
+	i := sort.Search(len(w.trades), func(i int) bool { return w.trades[i].at.After(at) })
+	w.trades = append(w.trades, windowTrade{})
+	copy(w.trades[i+1:], w.trades[i:])
+	w.trades[i] = windowTrade{id: id, at: at, Transaction: t.Transaction}
+	w.ids[id] = struct{}{}
+
+	if excess := len(w.trades) - usdtIrrTradeLimit; excess > 0 {
+		w.drop(excess)
+	}
+	return true
+}
+
+// evict removes the trades older than since.
+func (w *tradeWindow) evict(since time.Time) {
+	n := sort.Search(len(w.trades), func(i int) bool { return !w.trades[i].at.Before(since) })
+	w.drop(n)
+}
+
+func (w *tradeWindow) drop(n int) {
+	for _, t := range w.trades[:n] {
+		delete(w.ids, t.id)
+	}
+	w.trades = append(w.trades[:0], w.trades[n:]...)
+}
+
+// transactions returns a copy of the trades larger than minAmount.
+func (w *tradeWindow) transactions(minAmount float64) []Transaction {
+	out := make([]Transaction, 0, len(w.trades))
+	for _, t := range w.trades {
+		if t.Amount > minAmount {
+			out = append(out, t.Transaction)
+		}
+	}
+	return out
+}
+
+// streamUsdtIrr keeps USDT/IRR rates up to date from a change stream of the
+// last trade collection until ctx is done. Each source has a rolling window
+// of its recent trades, seeded by the batch query, and its rate is
+// republished on every qualifying trade and at least every
+// jobs.usdt_irr_interval. When the stream cannot be resumed the windows are
+// seeded again; when change streams are unavailable, rates are computed in
+// batches as in batch mode.
+func (r *Runner) streamUsdtIrr(ctx context.Context) {
+	ctx = logging.WithRun(ctx, JobUsdtIrr)
+	var token bson.Raw
+	var windows map[string]*tradeWindow
+
+	for ctx.Err() == nil {
+		stream, err := r.mongo.WatchLastTrades(ctx, token)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if token != nil {
+				slog.WarnContext(ctx, "Cannot resume USDT/IRR trade stream, reloading trades", "error", err)
+				token = nil
+				continue
+			}
+			slog.WarnContext(ctx, "USDT/IRR trade stream unavailable, computing in batches", "error", err)
+			r.pollFor(ctx, changeStreamRetryInterval, usdtIrrInterval, r.job(JobUsdtIrr, nil, r.updateUsdtIrr))
+			continue
+		}
+
+		if token == nil {
+			// Seeding after the stream opened leaves no gap; trades seen by
+			// both are only counted once.
+			if windows, err = r.seedTradeWindows(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error loading USDT/IRR trades", "error", err)
+				stream.Close(context.Background())
+				r.pollFor(ctx, streamRetryDelay, usdtIrrInterval, r.job(JobUsdtIrr, nil, r.updateUsdtIrr))
+				continue
+			}
+			slog.InfoContext(ctx, "Streaming USDT/IRR trades")
+			r.publishUsdtIrr(ctx, windows, nil)
+		}
+
+		token, err = r.followTrades(ctx, stream, windows)
+		stream.Close(context.Background())
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "USDT/IRR trade stream interrupted, resuming", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(streamRetryDelay):
+			}
+		}
+	}
+}
+
+// seedTradeWindows loads the current window of every source with the batch
+// query.
+func (r *Runner) seedTradeWindows(ctx context.Context) (map[string]*tradeWindow, error) {
+	cfg, _ := r.config()
+	ctx, cancel := context.WithTimeout(ctx, cfg.UsdtIrrTimeout)
+	defer cancel()
+
+	collection, err := r.mongo.LastTrades(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.ensureTradeIndex(ctx)
+
+	now := time.Now()
+	windows := make(map[string]*tradeWindow)
+	for _, source := range cfg.UsdtIrrSources {
+		market, ok := usdtIrrMarkets[source]
+		if !ok {
+			continue
+		}
+		trades, err := loadTrades(ctx, collection, cfg, source, market, windowStart(cfg, now))
+		if err != nil {
+			return nil, err
+		}
+
+		window := newTradeWindow()
+		for _, t := range trades {
+			window.add(t)
+		}
+		windows[source] = window
+	}
+	return windows, nil
+}
+
+// followTrades applies the trades of stream to windows until the stream
+// fails or ctx is done, and returns the token to resume after.
+func (r *Runner) followTrades(ctx context.Context, stream *mongo.ChangeStream, windows map[string]*tradeWindow) (bson.Raw, error) {
+	cfg, reconfigured := r.config()
+	nextPublish := time.Now().Add(cfg.UsdtIrrInterval)
+
+	for {
+		if stream.TryNext(ctx) {
+			var event struct {
+				FullDocument trade `bson:"fullDocument"`
+			}
+			if err := stream.Decode(&event); err != nil {
+				slog.WarnContext(ctx, "Skipping undecodable trade", "error", err)
+				continue
+			}
+			if source, ok := r.addTrade(windows, event.FullDocument); ok {
+				r.publishUsdtIrr(ctx, windows, []string{source})
+			}
+			continue
+		}
+		token := stream.ResumeToken()
+		if err := stream.Err(); err != nil {
+			return token, err
+		}
+		if err := ctx.Err(); err != nil {
+			return token, err
+		}
+
+		select {
+		case <-reconfigured:
+			// Sources, window or minimum amount may have changed.
+			seeded, err := r.seedTradeWindows(ctx)
+			if err != nil {
+				return token, fmt.Errorf("failed to reload trades after a config change: %w", err)
+			}
+			for source := range windows {
+				delete(windows, source)
+			}
+			for source, window := range seeded {
+				windows[source] = window
+			}
+			cfg, reconfigured = r.config()
+			r.publishUsdtIrr(ctx, windows, nil)
+			nextPublish = time.Now().Add(cfg.UsdtIrrInterval)
+		default:
+		}
+
+		// Republish without new trades so old trades leave the window and
+		// the stored rates do not expire.
+		if time.Now().After(nextPublish) {
+			r.publishUsdtIrr(ctx, windows, nil)
+			nextPublish = time.Now().Add(cfg.UsdtIrrInterval)
+		}
+	}
+}
+
+// addTrade adds t to the window of its source if it qualifies, and returns
+// the source.
+func (r *Runner) addTrade(windows map[string]*tradeWindow, t trade) (string, bool) {
+	cfg, _ := r.config()
+	window, ok := windows[t.Source]
+	if !ok || t.MarketName != usdtIrrMarkets[t.Source].MarketName || t.Amount <= cfg.UsdtIrrMinAmount {
+		return "", false
+	}
+	return t.Source, window.add(t)
+}
+
+// publishUsdtIrr stores the rates of sources, or of every source when
+// sources is nil, from their windows.
+func (r *Runner) publishUsdtIrr(ctx context.Context, windows map[string]*tradeWindow, sources []string) {
+	if sources == nil {
+		for source := range windows {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+	}
+
+	cfg, _ := r.config()
+	now := time.Now()
+	var results []models.MarketSourceResult
+	for _, source := range sources {
+		market := usdtIrrMarkets[source]
+		since := windowStart(cfg, now)
+		window := windows[source]
+		window.evict(market.sourceTime(since))
+		results = append(results, usdtIrrResult(source, market, window.transactions(cfg.UsdtIrrMinAmount), since, now))
+	}
+
+	if err := StoreUsdtIrrPrices(ctx, r.store, results); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Error storing USDT/IRR rates", "error", err)
+			reporting.CaptureError(ctx, err, map[string]string{reporting.TagJob: JobUsdtIrr})
+		}
+		return
+	}
+	if len(results) > 0 {
+		r.recordWrite(JobUsdtIrr, now)
+	}
+}
@@ -0,0 +1,218 @@
+package jobs
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/store"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func testTrade(id int, source string, at time.Time, price, amount float64) trade {
+	return trade{
+		ID:          id,
+		MarketName:  usdtIrrMarkets[source].MarketName,
+		Source:      source,
+		Time:        at.UTC().Format(tradeTimeLayout),
+		Transaction: Transaction{Price: price, Amount: amount},
+	}
+}
+
+func windowIDs(w *tradeWindow) string {
+	ids := make([]string, len(w.trades))
+	for i, t := range w.trades {
+		ids[i] = t.id
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestTradeWindowAdd(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	w := newTradeWindow()
+
+	for _, tt := range []struct {
+		trade trade
+		want  bool
+	}{
+		{testTrade(1, "nobitex", base.Add(2*time.Second), 1, 1), true},
+		{testTrade(2, "nobitex", base, 1, 1), true},
+		{testTrade(3, "nobitex", base.Add(time.Second), 1, 1), true},
+		// Seen both by the seed query and the stream.
+		{testTrade(1, "nobitex", base.Add(2*time.Second), 1, 1), false},
+		{trade{ID: 4, Source: "nobitex", Time: "yesterday"}, false},
+	} {
+		if got := w.add(tt.trade); got != tt.want {
+			t.Errorf("add(%v at %s) = %v, want %v", tt.trade.ID, tt.trade.Time, got, tt.want)
+		}
+	}
+	if got := windowIDs(w); got != "[2 3 1]" {
+		t.Errorf("window = %s, want trades in time order [2 3 1]", got)
+	}
+}
+
+func TestTradeWindowLimit(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	w := newTradeWindow()
+	for i := 0; i <= usdtIrrTradeLimit; i++ {
+		w.add(testTrade(i, "nobitex", base.Add(time.Duration(i)*time.Second), 1, 1))
+	}
+
+	if len(w.trades) != usdtIrrTradeLimit || len(w.ids) != usdtIrrTradeLimit {
+		t.Fatalf("window holds %d trades and %d ids, want %d", len(w.trades), len(w.ids), usdtIrrTradeLimit)
+	}
+	if w.trades[0].id != "1" {
+		t.Errorf("oldest trade = %s, want 1 after trade 0 was dropped", w.trades[0].id)
+	}
+	// A dropped trade is forgotten, like any trade outside the window.
+	if !w.add(testTrade(0, "nobitex", base, 1, 1)) {
+		t.Error("dropped trade was still considered a duplicate")
+	}
+}
+
+func TestTradeWindowEvict(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		since time.Time
+		want  string
+	}{
+		{name: "none", since: base.Add(-time.Second), want: "[0 1 2]"},
+		{name: "older", since: base.Add(time.Second), want: "[1 2]"},
+		{name: "all", since: base.Add(time.Minute), want: "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTradeWindow()
+			for i := 0; i < 3; i++ {
+				w.add(testTrade(i, "nobitex", base.Add(time.Duration(i)*time.Second), 1, 1))
+			}
+			w.evict(tt.since)
+			if got := windowIDs(w); got != tt.want {
+				t.Errorf("window = %s, want %s", got, tt.want)
+			}
+			if len(w.ids) != len(w.trades) {
+				t.Errorf("%d ids for %d trades", len(w.ids), len(w.trades))
+			}
+		})
+	}
+}
+
+func TestTradeWindowTransactions(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	w := newTradeWindow()
+	w.add(testTrade(1, "nobitex", base, 600000, 5))
+	w.add(testTrade(2, "nobitex", base.Add(time.Second), 610000, 50))
+
+	transactions := w.transactions(10)
+	if len(transactions) != 1 || transactions[0].Price != 610000 {
+		t.Errorf("transactions above 10 = %v, want the 610000 trade", transactions)
+	}
+	// The copy can be sorted without reordering the window.
+	transactions = w.transactions(0)
+	transactions[0], transactions[1] = transactions[1], transactions[0]
+	if w.trades[0].Price != 600000 {
+		t.Error("changing the returned transactions changed the window")
+	}
+}
+
+func TestAddTrade(t *testing.T) {
+	r := NewRunner(config.JobsConfig{UsdtIrrMinAmount: 10}, nil, nil, nil, nil)
+	windows := map[string]*tradeWindow{"nobitex": newTradeWindow()}
+	now := time.Now()
+
+	wrongMarket := testTrade(3, "nobitex", now, 600000, 50)
+	wrongMarket.MarketName = "BTCIRT"
+	tests := []struct {
+		name  string
+		trade trade
+		want  bool
+	}{
+		{name: "qualifying", trade: testTrade(1, "nobitex", now, 600000, 50), want: true},
+		{name: "duplicate", trade: testTrade(1, "nobitex", now, 600000, 50)},
+		{name: "source without window", trade: testTrade(2, "wallex", now, 600000, 50)},
+		{name: "other market", trade: wrongMarket},
+		{name: "at minimum amount", trade: testTrade(4, "nobitex", now, 600000, 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := r.addTrade(windows, tt.trade); got != tt.want {
+				t.Errorf("addTrade = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if n := len(windows["nobitex"].trades); n != 1 {
+		t.Errorf("window holds %d trades, want 1", n)
+	}
+}
+
+func TestCalculateStatistics(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions []Transaction
+		median       float64
+		weightedMean float64
+		stdDev       float64
+		sumAmounts   float64
+	}{
+		{name: "empty"},
+		{
+			name:         "odd",
+			transactions: []Transaction{{Price: 300, Amount: 1}, {Price: 100, Amount: 1}, {Price: 200, Amount: 2}},
+			median:       200, weightedMean: 200, stdDev: math.Sqrt(5000), sumAmounts: 4,
+		},
+		{
+			name:         "even",
+			transactions: []Transaction{{Price: 100, Amount: 3}, {Price: 200, Amount: 1}},
+			median:       150, weightedMean: 125, stdDev: math.Sqrt(1875), sumAmounts: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			median, weightedMean, stdDev, sumAmounts := calculateStatistics(tt.transactions)
+			if median != tt.median || weightedMean != tt.weightedMean || math.Abs(stdDev-tt.stdDev) > 1e-9 || sumAmounts != tt.sumAmounts {
+				t.Errorf("statistics = %v, %v, %v, %v; want %v, %v, %v, %v", median, weightedMean, stdDev, sumAmounts,
+					tt.median, tt.weightedMean, tt.stdDev, tt.sumAmounts)
+			}
+		})
+	}
+}
+
+func TestPublishUsdtIrr(t *testing.T) {
+	ps := store.NewMemoryStore(store.Expirations{ShortTerm: time.Minute, LongTerm: time.Hour, UsdtIrr: time.Hour})
+	r := NewRunner(config.JobsConfig{UsdtIrrWindow: 10 * time.Minute}, nil, nil, ps, nil)
+	now := time.Now()
+
+	windows := map[string]*tradeWindow{"nobitex": newTradeWindow(), "wallex": newTradeWindow()}
+	windows["nobitex"].add(testTrade(1, "nobitex", now.Add(-time.Hour), 500000, 100))
+	windows["nobitex"].add(testTrade(2, "nobitex", now.Add(-time.Minute), 600000, 1))
+	windows["nobitex"].add(testTrade(3, "nobitex", now.Add(-time.Minute), 620000, 3))
+	// Wallex records Tehran time: a trade of a minute ago is stored ahead of
+	// UTC and must stay in the window.
+	windows["wallex"].add(testTrade(4, "wallex", now.Add(-time.Minute+exchangeClockSkew), 610000, 2))
+
+	r.publishUsdtIrr(context.Background(), windows, []string{"nobitex"})
+	if len(windows["nobitex"].trades) != 2 {
+		t.Errorf("nobitex window holds %d trades, want 2 after the hour-old trade left", len(windows["nobitex"].trades))
+	}
+	result, err := ps.GetUsdtIrr(context.Background(), "nobitex")
+	if err != nil {
+		t.Fatalf("GetUsdtIrr: %v", err)
+	}
+	if result.Trades != 2 || result.Median != 610000 || result.WeightedMean != 615000 || result.SumAmounts != 4 {
+		t.Errorf("nobitex rate = %+v, want 2 trades, median 610000, weighted mean 615000", result)
+	}
+	if _, err := ps.GetUsdtIrr(context.Background(), "wallex"); err == nil {
+		t.Error("wallex was published although only nobitex was asked for")
+	}
+	if r.LastWrite(JobUsdtIrr).IsZero() {
+		t.Error("publish did not record a write")
+	}
+
+	r.publishUsdtIrr(context.Background(), windows, nil)
+	result, err = ps.GetUsdtIrr(context.Background(), "wallex")
+	if err != nil || result.Trades != 1 || result.Median != 610000 {
+		t.Errorf("wallex rate = %+v, %v; want its trade of a minute ago", result, err)
+	}
+}
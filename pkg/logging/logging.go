@@ -0,0 +1,185 @@
+// Package logging configures the structured logger of the service and
+// carries request and job run IDs through contexts, so every record logged
+// with a context is tagged with the request or job run it belongs to.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto_price/pkg/config"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Formats selectable with log.format.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Attribute keys added from the context.
+const (
+	KeyRequestID = "request_id"
+	KeyJob       = "job"
+	KeyRunID     = "run_id"
+)
+
+var (
+	level   = new(slog.LevelVar)
+	samples = newSampler(time.Minute)
+)
+
+// Setup installs the default slog logger writing to w in the format of cfg.
+// Records from the standard log package go through it as well.
+func Setup(cfg config.LogConfig, w io.Writer) error {
+	if err := Configure(cfg); err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch cfg.Format {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// Configure applies the level and sampling interval of cfg to the installed
+// logger. Both can change while the service runs.
+func Configure(cfg config.LogConfig) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+	level.Set(l)
+	samples.setInterval(cfg.SampleInterval)
+	return nil
+}
+
+// NewID returns a random identifier for a request or job run.
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+type requestIDKey struct{}
+
+type runKey struct{}
+
+type run struct {
+	job, id string
+}
+
+// WithRequestID returns a context tagging records with request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRun returns a context tagging records with job and a new run ID.
+func WithRun(ctx context.Context, job string) context.Context {
+	return context.WithValue(ctx, runKey{}, run{job: job, id: NewID()})
+}
+
+// contextHandler adds the request and job run IDs of the context to every
+// record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String(KeyRequestID, id))
+		}
+		if run, ok := ctx.Value(runKey{}).(run); ok {
+			r.AddAttrs(slog.String(KeyJob, run.job), slog.String(KeyRunID, run.id))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Sampled logs a record unless one with the same key was logged less than
+// log.sample_interval ago. It is meant for errors that repeat on every job
+// run, such as a failing symbol; the next record logged for key reports how
+// many were suppressed.
+func Sampled(ctx context.Context, lvl slog.Level, key, msg string, args ...any) {
+	if !slog.Default().Enabled(ctx, lvl) {
+		return
+	}
+	ok, suppressed := samples.allow(key, time.Now())
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		args = append(args, "suppressed", suppressed)
+	}
+	slog.Log(ctx, lvl, msg, args...)
+}
+
+type sampler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	keys     map[string]*sampledKey
+}
+
+type sampledKey struct {
+	logged     time.Time
+	suppressed int
+}
+
+func newSampler(interval time.Duration) *sampler {
+	return &sampler{interval: interval, keys: make(map[string]*sampledKey)}
+}
+
+func (s *sampler) setInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = interval
+}
+
+// allow reports whether a record for key may be logged at now, and how many
+// were suppressed since the last one.
+func (s *sampler) allow(key string, now time.Time) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[key]
+	if !ok {
+		s.keys[key] = &sampledKey{logged: now}
+		return true, 0
+	}
+	if now.Sub(k.logged) < s.interval {
+		k.suppressed++
+		return false, 0
+	}
+	suppressed := k.suppressed
+	k.logged, k.suppressed = now, 0
+	return true, suppressed
+}
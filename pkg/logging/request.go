@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader carries the request ID in HTTP requests and responses, and
+// in gRPC metadata in lower case.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// HTTPMiddleware tags the context of every request with the ID sent by the
+// client in RequestIDHeader, or a new one, and echoes it in the response.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// UnaryServerInterceptor is the gRPC counterpart of HTTPMiddleware. The ID
+// is read from and returned in the x-request-id metadata.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var sent string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(RequestIDHeader); len(values) > 0 {
+				sent = values[0]
+			}
+		}
+		id := requestID(sent)
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+		return handler(WithRequestID(ctx, id), req)
+	}
+}
+
+// requestID returns sent if it is a usable ID, or a new one.
+func requestID(sent string) string {
+	if sent == "" || len(sent) > maxRequestIDLength {
+		return NewID()
+	}
+	for _, c := range sent {
+		if c < '!' || c > '~' {
+			return NewID()
+		}
+	}
+	return sent
+}
@@ -0,0 +1,230 @@
+// Package metrics defines the Prometheus metrics of the service and the
+// adapters that record them for HTTP, gRPC, Redis and MongoDB.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of jobs and database operations.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_price_http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crypto_price_http_request_duration_seconds",
+		Help:    "HTTP request latency by route and method.",
+		Buckets: latencyBuckets,
+	}, []string{"route", "method"})
+
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_price_grpc_requests_total",
+		Help: "gRPC calls by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crypto_price_grpc_request_duration_seconds",
+		Help:    "gRPC call latency by method.",
+		Buckets: latencyBuckets,
+	}, []string{"method"})
+
+	exchangeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crypto_price_exchange_request_duration_seconds",
+		Help:    "Exchange API request latency by exchange and endpoint.",
+		Buckets: latencyBuckets,
+	}, []string{"exchange", "endpoint"})
+
+	exchangeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_price_exchange_request_errors_total",
+		Help: "Exchange API requests that failed or returned an error status, by exchange and endpoint.",
+	}, []string{"exchange", "endpoint"})
+
+	exchangeRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_price_exchange_request_retries_total",
+		Help: "Exchange API requests retried after a network error, 429 or 5xx, by exchange.",
+	}, []string{"exchange"})
+
+	exchangeCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_price_exchange_circuit_state",
+		Help: "Circuit breaker state of each exchange: 0 closed, 1 half-open, 2 open.",
+	}, []string{"exchange"})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crypto_price_job_duration_seconds",
+		Help:    "Ingestion job run duration by job.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"job"})
+
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_price_job_runs_total",
+		Help: "Ingestion job runs by job and result.",
+	}, []string{"job", "result"})
+
+	jobLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_price_job_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful run of each job.",
+	}, []string{"job"})
+
+	suppressedSymbols = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_price_suppressed_symbols",
+		Help: "Symbols whose ingestion is suppressed after consecutive failures, by source.",
+	}, []string{"source"})
+
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crypto_price_redis_command_duration_seconds",
+		Help:    "Redis command latency by command and result. Pipelines are reported as a single pipeline command.",
+		Buckets: latencyBuckets,
+	}, []string{"command", "result"})
+
+	mongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crypto_price_mongo_command_duration_seconds",
+		Help:    "MongoDB command latency by command and result.",
+		Buckets: latencyBuckets,
+	}, []string{"command", "result"})
+
+	usdtIrrRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_price_usdt_irr_rate",
+		Help: "Latest USDT/IRR rate (volume-weighted mean) by source.",
+	}, []string{"source"})
+
+	priceAges = &priceAgeCollector{
+		desc: prometheus.NewDesc("crypto_price_price_age_seconds",
+			"Time since the price of each tracked symbol was last written by the ingestion jobs.",
+			[]string{"source", "symbol"}, nil),
+		tracked: make(map[string]map[string]bool),
+		updated: make(map[priceKey]time.Time),
+	}
+)
+
+// Collectors returns the collectors of the service, to be registered once.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		httpRequests, httpDuration,
+		grpcRequests, grpcDuration,
+		exchangeDuration, exchangeErrors, exchangeRetries, exchangeCircuitState,
+		jobDuration, jobRuns, jobLastSuccess, suppressedSymbols,
+		redisDuration, mongoDuration,
+		usdtIrrRate, priceAges,
+	}
+}
+
+// ObserveExchangeRequest records a request to endpoint of exchange that
+// started at start and failed if failed is set.
+func ObserveExchangeRequest(exchange, endpoint string, start time.Time, failed bool) {
+	exchangeDuration.WithLabelValues(exchange, endpoint).Observe(time.Since(start).Seconds())
+	if failed {
+		exchangeErrors.WithLabelValues(exchange, endpoint).Inc()
+	}
+}
+
+// ExchangeRetried records a retry of a request to exchange.
+func ExchangeRetried(exchange string) {
+	exchangeRetries.WithLabelValues(exchange).Inc()
+}
+
+// SetExchangeCircuitState publishes the circuit breaker state of exchange,
+// 0 closed, 1 half-open and 2 open.
+func SetExchangeCircuitState(exchange string, state int) {
+	exchangeCircuitState.WithLabelValues(exchange).Set(float64(state))
+}
+
+// ObserveJob records a run of job that started at start and returned err.
+func ObserveJob(job string, start time.Time, err error) {
+	jobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+	if err != nil {
+		jobRuns.WithLabelValues(job, ResultFailure).Inc()
+		return
+	}
+	jobRuns.WithLabelValues(job, ResultSuccess).Inc()
+	jobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+}
+
+// SetSuppressedSymbols publishes how many symbols of source are suppressed.
+func SetSuppressedSymbols(source string, count int) {
+	suppressedSymbols.WithLabelValues(source).Set(float64(count))
+}
+
+// SetUsdtIrrRate publishes the latest USDT/IRR rate of source.
+func SetUsdtIrrRate(source string, rate float64) {
+	usdtIrrRate.WithLabelValues(source).Set(rate)
+}
+
+// TrackPrices limits the price ages reported for source to symbols, e.g.
+// "BTCUSDT", and drops those of symbols no longer tracked. Binance returns
+// every market it lists, so ages are only kept for tracked symbols to bound
+// the number of series.
+func TrackPrices(source string, symbols []string) {
+	tracked := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		tracked[symbol] = true
+	}
+
+	priceAges.mu.Lock()
+	defer priceAges.mu.Unlock()
+	priceAges.tracked[source] = tracked
+	for key := range priceAges.updated {
+		if key.source == source && !tracked[key.symbol] {
+			delete(priceAges.updated, key)
+		}
+	}
+}
+
+// PricesUpdated records that the prices of symbols from source were written
+// at at. Symbols not tracked through TrackPrices are ignored.
+func PricesUpdated(source string, symbols []string, at time.Time) {
+	priceAges.mu.Lock()
+	defer priceAges.mu.Unlock()
+	tracked := priceAges.tracked[source]
+	for _, symbol := range symbols {
+		if tracked[symbol] {
+			priceAges.updated[priceKey{source, symbol}] = at
+		}
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+type priceKey struct {
+	source, symbol string
+}
+
+// priceAgeCollector reports the age of every price at scrape time, so a
+// symbol that stops updating shows a growing age rather than a stale one.
+type priceAgeCollector struct {
+	desc *prometheus.Desc
+
+	mu sync.Mutex
+	// tracked holds the symbols of each source whose age is reported.
+	tracked map[string]map[string]bool
+	updated map[priceKey]time.Time
+}
+
+func (c *priceAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *priceAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for key, at := range c.updated {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(at).Seconds(), key.source, key.symbol)
+	}
+}
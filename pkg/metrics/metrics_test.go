@@ -0,0 +1,154 @@
+package metrics_test
+
+import (
+	"context"
+	"crypto_price/pkg/app"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/jobs"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/models"
+	"crypto_price/pkg/server"
+	"crypto_price/pkg/store"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.mongodb.org/mongo-driver/event"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestMetricsEndpoint drives an HTTP request, a gRPC call, a job run and a
+// USDT/IRR rate through the application and checks /metrics exports every
+// family.
+func TestMetricsEndpoint(t *testing.T) {
+	binance := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"symbol":"BTCUSDT","lastPrice":"60000"},{"symbol":"ETHUSDT","lastPrice":"3000"}]`)
+	}))
+	defer binance.Close()
+
+	mr := miniredis.RunT(t)
+	cfg := config.Default()
+	cfg.Store.Backend = "memory"
+	cfg.Redis.Addrs = []string{mr.Addr()}
+	cfg.Mongo.URI = "mongodb://127.0.0.1:1"
+	cfg.Mongo.ConnectTimeout = 100 * time.Millisecond
+	cfg.Mongo.ServerSelectionTimeout = 100 * time.Millisecond
+	cfg.Exchanges.BinanceURL = binance.URL
+	cfg.Jobs.BinanceEnabled = true
+	cfg.Jobs.BinanceInterval = 50 * time.Millisecond
+
+	a, err := app.New(cfg)
+	if err != nil {
+		t.Fatalf("app.New: %v", err)
+	}
+	defer a.Close(context.Background())
+	handler := a.HTTP.Handler()
+
+	// MongoDB is unreachable, so the symbols cannot be loaded and no command
+	// completes: track BTC by hand and replay a command through the monitor.
+	metrics.TrackPrices("binance", []string{"BTCUSDT"})
+	monitor := metrics.MongoMonitor()
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", DurationNanos: int64(time.Millisecond)},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := a.Store.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Jobs.Run(ctx)
+	}()
+	timeout := time.After(5 * time.Second)
+	for received := false; !received; {
+		select {
+		case prefix := <-updates:
+			received = prefix == store.SourcePrefix("binance")
+		case <-timeout:
+			t.Fatal("no Binance update was published")
+		}
+	}
+	cancel()
+	<-done
+
+	if err := jobs.StoreUsdtIrrPrices(context.Background(), a.Store, []models.MarketSourceResult{
+		{Source: "nobitex", MarketName: "USDTIRT", WeightedMean: 600000, Trades: 100, ComputedAt: time.Now()},
+	}); err != nil {
+		t.Fatalf("StoreUsdtIrrPrices: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/health", nil))
+
+	client := dialGRPC(t, a)
+	if _, err := client.GetCryptoPrice(context.Background(), &server.PriceRequest{Base: "BTC", Source: "binance", Quote: "usdt"}); err != nil {
+		t.Fatalf("GetCryptoPrice: %v", err)
+	}
+
+	body := scrape(t, handler)
+	for _, want := range []string{
+		`crypto_price_http_requests_total{method="GET",route="/v1/health",status=`,
+		`crypto_price_http_request_duration_seconds_count{method="GET",route="/v1/health"}`,
+		`crypto_price_grpc_requests_total{code="OK",method="` + server.CryptoPriceService_GetCryptoPrice_FullMethodName + `"} 1`,
+		`crypto_price_grpc_request_duration_seconds_count{method="` + server.CryptoPriceService_GetCryptoPrice_FullMethodName + `"} 1`,
+		`crypto_price_exchange_request_duration_seconds_count{endpoint="/api/v3/ticker/24hr",exchange="binance"}`,
+		`crypto_price_price_age_seconds{source="binance",symbol="BTCUSDT"}`,
+		`crypto_price_job_runs_total{job="binance",result="success"}`,
+		`crypto_price_job_last_success_timestamp_seconds{job="binance"}`,
+		`crypto_price_redis_command_duration_seconds_count{command="ping",result="success"}`,
+		`crypto_price_mongo_command_duration_seconds_count{command="find",result="success"}`,
+		`crypto_price_usdt_irr_rate{source="nobitex"} 600000`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics lacks %s", want)
+		}
+	}
+	if strings.Contains(body, `symbol="ETHUSDT"`) {
+		t.Error("/metrics reports the age of an untracked symbol")
+	}
+
+	// Symbols that are no longer tracked drop out.
+	metrics.TrackPrices("binance", nil)
+	if body := scrape(t, handler); strings.Contains(body, "crypto_price_price_age_seconds{") {
+		t.Error("/metrics still reports the age of a symbol that is no longer tracked")
+	}
+}
+
+// dialGRPC serves the gRPC server of a in memory and returns a client for it.
+func dialGRPC(t *testing.T, a *app.App) server.CryptoPriceServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	go a.GRPC.Serve(listener)
+	t.Cleanup(a.GRPC.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server.NewCryptoPriceServiceClient(conn)
+}
+
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d", w.Code)
+	}
+	return w.Body.String()
+}
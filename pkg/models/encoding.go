@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MarketSourceResultVersion is the current version of the Redis payload
+// written for usdtirr:<source> keys.
+const MarketSourceResultVersion = 1
+
+type marketSourceResultEnvelope struct {
+	Version int                `json:"v"`
+	Data    MarketSourceResult `json:"data"`
+}
+
+// legacyMarketSourceResult is the unversioned payload written before the
+// envelope was introduced, with Go field names as JSON keys.
+type legacyMarketSourceResult struct {
+	MarketName   string
+	Source       string
+	Median       float64
+	WeightedMean float64
+	StdDev       float64
+	SumAmounts   float64
+}
+
+// toResult maps the legacy payload to the current model. The trade count,
+// computation time and window were not recorded and stay zero.
+func (l legacyMarketSourceResult) toResult() MarketSourceResult {
+	return MarketSourceResult{
+		MarketName:   l.MarketName,
+		Source:       l.Source,
+		Median:       l.Median,
+		WeightedMean: l.WeightedMean,
+		StdDev:       l.StdDev,
+		SumAmounts:   l.SumAmounts,
+	}
+}
+
+// EncodeMarketSourceResult encodes a result as a versioned Redis payload.
+func EncodeMarketSourceResult(result MarketSourceResult) ([]byte, error) {
+	return json.Marshal(marketSourceResultEnvelope{
+		Version: MarketSourceResultVersion,
+		Data:    result,
+	})
+}
+
+// DecodeMarketSourceResult decodes a Redis payload written by any supported
+// version of EncodeMarketSourceResult, including the legacy unversioned form.
+func DecodeMarketSourceResult(data []byte) (MarketSourceResult, error) {
+	var probe struct {
+		Version *int `json:"v"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return MarketSourceResult{}, fmt.Errorf("failed to decode market source result: %w", err)
+	}
+
+	if probe.Version == nil {
+		var legacy legacyMarketSourceResult
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return MarketSourceResult{}, fmt.Errorf("failed to decode legacy market source result: %w", err)
+		}
+		return legacy.toResult(), nil
+	}
+
+	switch *probe.Version {
+	case 1:
+		var envelope marketSourceResultEnvelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return MarketSourceResult{}, fmt.Errorf("failed to decode market source result v1: %w", err)
+		}
+		return envelope.Data, nil
+	default:
+		return MarketSourceResult{}, fmt.Errorf("unsupported market source result version: %d", *probe.Version)
+	}
+}
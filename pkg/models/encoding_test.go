@@ -0,0 +1,154 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMarketSourceResult() MarketSourceResult {
+	computed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return MarketSourceResult{
+		MarketName:   "USDTIRT",
+		Source:       "nobitex",
+		Median:       612000,
+		WeightedMean: 611500.5,
+		StdDev:       120.25,
+		SumAmounts:   15000,
+		Trades:       100,
+		ComputedAt:   computed,
+		WindowStart:  computed.Add(-30 * time.Minute),
+		WindowEnd:    computed,
+	}
+}
+
+func TestMarketSourceResultRoundTrip(t *testing.T) {
+	want := testMarketSourceResult()
+
+	data, err := EncodeMarketSourceResult(want)
+	if err != nil {
+		t.Fatalf("EncodeMarketSourceResult: %v", err)
+	}
+	if !strings.Contains(string(data), `"v":1`) {
+		t.Errorf("payload %s has no version", data)
+	}
+
+	got, err := DecodeMarketSourceResult(data)
+	if err != nil {
+		t.Fatalf("DecodeMarketSourceResult: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeLegacyMarketSourceResult(t *testing.T) {
+	// The legacy payload has Go field names as keys and no trade count,
+	// computation time or window.
+	data := []byte(`{"MarketName":"USDTIRT","Source":"nobitex","Median":612000,"WeightedMean":611500.5,"StdDev":850.25,"SumAmounts":125000}`)
+	want := MarketSourceResult{
+		MarketName:   "USDTIRT",
+		Source:       "nobitex",
+		Median:       612000,
+		WeightedMean: 611500.5,
+		StdDev:       850.25,
+		SumAmounts:   125000,
+	}
+
+	got, err := DecodeMarketSourceResult(data)
+	if err != nil {
+		t.Fatalf("DecodeMarketSourceResult: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("legacy decode = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeMarketSourceResultErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"invalid JSON", `{"v":`},
+		{"unsupported version", `{"v":2,"data":{}}`},
+		{"invalid v1 data", `{"v":1,"data":{"trades":"many"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeMarketSourceResult([]byte(tt.data)); err == nil {
+				t.Errorf("DecodeMarketSourceResult(%s) succeeded, want error", tt.data)
+			}
+		})
+	}
+}
+
+func TestPriceResponseJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats *MarketStats
+	}{
+		{"without stats", nil},
+		{"with stats", &MarketStats{High: 61000, Low: 59000, Change: 600, ChangePercent: 1.01, Volume: 1234.5, QuoteVolume: 74070000, Bid: 59999, Ask: 60001}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := Price{
+				Symbol:     "BTCUSDT",
+				Base:       "BTC",
+				Quote:      "usdt",
+				Source:     "kucoin",
+				SourceUsdt: "nobitex",
+				Value:      60000,
+				Timestamp:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				Stats:      tt.stats,
+			}
+
+			data, err := json.Marshal(NewPriceResponse(want, want.Timestamp.Add(time.Second), time.Minute))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if got := strings.Contains(string(data), `"stats"`); got != (tt.stats != nil) {
+				t.Errorf("payload %s has stats = %t, want %t", data, got, tt.stats != nil)
+			}
+
+			var response PriceResponse
+			if err := json.Unmarshal(data, &response); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got := response.ToPrice(); !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestNewPriceResponseNote(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		want      string
+	}{
+		{"fresh", now.Add(-5 * time.Second), ""},
+		{"outdated", now.Add(-time.Minute), PriceOutdatedNote},
+		{"unknown age", time.Time{}, PriceOutdatedNote},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := NewPriceResponse(Price{Value: 1, Timestamp: tt.timestamp}, now, 20*time.Second)
+			if response.Note != tt.want {
+				t.Errorf("Note = %q, want %q", response.Note, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarketStatsConvert(t *testing.T) {
+	stats := MarketStats{High: 2, Low: 1, Change: 0.5, ChangePercent: 25, Volume: 10, QuoteVolume: 15, Bid: 1.9, Ask: 2.1}
+	want := MarketStats{High: 20, Low: 10, Change: 5, ChangePercent: 25, Volume: 10, QuoteVolume: 150, Bid: 19, Ask: 21}
+	if got := stats.Convert(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("Convert(10) = %+v, want %+v", got, want)
+	}
+}
@@ -1,24 +1,129 @@
 package models
 
+import "time"
 
+// Price is the canonical price quote shared by the HTTP, gRPC and storage
+// layers. Transport-specific shapes are derived from it through the explicit
+// mapping functions below and in the server package.
+type Price struct {
+	Symbol     string
+	Base       string
+	Quote      string
+	Source     string
+	SourceUsdt string
+	Value      float64
+	// Timestamp is the time of the oldest input the price was derived from.
+	Timestamp time.Time
+	// Stats are the 24h statistics of the market, in Quote, when the
+	// exchange reported them.
+	Stats *MarketStats
+}
+
+// MarketStats are the 24h statistics of a market. All fields but Volume and
+// ChangePercent are in the quote currency of the price they belong to.
+type MarketStats struct {
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+	// Volume is the traded volume in the base currency and QuoteVolume its
+	// value in the quote currency.
+	Volume      float64 `json:"volume"`
+	QuoteVolume float64 `json:"quote_volume"`
+	Bid         float64 `json:"bid,omitempty"`
+	Ask         float64 `json:"ask,omitempty"`
+}
+
+// Convert returns the statistics with every price-based field multiplied by
+// rate, e.g. to express USDT statistics in IRR.
+func (s MarketStats) Convert(rate float64) MarketStats {
+	s.High *= rate
+	s.Low *= rate
+	s.Change *= rate
+	s.QuoteVolume *= rate
+	s.Bid *= rate
+	s.Ask *= rate
+	return s
+}
+
+// Elapsed returns the age of the price relative to now.
+func (p Price) Elapsed(now time.Time) time.Duration {
+	return now.Sub(p.Timestamp)
+}
+
+// PriceResponse is the JSON representation of a Price.
 type PriceResponse struct {
-    Symbol  string  `json:"symbol"`
-    Source  string  `json:"source"`
-    Price   float64 `json:"price"`
-    Elapsed float64 `json:"elapsed"`
-    Note    string  `json:"note,omitempty"`
+	Symbol     string    `json:"symbol"`
+	Base       string    `json:"base"`
+	Source     string    `json:"source"`
+	Price      float64   `json:"price"`
+	Elapsed    float64   `json:"elapsed"`
+	Timestamp  time.Time `json:"timestamp"`
+	SourceUsdt string    `json:"source_usdt"`
+	Quote      string    `json:"quote"`
+	Note       string    `json:"note,omitempty"`
+	// Stats are only included on request.
+	Stats *MarketStats `json:"stats,omitempty"`
+}
+
+// NewPriceResponse maps a Price to its JSON representation. Prices older than
+// freshness are annotated with a note.
+func NewPriceResponse(p Price, now time.Time, freshness time.Duration) PriceResponse {
+	elapsed := p.Elapsed(now)
+
+	response := PriceResponse{
+		Symbol:     p.Symbol,
+		Base:       p.Base,
+		Source:     p.Source,
+		Price:      p.Value,
+		Elapsed:    elapsed.Seconds(),
+		Timestamp:  p.Timestamp,
+		SourceUsdt: p.SourceUsdt,
+		Quote:      p.Quote,
+		Stats:      p.Stats,
+	}
+
+	if elapsed > freshness {
+		response.Note = PriceOutdatedNote
+	}
+
+	return response
+}
+
+// ToPrice maps a JSON response back to the domain model.
+func (r PriceResponse) ToPrice() Price {
+	return Price{
+		Symbol:     r.Symbol,
+		Base:       r.Base,
+		Quote:      r.Quote,
+		Source:     r.Source,
+		SourceUsdt: r.SourceUsdt,
+		Value:      r.Price,
+		Timestamp:  r.Timestamp,
+		Stats:      r.Stats,
+	}
 }
 
+// PriceOutdatedNote is attached to responses whose price is older than the
+// freshness threshold.
+const PriceOutdatedNote = "Price may be outdated."
 
 type Transaction struct {
 	Price  interface{} `bson:"price"`
 	Amount interface{} `bson:"amount"`
 }
 
+// MarketSourceResult holds the USDT/IRR statistics computed from recent trades
+// of one market on one source.
 type MarketSourceResult struct {
-	MarketName   string
-	Source       string
-	Median       float64
-	WeightedMean float64
-	SumAmounts   float64
+	MarketName   string    `json:"market_name"`
+	Source       string    `json:"source"`
+	Median       float64   `json:"median"`
+	WeightedMean float64   `json:"weighted_mean"`
+	StdDev       float64   `json:"std_dev"`
+	SumAmounts   float64   `json:"sum_amounts"`
+	Trades       int       `json:"trades"`
+	ComputedAt   time.Time `json:"computed_at"`
+	WindowStart  time.Time `json:"window_start"`
+	WindowEnd    time.Time `json:"window_end"`
 }
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// TrackedSymbol is an asset the service ingests prices for, together with the
+// symbol each exchange lists it under (e.g. KuCoin "XBT" for Binance "BTC").
+type TrackedSymbol struct {
+	Asset     string            `bson:"_id" json:"asset"`
+	Exchanges map[string]string `bson:"exchanges" json:"exchanges"`
+	Enabled   bool              `bson:"enabled" json:"enabled"`
+	CreatedAt time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time         `bson:"updated_at" json:"updated_at"`
+
+	// Optional metadata surfaced through the asset registry.
+	Name     string   `bson:"name,omitempty" json:"name,omitempty"`
+	Decimals int      `bson:"decimals,omitempty" json:"decimals,omitempty"`
+	Aliases  []string `bson:"aliases,omitempty" json:"aliases,omitempty"`
+	Quotes   []string `bson:"quotes,omitempty" json:"quotes,omitempty"`
+}
+
+// Asset is an entry of the asset registry: a canonical ticker with display
+// metadata, alternative tickers clients may use, the symbol each exchange
+// lists it under and the quote currencies it can be priced in. An empty
+// Quotes list means every supported quote.
+type Asset struct {
+	Ticker    string            `json:"ticker"`
+	Name      string            `json:"name,omitempty"`
+	Decimals  int               `json:"decimals"`
+	Aliases   []string          `json:"aliases,omitempty"`
+	Exchanges map[string]string `json:"exchanges"`
+	Quotes    []string          `json:"quotes,omitempty"`
+}
+
+// SupportsQuote reports whether the asset can be priced in quote.
+func (a Asset) SupportsQuote(quote string) bool {
+	if len(a.Quotes) == 0 {
+		return true
+	}
+	for _, q := range a.Quotes {
+		if strings.EqualFold(q, quote) {
+			return true
+		}
+	}
+	return false
+}
+
+// Symbol audit actions.
+const (
+	SymbolActionAdd     = "add"
+	SymbolActionEnable  = "enable"
+	SymbolActionDisable = "disable"
+)
+
+// SymbolAuditEntry records a change made to a tracked symbol.
+type SymbolAuditEntry struct {
+	Asset  string         `bson:"asset" json:"asset"`
+	Action string         `bson:"action" json:"action"`
+	Actor  string         `bson:"actor" json:"actor"`
+	Before *TrackedSymbol `bson:"before,omitempty" json:"before,omitempty"`
+	After  *TrackedSymbol `bson:"after,omitempty" json:"after,omitempty"`
+	At     time.Time      `bson:"at" json:"at"`
+}
+
+// SymbolFailure tracks the consecutive failed price fetches of an ingested
+// symbol. Suppressed symbols are not fetched until re-enabled.
+type SymbolFailure struct {
+	Source              string     `json:"source"`
+	Symbol              string     `json:"symbol"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error"`
+	FirstFailedAt       time.Time  `json:"first_failed_at"`
+	LastFailedAt        time.Time  `json:"last_failed_at"`
+	Suppressed          bool       `json:"suppressed"`
+	SuppressedAt        *time.Time `json:"suppressed_at,omitempty"`
+}
@@ -0,0 +1,135 @@
+package reporting
+
+import (
+	"context"
+	"crypto_price/pkg/api"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/getsentry/sentry-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// HTTPHandler recovers from panics in next, answering 500, and reports them
+// to Sentry together with a transaction named after route for every request.
+func HTTPHandler(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		if Enabled() {
+			hub := sentry.CurrentHub().Clone()
+			hub.Scope().SetRequest(r)
+			ctx = sentry.SetHubOnContext(ctx, hub)
+			tx := sentry.StartTransaction(ctx, r.Method+" "+route,
+				sentry.WithOpName("http.server"),
+				sentry.ContinueFromRequest(r),
+				sentry.WithTransactionSource(sentry.SourceRoute),
+			)
+			ctx = tx.Context()
+			defer func() {
+				tx.Status = sentry.HTTPtoSpanStatus(rec.status)
+				tx.Finish()
+			}()
+		}
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			recovered(ctx, route, p)
+			if !rec.wroteHeader {
+				api.WriteError(rec, http.StatusInternalServerError, api.CodeInternal, "internal error", nil)
+			}
+		}()
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+	})
+}
+
+// UnaryServerInterceptor is the gRPC counterpart of HTTPHandler. Panics are
+// answered with codes.Internal; calls failing with an internal error are
+// reported as well.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if Enabled() {
+			var trace, baggage string
+			if md, ok := metadata.FromIncomingContext(ctx); ok {
+				trace, baggage = first(md.Get(sentry.SentryTraceHeader)), first(md.Get(sentry.SentryBaggageHeader))
+			}
+			ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+			tx := sentry.StartTransaction(ctx, info.FullMethod,
+				sentry.WithOpName("grpc.server"),
+				sentry.ContinueFromHeaders(trace, baggage),
+				sentry.WithTransactionSource(sentry.SourceRoute),
+			)
+			ctx = tx.Context()
+			defer func() {
+				// Span statuses follow the gRPC codes, offset by Undefined.
+				tx.Status = sentry.SpanStatus(status.Code(err) + 1)
+				tx.Finish()
+			}()
+		}
+
+		defer func() {
+			if p := recover(); p != nil {
+				recovered(ctx, info.FullMethod, p)
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+
+		resp, err = handler(ctx, req)
+		switch status.Code(err) {
+		case codes.Internal, codes.Unknown, codes.DataLoss:
+			CaptureError(ctx, err, nil)
+		}
+		return resp, err
+	}
+}
+
+// recovered logs and reports a panic raised while serving route.
+func recovered(ctx context.Context, route string, p interface{}) {
+	slog.ErrorContext(ctx, "Recovered from panic", "route", route, "panic", p, "stack", string(debug.Stack()))
+	if Enabled() {
+		hubFromContext(ctx).RecoverWithContext(ctx, p)
+	}
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,113 @@
+// Package reporting sends errors, panics and performance transactions to
+// Sentry. Every function is a no-op, apart from panic recovery, when Sentry
+// is not configured.
+package reporting
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Tags attached to captured errors.
+const (
+	TagSource = "source"
+	TagSymbol = "symbol"
+	TagJob    = "job"
+)
+
+var enabled atomic.Bool
+
+// Init sets up the Sentry client when cfg has a DSN.
+func Init(cfg config.SentryConfig) error {
+	if cfg.DSN == "" {
+		slog.Info("Sentry DSN not provided, skipping Sentry initialization")
+		return nil
+	}
+
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		EnableTracing:    true,
+		TracesSampleRate: cfg.TracesSampleRate,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize Sentry: %w", err)
+	}
+	enabled.Store(true)
+	slog.Info("Sentry initialized successfully")
+	return nil
+}
+
+// Enabled reports whether events are sent to Sentry.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Flush waits up to timeout for buffered events to be sent.
+func Flush(timeout time.Duration) {
+	if Enabled() && !sentry.Flush(timeout) {
+		slog.Warn("Timed out sending buffered Sentry events")
+	}
+}
+
+// CaptureError reports err with tags, using the hub of ctx so the event is
+// linked to the request or job run in progress.
+func CaptureError(ctx context.Context, err error, tags map[string]string) {
+	if !Enabled() || err == nil {
+		return
+	}
+	hub := hubFromContext(ctx)
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		hub.CaptureException(err)
+	})
+}
+
+// StartJob starts a transaction for a run of job. The returned function
+// finishes it, reporting err if the run failed.
+func StartJob(ctx context.Context, job string) (context.Context, func(err error)) {
+	if !Enabled() {
+		return ctx, func(error) {}
+	}
+
+	hub := sentry.CurrentHub().Clone()
+	hub.Scope().SetTag(TagJob, job)
+	ctx = sentry.SetHubOnContext(ctx, hub)
+	tx := sentry.StartTransaction(ctx, "job "+job,
+		sentry.WithOpName("job"),
+		sentry.WithTransactionSource(sentry.SourceTask),
+	)
+	return tx.Context(), func(err error) {
+		tx.Status = sentry.SpanStatusOK
+		if err != nil {
+			tx.Status = sentry.SpanStatusInternalError
+			CaptureError(tx.Context(), err, map[string]string{TagJob: job})
+		}
+		tx.Finish()
+	}
+}
+
+// SetTag tags the events and transaction of the request or job run of ctx.
+func SetTag(ctx context.Context, key, value string) {
+	if !Enabled() {
+		return
+	}
+	if hub := sentry.GetHubFromContext(ctx); hub != nil {
+		hub.Scope().SetTag(key, value)
+	}
+	if tx := sentry.TransactionFromContext(ctx); tx != nil {
+		tx.SetTag(key, value)
+	}
+}
+
+func hubFromContext(ctx context.Context) *sentry.Hub {
+	if hub := sentry.GetHubFromContext(ctx); hub != nil {
+		return hub
+	}
+	return sentry.CurrentHub()
+}
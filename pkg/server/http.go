@@ -1,46 +1,160 @@
 package server
 
 import (
+	"context"
+	"crypto_price/pkg/api"
+	"crypto_price/pkg/auth"
 	"crypto_price/pkg/config"
 	"crypto_price/pkg/controller"
 	"crypto_price/pkg/health"
-	"log"
+	"crypto_price/pkg/logging"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/reporting"
+	"crypto_price/pkg/tracing"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/http/pprof"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// HTTPServer serves the REST API, health checks and metrics.
+type HTTPServer struct {
+    cfg        *config.Config
+    reloader   *config.Reloader
+    auth       *auth.Authenticator
+    controller *controller.Controller
+    health     *health.Checker
+}
+
+// NewHTTPServer returns an HTTPServer routing requests to the given handlers.
+func NewHTTPServer(cfg *config.Config, reloader *config.Reloader, authn *auth.Authenticator, ctrl *controller.Controller, checker *health.Checker) *HTTPServer {
+    return &HTTPServer{cfg: cfg, reloader: reloader, auth: authn, controller: ctrl, health: checker}
+}
+
+// ListenAndServe serves on the configured port until ctx is done, then shuts
+// down gracefully.
+func (s *HTTPServer) ListenAndServe(ctx context.Context) error {
+    srv := &http.Server{
+        Addr:    ":" + s.cfg.Server.Port,
+        Handler: s.Handler(),
+    }
+
+    errc := make(chan error, 1)
+    go func() {
+        slog.Info("Starting HTTP server", "addr", srv.Addr)
+        errc <- srv.ListenAndServe()
+    }()
 
-func StartHTTPServer() {
-    cfg := config.GetConfigs()
+    select {
+    case err := <-errc:
+        return fmt.Errorf("failed to start server: %w", err)
+    case <-ctx.Done():
+    }
 
-    registerMetrics()
-    http.Handle("/metrics", promhttp.Handler())
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.Server.ShutdownTimeout)
+    defer cancel()
+    return srv.Shutdown(shutdownCtx)
+}
 
-    http.HandleFunc("/health", health.HandleHealthCheck)
-    http.HandleFunc("/health/live", health.HandleLiveness)
-    http.HandleFunc("/health/ready", health.HandleReadiness)
+// Handler returns the router of every endpoint. It registers the collectors
+// /metrics exports.
+func (s *HTTPServer) Handler() http.Handler {
+    s.registerMetrics()
+    mux := http.NewServeMux()
 
-    http.HandleFunc("/price", controller.HandlePriceRequest)
+    route(mux, "/metrics", s.auth.RequireScope(auth.ScopeMetricsRead, promhttp.Handler()))
 
-    addr := ":" + cfg.ServerPort
-    log.Printf("Starting HTTP server on %s", addr)
-    if err := http.ListenAndServe(addr, nil); err != nil {
-        log.Fatalf("Failed to start server: %v", err)
+    // Profiles expose memory contents and command lines, so they are opt-in
+    // and, when keys are checked, only served to admin keys.
+    if s.cfg.Server.Pprof {
+        mux.Handle("/debug/pprof/", s.auth.RequireScope(auth.ScopeAdmin, http.HandlerFunc(pprof.Index)))
+        mux.Handle("/debug/pprof/cmdline", s.auth.RequireScope(auth.ScopeAdmin, http.HandlerFunc(pprof.Cmdline)))
+        mux.Handle("/debug/pprof/profile", s.auth.RequireScope(auth.ScopeAdmin, http.HandlerFunc(pprof.Profile)))
+        mux.Handle("/debug/pprof/symbol", s.auth.RequireScope(auth.ScopeAdmin, http.HandlerFunc(pprof.Symbol)))
+        mux.Handle("/debug/pprof/trace", s.auth.RequireScope(auth.ScopeAdmin, http.HandlerFunc(pprof.Trace)))
     }
+
+    route(mux, "/health", http.HandlerFunc(s.health.HandleHealthCheck))
+    route(mux, "/health/live", http.HandlerFunc(health.HandleLiveness))
+    route(mux, "/health/ready", http.HandlerFunc(s.health.HandleReadiness))
+    route(mux, "/status/freshness", http.HandlerFunc(s.health.HandleFreshness))
+
+    route(mux, "/price", s.auth.RequireScope(auth.ScopePriceRead, http.HandlerFunc(s.controller.HandlePriceRequest)))
+
+    route(mux, "/assets", s.auth.RequireScope(auth.ScopePriceRead, api.Get(s.controller.HandleAssets)))
+
+    mux.Handle("/v1/", s.newV1Router())
+
+    return logging.HTTPMiddleware(mux)
 }
 
+// newV1Router builds the versioned API. Every route answers with JSON and
+// reports errors using the api error envelope.
+func (s *HTTPServer) newV1Router() *http.ServeMux {
+    mux := http.NewServeMux()
+
+    route(mux, "/v1/price", s.auth.RequireScope(auth.ScopePriceRead, api.Get(s.controller.HandlePriceRequestV1)))
+    route(mux, "/v1/assets", s.auth.RequireScope(auth.ScopePriceRead, api.Get(s.controller.HandleAssets)))
+    route(mux, "/v1/health", api.Get(s.health.HandleHealthCheck))
+    route(mux, "/v1/health/live", api.Get(health.HandleLiveness))
+    route(mux, "/v1/health/ready", api.Get(s.health.HandleReadiness))
+    route(mux, "/v1/status/freshness", api.Get(s.health.HandleFreshness))
+    route(mux, "/v1/openapi.json", api.Get(api.HandleOpenAPI))
+
+    // The admin API changes what the service ingests, so it is only exposed
+    // when callers can be authenticated.
+    if s.auth.Enabled() {
+        route(mux, "/v1/admin/symbols", s.auth.RequireScope(auth.ScopeAdmin, http.HandlerFunc(s.controller.HandleAdminSymbols)))
+        route(mux, "/v1/admin/symbols/", s.auth.RequireScope(auth.ScopeAdmin, http.HandlerFunc(s.controller.HandleAdminSymbol)))
+        route(mux, "/v1/admin/symbol-failures", s.auth.RequireScope(auth.ScopeAdmin, api.Get(s.controller.HandleSymbolFailures)))
+        route(mux, "/v1/admin/symbol-failures/", s.auth.RequireScope(auth.ScopeAdmin, http.HandlerFunc(s.controller.HandleSymbolFailure)))
+        route(mux, "/v1/admin/config", s.auth.RequireScope(auth.ScopeAdmin, api.Get(s.handleAdminConfig)))
+    } else {
+        slog.Warn("Authentication disabled, admin API is not exposed")
+    }
+    route(mux, "/v1/", http.HandlerFunc(api.NotFound))
 
+    return mux
+}
 
-func registerMetrics() {
-    goCollector := collectors.NewGoCollector()
-    if err := prometheus.Register(goCollector); err != nil {
-        if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
-            log.Printf("Failed to register Go collector: %v", err)
-            return
+// route registers h on mux under pattern, tracing its requests, recording
+// them in the HTTP metrics and Sentry, and recovering from its panics.
+func route(mux *http.ServeMux, pattern string, h http.Handler) {
+    h = reporting.HTTPHandler(pattern, h)
+    h = metrics.InstrumentHandler(pattern, h)
+    mux.Handle(pattern, tracing.HTTPHandler(pattern, h))
+}
+
+// handleAdminConfig serves /v1/admin/config: the version and the redacted
+// values of the configuration in effect.
+func (s *HTTPServer) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+    doc, err := s.reloader.Current().Document()
+    if err != nil {
+        slog.ErrorContext(r.Context(), "Error encoding configuration", "error", err)
+        api.WriteError(w, http.StatusInternalServerError, api.CodeInternal, "failed to encode configuration", nil)
+        return
+    }
+    api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+        "version": s.reloader.Version(),
+        "config":  doc,
+    })
+}
+
+func (s *HTTPServer) registerMetrics() {
+    toRegister := []prometheus.Collector{collectors.NewGoCollector()}
+    toRegister = append(toRegister, s.controller.CacheCollectors()...)
+    toRegister = append(toRegister, metrics.Collectors()...)
+    for _, collector := range toRegister {
+        if err := prometheus.Register(collector); err != nil {
+            if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+                slog.Error("Failed to register collector", "error", err)
+                return
+            }
         }
     }
-    log.Println("Prometheus metrics registered successfully")
-}
\ No newline at end of file
+    slog.Info("Prometheus metrics registered successfully")
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto_price/pkg/models"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// priceToProto maps the domain price to its protobuf representation. It goes
+// through models.NewPriceResponse so the JSON and gRPC surfaces derive elapsed
+// time and notes the same way.
+func priceToProto(p models.Price, now time.Time, freshness time.Duration) *PriceResponse {
+	response := models.NewPriceResponse(p, now, freshness)
+
+	return &PriceResponse{
+		Price:      response.Price,
+		Symbol:     response.Symbol,
+		Base:       response.Base,
+		Quote:      response.Quote,
+		Source:     response.Source,
+		SourceUsdt: response.SourceUsdt,
+		Timestamp:  timestamppb.New(response.Timestamp),
+		Elapsed:    response.Elapsed,
+		Note:       response.Note,
+		Stats:      statsToProto(response.Stats),
+	}
+}
+
+func statsToProto(s *models.MarketStats) *MarketStats {
+	if s == nil {
+		return nil
+	}
+	return &MarketStats{
+		High:          s.High,
+		Low:           s.Low,
+		Change:        s.Change,
+		ChangePercent: s.ChangePercent,
+		Volume:        s.Volume,
+		QuoteVolume:   s.QuoteVolume,
+		Bid:           s.Bid,
+		Ask:           s.Ask,
+	}
+}
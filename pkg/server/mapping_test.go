@@ -0,0 +1,70 @@
+package server
+
+import (
+	"crypto_price/pkg/models"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPriceToProtoRoundTrip(t *testing.T) {
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC)
+	price := models.Price{
+		Symbol:     "BTCUSDT",
+		Base:       "BTC",
+		Quote:      "irr",
+		Source:     "kucoin",
+		SourceUsdt: "nobitex",
+		Value:      60000,
+		Timestamp:  timestamp,
+	}
+
+	tests := []struct {
+		name  string
+		stats *models.MarketStats
+		want  *MarketStats
+	}{
+		{"without stats", nil, nil},
+		{
+			"with stats",
+			&models.MarketStats{High: 61000, Low: 59000, Change: 600, ChangePercent: 1.01, Volume: 1234.5, QuoteVolume: 74070000, Bid: 59999, Ask: 60001},
+			&MarketStats{High: 61000, Low: 59000, Change: 600, ChangePercent: 1.01, Volume: 1234.5, QuoteVolume: 74070000, Bid: 59999, Ask: 60001},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price := price
+			price.Stats = tt.stats
+
+			data, err := proto.Marshal(priceToProto(price, timestamp.Add(time.Minute), 20*time.Second))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var got PriceResponse
+			if err := proto.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+
+			want := &PriceResponse{
+				Price:      60000,
+				Symbol:     "BTCUSDT",
+				Base:       "BTC",
+				Quote:      "irr",
+				Source:     "kucoin",
+				SourceUsdt: "nobitex",
+				Timestamp:  timestamppb.New(timestamp),
+				Elapsed:    60,
+				Note:       models.PriceOutdatedNote,
+				Stats:      tt.want,
+			}
+			if !proto.Equal(&got, want) {
+				t.Errorf("round trip = %v, want %v", &got, want)
+			}
+			if !got.GetTimestamp().AsTime().Equal(timestamp) {
+				t.Errorf("timestamp = %v, want %v", got.GetTimestamp().AsTime(), timestamp)
+			}
+		})
+	}
+}
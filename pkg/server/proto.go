@@ -2,41 +2,99 @@ package server
 
 import (
 	"context"
-	"crypto_price/pkg/exchanges"
-	"log"
+	"crypto_price/pkg/auth"
+	"crypto_price/pkg/controller"
+	"crypto_price/pkg/logging"
+	"crypto_price/pkg/metrics"
+	"crypto_price/pkg/reporting"
+	"crypto_price/pkg/tracing"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-func StartServer() {
-    lis, err := net.Listen("tcp", ":50051")
+// NewGRPCServer returns a gRPC server exposing the price service.
+func NewGRPCServer(authn *auth.Authenticator, ctrl *controller.Controller) *grpc.Server {
+    s := grpc.NewServer(tracing.ServerOption(), grpc.ChainUnaryInterceptor(
+        logging.UnaryServerInterceptor(),
+        metrics.UnaryServerInterceptor(),
+        reporting.UnaryServerInterceptor(),
+        authn.UnaryServerInterceptor(map[string]string{
+            CryptoPriceService_GetCryptoPrice_FullMethodName: auth.ScopePriceRead,
+        }),
+    ))
+    RegisterCryptoPriceServiceServer(s, &server{controller: ctrl})
+    return s
+}
+
+// ServeGRPC serves srv on port until ctx is done, then stops it gracefully.
+// Calls still running after timeout are cancelled.
+func ServeGRPC(ctx context.Context, srv *grpc.Server, port string, timeout time.Duration) error {
+    lis, err := net.Listen("tcp", ":"+port)
     if err != nil {
-        log.Fatalf("failed to listen: %v", err)
+        return fmt.Errorf("failed to listen for gRPC on port %s: %w", port, err)
     }
-    s := grpc.NewServer()
-    RegisterCryptoPriceServiceServer(s, &server{})
-    if err := s.Serve(lis); err != nil {
-        log.Fatalf("failed to serve: %v", err)
+
+    errc := make(chan error, 1)
+    go func() {
+        slog.Info("Starting gRPC server", "addr", lis.Addr().String())
+        errc <- srv.Serve(lis)
+    }()
+
+    select {
+    case err := <-errc:
+        return fmt.Errorf("failed to serve gRPC: %w", err)
+    case <-ctx.Done():
     }
+
+    stopped := make(chan struct{})
+    go func() {
+        srv.GracefulStop()
+        close(stopped)
+    }()
+    select {
+    case <-stopped:
+    case <-time.After(timeout):
+        slog.Warn("gRPC calls still running after shutdown timeout, cancelling them")
+        srv.Stop()
+    }
+    return nil
 }
+
 type server struct {
     CryptoPriceServiceServer
+    controller *controller.Controller
 }
 func (s *server) GetCryptoPrice(ctx context.Context, req *PriceRequest) (*PriceResponse, error) {
-    price, err := s.fetchPrice(req.Base, req.Quote, req.Source)
-	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "failed to fetch price: %v", err)
-	}
-    return &PriceResponse{Price: price}, nil
-}
+    base, source, quote, sourceUsdt, err := controller.ValidateParams(req.Base, req.Source, req.Quote, req.SourceUsdt)
+    if err != nil {
+        return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+    }
+
+    base, err = s.controller.ResolveAsset(ctx, base, quote)
+    if err != nil {
+        var unknownErr *controller.UnknownAssetError
+        if errors.As(err, &unknownErr) {
+            return nil, status.Errorf(codes.NotFound, "%v", err)
+        }
+        return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+    }
 
-func (s *server) fetchPrice(base, quote, source string) (float64, error) {
-	price, err := exchanges.GetCryptoPrice(source, base, quote)
-	if err != nil {
-		return 0, err
-	}
-	return price, nil	
-}
\ No newline at end of file
+    price, err := s.controller.FetchPrice(ctx, base, source, quote, sourceUsdt)
+    if err != nil {
+        if errors.Is(err, controller.ErrPriceNotAvailable) {
+            return nil, status.Errorf(codes.NotFound, "price not found: %v", err)
+        }
+        return nil, status.Errorf(codes.Unavailable, "failed to fetch price: %v", err)
+    }
+    if !req.GetIncludeStats() {
+        price.Stats = nil
+    }
+    return priceToProto(price, time.Now(), s.controller.Freshness()), nil
+}
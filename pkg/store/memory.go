@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"crypto_price/pkg/models"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps prices in process memory with the same tiers and
+// expirations as the Redis store. It is meant for local development and
+// tests, where the ingestion jobs and the API run in the same process.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	prices      map[string]PricePoint
+	usdtIrr     map[string]models.MarketSourceResult
+	usdtIrrAt   map[string]time.Time
+	subscribers map[chan string]struct{}
+	expirations
+
+	// now is replaceable so tests can move time forward.
+	now func() time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore(exp Expirations) *MemoryStore {
+	s := &MemoryStore{
+		prices:      make(map[string]PricePoint),
+		usdtIrr:     make(map[string]models.MarketSourceResult),
+		usdtIrrAt:   make(map[string]time.Time),
+		subscribers: make(map[chan string]struct{}),
+		now:         time.Now,
+	}
+	s.SetExpirations(exp)
+	return s
+}
+
+func (s *MemoryStore) Name() string {
+	return BackendMemory
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) PutPrices(ctx context.Context, source string, prices map[string]float64, stats map[string]models.MarketStats, at time.Time) error {
+	s.mu.Lock()
+	for symbol, price := range prices {
+		point := PricePoint{Price: price, Timestamp: at}
+		if symbolStats, ok := stats[symbol]; ok {
+			point.Stats = &symbolStats
+		}
+		s.prices[PriceKey(source, symbol)] = point
+	}
+	s.mu.Unlock()
+
+	s.publish(SourcePrefix(source))
+	return nil
+}
+
+func (s *MemoryStore) GetPrice(ctx context.Context, source, symbol string) (PricePoint, error) {
+	s.mu.RLock()
+	point, ok := s.prices[PriceKey(source, symbol)]
+	s.mu.RUnlock()
+
+	if !ok {
+		return PricePoint{}, fmt.Errorf("%w for %s from %s", ErrNotFound, symbol, source)
+	}
+
+	age, exp := s.now().Sub(point.Timestamp), s.exp()
+	switch {
+	case age < exp.ShortTerm:
+		point.Tier = TierShort
+	case age < exp.LongTerm:
+		point.Tier = TierLong
+	default:
+		return PricePoint{}, fmt.Errorf("%w for %s from %s", ErrNotFound, symbol, source)
+	}
+	return point, nil
+}
+
+func (s *MemoryStore) GetPrices(ctx context.Context, source string, symbols []string) (map[string]PricePoint, error) {
+	points := make(map[string]PricePoint, len(symbols))
+	for _, symbol := range symbols {
+		if point, err := s.GetPrice(ctx, source, symbol); err == nil {
+			points[symbol] = point
+		}
+	}
+	return points, nil
+}
+
+func (s *MemoryStore) PutUsdtIrr(ctx context.Context, result models.MarketSourceResult) error {
+	s.mu.Lock()
+	s.usdtIrr[result.Source] = result
+	s.usdtIrrAt[result.Source] = s.now()
+	s.mu.Unlock()
+
+	s.publish(UsdtIrrKey(result.Source))
+	return nil
+}
+
+func (s *MemoryStore) GetUsdtIrr(ctx context.Context, source string) (models.MarketSourceResult, error) {
+	s.mu.RLock()
+	result, ok := s.usdtIrr[source]
+	storedAt := s.usdtIrrAt[source]
+	s.mu.RUnlock()
+
+	if !ok || s.now().Sub(storedAt) >= s.exp().UsdtIrr {
+		return models.MarketSourceResult{}, fmt.Errorf("%w (key: %s)", ErrNotFound, UsdtIrrKey(source))
+	}
+	if result.ComputedAt.IsZero() {
+		result.ComputedAt = storedAt
+	}
+	return result, nil
+}
+
+// Subscribe registers a buffered channel for update notifications. Updates
+// are dropped for subscribers that fall behind, like Redis pub/sub does for
+// slow clients.
+func (s *MemoryStore) Subscribe(ctx context.Context) (<-chan string, error) {
+	updates := make(chan string, 64)
+
+	s.mu.Lock()
+	s.subscribers[updates] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, updates)
+		close(updates)
+		s.mu.Unlock()
+	}()
+	return updates, nil
+}
+
+func (s *MemoryStore) publish(prefix string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for updates := range s.subscribers {
+		select {
+		case updates <- prefix:
+		default:
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package store
+
+import (
+	"context"
+	"crypto_price/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// UpdatesChannel is the pub/sub channel the Redis store publishes to after
+// writing prices. Each message is a prefix of the keys that changed.
+const UpdatesChannel = "price-updates"
+
+// Price storage layouts selectable with config.RedisPriceLayout.
+const (
+	// PriceLayoutLegacy writes four string keys per symbol:
+	// <source>:<symbol>:{short,long} and their :time companions.
+	PriceLayoutLegacy = "legacy"
+	// PriceLayoutHash writes one hash per symbol at PriceHashKey.
+	PriceLayoutHash = "hash"
+	// PriceLayoutBoth writes both layouts, for rollouts where readers that
+	// only understand the legacy layout are still running.
+	PriceLayoutBoth = "both"
+)
+
+// Fields of the per-symbol price hash. The key expires with the long-term
+// tier; short_until marks when the price leaves the short-term tier. stats
+// holds the 24h statistics as JSON, when known.
+const (
+	PriceHashFieldPrice      = "price"
+	PriceHashFieldTime       = "ts"
+	PriceHashFieldShortUntil = "short_until"
+	PriceHashFieldStats      = "stats"
+)
+
+// PriceHashKey is the key of the hash holding the latest price of symbol from source.
+func PriceHashKey(source, symbol string) string {
+	return fmt.Sprintf("price:%s:%s", source, symbol)
+}
+
+// RedisStore keeps prices in Redis and announces updates on UpdatesChannel,
+// so every API replica sees what the ingestion jobs wrote.
+type RedisStore struct {
+	client redis.UniversalClient
+	layout string
+	expirations
+}
+
+// NewRedisStore returns a store writing prices with layout through client.
+func NewRedisStore(client redis.UniversalClient, layout string, exp Expirations) *RedisStore {
+	s := &RedisStore{client: client, layout: layout}
+	s.SetExpirations(exp)
+	return s
+}
+
+func (s *RedisStore) Name() string {
+	return BackendRedis
+}
+
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// PutPrices writes all prices of a source in a single transactional pipeline.
+// The legacy layout keeps statistics in a <source>:<symbol>:stats key that
+// expires with the long-term tier.
+func (s *RedisStore) PutPrices(ctx context.Context, source string, prices map[string]float64, stats map[string]models.MarketStats, at time.Time) error {
+	encoded := make(map[string][]byte, len(stats))
+	for symbol, symbolStats := range stats {
+		value, err := json.Marshal(symbolStats)
+		if err != nil {
+			return fmt.Errorf("failed to encode stats of %s for %s: %w", symbol, source, err)
+		}
+		encoded[symbol] = value
+	}
+
+	exp := s.exp()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for symbol, price := range prices {
+			statsValue, hasStats := encoded[symbol]
+			if s.layout != PriceLayoutLegacy {
+				hashKey := PriceHashKey(source, symbol)
+				pipe.HSet(ctx, hashKey,
+					PriceHashFieldPrice, price,
+					PriceHashFieldTime, at.Unix(),
+					PriceHashFieldShortUntil, at.Add(exp.ShortTerm).Unix(),
+				)
+				if hasStats {
+					pipe.HSet(ctx, hashKey, PriceHashFieldStats, statsValue)
+				} else {
+					pipe.HDel(ctx, hashKey, PriceHashFieldStats)
+				}
+				pipe.Expire(ctx, hashKey, exp.LongTerm)
+			}
+
+			if s.layout != PriceLayoutHash {
+				pipe.Set(ctx, fmt.Sprintf("%s:%s:long", source, symbol), price, exp.LongTerm)
+				pipe.Set(ctx, fmt.Sprintf("%s:%s:short", source, symbol), price, exp.ShortTerm)
+				pipe.Set(ctx, fmt.Sprintf("%s:%s:long:time", source, symbol), at.Unix(), exp.LongTerm)
+				pipe.Set(ctx, fmt.Sprintf("%s:%s:short:time", source, symbol), at.Unix(), exp.ShortTerm)
+				if hasStats {
+					pipe.Set(ctx, legacyStatsKey(source, symbol), statsValue, exp.LongTerm)
+				} else {
+					pipe.Del(ctx, legacyStatsKey(source, symbol))
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store %d prices for %s: %w", len(prices), source, err)
+	}
+
+	return s.publish(ctx, SourcePrefix(source))
+}
+
+func legacyStatsKey(source, symbol string) string {
+	return fmt.Sprintf("%s:%s:stats", source, symbol)
+}
+
+// GetPrice reads the per-symbol hash first and falls back to the legacy
+// string keys, so it works while writers are migrating between layouts.
+func (s *RedisStore) GetPrice(ctx context.Context, source, symbol string) (PricePoint, error) {
+	values, err := s.client.HMGet(ctx, PriceHashKey(source, symbol), priceHashFields...).Result()
+	if err != nil {
+		return PricePoint{}, fmt.Errorf("error retrieving price hash from Redis: %w", err)
+	}
+	if values[0] != nil {
+		return parseHashPrice(source, symbol, values)
+	}
+
+	points, err := s.getLegacyPrices(ctx, source, []string{symbol})
+	if err != nil {
+		return PricePoint{}, err
+	}
+	point, ok := points[symbol]
+	if !ok {
+		return PricePoint{}, fmt.Errorf("%w for %s from %s", ErrNotFound, symbol, source)
+	}
+	return point, nil
+}
+
+// GetPrices reads the hashes of all symbols in one pipeline and, like
+// GetPrice, falls back to the legacy keys of the symbols without a hash in a
+// second one.
+func (s *RedisStore) GetPrices(ctx context.Context, source string, symbols []string) (map[string]PricePoint, error) {
+	cmds := make([]*redis.SliceCmd, len(symbols))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, symbol := range symbols {
+			cmds[i] = pipe.HMGet(ctx, PriceHashKey(source, symbol), priceHashFields...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving price hashes from Redis: %w", err)
+	}
+
+	points := make(map[string]PricePoint, len(symbols))
+	var legacy []string
+	for i, symbol := range symbols {
+		values := cmds[i].Val()
+		if values[0] == nil {
+			legacy = append(legacy, symbol)
+			continue
+		}
+		point, err := parseHashPrice(source, symbol, values)
+		if err != nil {
+			return nil, err
+		}
+		points[symbol] = point
+	}
+	if len(legacy) == 0 {
+		return points, nil
+	}
+
+	legacyPoints, err := s.getLegacyPrices(ctx, source, legacy)
+	if err != nil {
+		return nil, err
+	}
+	for symbol, point := range legacyPoints {
+		points[symbol] = point
+	}
+	return points, nil
+}
+
+// priceHashFields are the fields read from a price hash, in the order
+// parseHashPrice expects them.
+var priceHashFields = []string{PriceHashFieldPrice, PriceHashFieldTime, PriceHashFieldShortUntil, PriceHashFieldStats}
+
+func parseHashPrice(source, symbol string, values []interface{}) (PricePoint, error) {
+	var point PricePoint
+
+	price, _ := values[0].(string)
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return point, fmt.Errorf("error parsing price for %s from %s: %w", symbol, source, err)
+	}
+	point.Price = value
+
+	ts, _ := values[1].(string)
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return point, fmt.Errorf("timestamp not available for %s from %s", symbol, source)
+	}
+	point.Timestamp = time.Unix(timestamp, 0)
+
+	point.Tier = TierLong
+	shortUntil, _ := values[2].(string)
+	if until, err := strconv.ParseInt(shortUntil, 10, 64); err == nil && time.Now().Unix() < until {
+		point.Tier = TierShort
+	}
+
+	if stats, ok := values[3].(string); ok {
+		point.Stats = decodeStats(source, symbol, stats)
+	}
+
+	return point, nil
+}
+
+// decodeStats decodes stored statistics. Statistics that cannot be decoded
+// are left out rather than failing the price read.
+func decodeStats(source, symbol, value string) *models.MarketStats {
+	var stats models.MarketStats
+	if err := json.Unmarshal([]byte(value), &stats); err != nil {
+		slog.Warn("Invalid stored stats", "source", source, "symbol", symbol, "error", err)
+		return nil
+	}
+	return &stats
+}
+
+// legacyPriceCmds are the reads of the legacy keys of one symbol.
+type legacyPriceCmds struct {
+	short, shortTime, long, longTime, stats *redis.StringCmd
+}
+
+// getLegacyPrices reads the prices of symbols from the legacy short/long
+// string keys in one pipeline. Symbols without a legacy price are missing
+// from the result.
+func (s *RedisStore) getLegacyPrices(ctx context.Context, source string, symbols []string) (map[string]PricePoint, error) {
+	cmds := make([]legacyPriceCmds, len(symbols))
+	// Missing keys fail their command with redis.Nil, so errors are checked
+	// per command.
+	s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, symbol := range symbols {
+			cmds[i] = legacyPriceCmds{
+				short:     pipe.Get(ctx, fmt.Sprintf("%s:%s:short", source, symbol)),
+				shortTime: pipe.Get(ctx, fmt.Sprintf("%s:%s:short:time", source, symbol)),
+				long:      pipe.Get(ctx, fmt.Sprintf("%s:%s:long", source, symbol)),
+				longTime:  pipe.Get(ctx, fmt.Sprintf("%s:%s:long:time", source, symbol)),
+				stats:     pipe.Get(ctx, legacyStatsKey(source, symbol)),
+			}
+		}
+		return nil
+	})
+
+	points := make(map[string]PricePoint, len(symbols))
+	for i, symbol := range symbols {
+		point, err := parseLegacyPrice(source, symbol, cmds[i])
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		points[symbol] = point
+	}
+	return points, nil
+}
+
+// parseLegacyPrice returns the short-term price of a symbol, or its
+// long-term price once the short-term keys have expired.
+func parseLegacyPrice(source, symbol string, cmds legacyPriceCmds) (PricePoint, error) {
+	point := PricePoint{Tier: TierShort}
+	price, timestamp := cmds.short, cmds.shortTime
+	if price.Err() == redis.Nil {
+		// Fall back to the long-term price
+		point.Tier = TierLong
+		price, timestamp = cmds.long, cmds.longTime
+		if price.Err() == redis.Nil {
+			return point, fmt.Errorf("%w for %s from %s", ErrNotFound, symbol, source)
+		}
+	}
+	if err := price.Err(); err != nil {
+		return point, fmt.Errorf("error retrieving %s-term price from Redis: %w", point.Tier, err)
+	}
+
+	value, err := strconv.ParseFloat(price.Val(), 64)
+	if err != nil {
+		return point, fmt.Errorf("error parsing price for %s from %s: %w", symbol, source, err)
+	}
+	point.Price = value
+
+	ts, err := timestamp.Int64()
+	switch {
+	case err == nil:
+		point.Timestamp = time.Unix(ts, 0)
+	case point.Tier == TierShort:
+		// The short-term price was written moments ago.
+		point.Timestamp = time.Now()
+	default:
+		return point, fmt.Errorf("timestamp not available for %s from %s", symbol, source)
+	}
+
+	if stats, err := cmds.stats.Result(); err == nil {
+		point.Stats = decodeStats(source, symbol, stats)
+	}
+
+	return point, nil
+}
+
+func (s *RedisStore) PutUsdtIrr(ctx context.Context, result models.MarketSourceResult) error {
+	value, err := models.EncodeMarketSourceResult(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result for %s: %w", result.Source, err)
+	}
+	if err := s.client.Set(ctx, UsdtIrrKey(result.Source), value, s.exp().UsdtIrr).Err(); err != nil {
+		return fmt.Errorf("failed to store USDTIRR price for %s: %w", result.Source, err)
+	}
+	return s.publish(ctx, UsdtIrrKey(result.Source))
+}
+
+func (s *RedisStore) GetUsdtIrr(ctx context.Context, source string) (models.MarketSourceResult, error) {
+	key := UsdtIrrKey(source)
+	value, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return models.MarketSourceResult{}, fmt.Errorf("%w (key: %s)", ErrNotFound, key)
+	}
+	if err != nil {
+		return models.MarketSourceResult{}, fmt.Errorf("failed to retrieve %s from Redis: %w", key, err)
+	}
+
+	result, err := models.DecodeMarketSourceResult(value)
+	if err != nil {
+		return models.MarketSourceResult{}, fmt.Errorf("failed to parse %s from Redis: %w", key, err)
+	}
+	if result.ComputedAt.IsZero() {
+		result.ComputedAt = s.storedAt(ctx, key)
+	}
+	return result, nil
+}
+
+// storedAt estimates when the USDT/IRR rate at key was written from the TTL
+// it has left. Keys without a TTL are assumed as old as a rate can get.
+func (s *RedisStore) storedAt(ctx context.Context, key string) time.Time {
+	now, ttl := time.Now(), s.exp().UsdtIrr
+	remaining, err := s.client.TTL(ctx, key).Result()
+	if err != nil || remaining <= 0 || remaining > ttl {
+		return now.Add(-ttl)
+	}
+	return now.Add(remaining - ttl)
+}
+
+// Subscribe listens on UpdatesChannel. The channel is closed when ctx is
+// done or the subscription drops; callers resubscribe as needed.
+func (s *RedisStore) Subscribe(ctx context.Context) (<-chan string, error) {
+	pubsub := s.client.Subscribe(ctx, UpdatesChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", UpdatesChannel, err)
+	}
+
+	updates := make(chan string)
+	go func() {
+		defer close(updates)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case updates <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return updates, nil
+}
+
+func (s *RedisStore) publish(ctx context.Context, prefix string) error {
+	if err := s.client.Publish(ctx, UpdatesChannel, prefix).Err(); err != nil {
+		return fmt.Errorf("failed to publish price update for %s: %w", prefix, err)
+	}
+	return nil
+}
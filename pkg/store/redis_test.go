@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"crypto_price/pkg/models"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+var testExpirations = Expirations{ShortTerm: time.Minute, LongTerm: time.Hour, UsdtIrr: time.Hour}
+
+func newTestRedisStore(t *testing.T, layout string) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client, layout, testExpirations), mr
+}
+
+func TestRedisStorePriceRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	at := time.Unix(time.Now().Unix(), 0)
+	stats := models.MarketStats{High: 61000, Low: 59000, Change: 600, ChangePercent: 1.01, Volume: 1234.5, QuoteVolume: 74070000, Bid: 59999, Ask: 60001}
+
+	for _, layout := range []string{PriceLayoutHash, PriceLayoutLegacy, PriceLayoutBoth} {
+		t.Run(layout, func(t *testing.T) {
+			s, _ := newTestRedisStore(t, layout)
+			prices := map[string]float64{"BTCUSDT": 60000, "ETHUSDT": 3000.5}
+			if err := s.PutPrices(ctx, "kucoin", prices, map[string]models.MarketStats{"BTCUSDT": stats}, at); err != nil {
+				t.Fatalf("PutPrices: %v", err)
+			}
+
+			want := map[string]PricePoint{
+				"BTCUSDT": {Price: 60000, Timestamp: at, Tier: TierShort, Stats: &stats},
+				"ETHUSDT": {Price: 3000.5, Timestamp: at, Tier: TierShort},
+			}
+			for symbol, wantPoint := range want {
+				got, err := s.GetPrice(ctx, "kucoin", symbol)
+				if err != nil {
+					t.Fatalf("GetPrice(%s): %v", symbol, err)
+				}
+				if !reflect.DeepEqual(got, wantPoint) {
+					t.Errorf("GetPrice(%s) = %+v, want %+v", symbol, got, wantPoint)
+				}
+			}
+
+			got, err := s.GetPrices(ctx, "kucoin", []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"})
+			if err != nil {
+				t.Fatalf("GetPrices: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GetPrices = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestRedisStoreClearsStats(t *testing.T) {
+	ctx := context.Background()
+	at := time.Unix(time.Now().Unix(), 0)
+
+	for _, layout := range []string{PriceLayoutHash, PriceLayoutLegacy} {
+		t.Run(layout, func(t *testing.T) {
+			s, _ := newTestRedisStore(t, layout)
+			prices := map[string]float64{"BTCUSDT": 60000}
+			if err := s.PutPrices(ctx, "kucoin", prices, map[string]models.MarketStats{"BTCUSDT": {High: 1}}, at); err != nil {
+				t.Fatalf("PutPrices: %v", err)
+			}
+			// A later price without stats must not keep the previous ones.
+			if err := s.PutPrices(ctx, "kucoin", prices, nil, at); err != nil {
+				t.Fatalf("PutPrices: %v", err)
+			}
+			got, err := s.GetPrice(ctx, "kucoin", "BTCUSDT")
+			if err != nil {
+				t.Fatalf("GetPrice: %v", err)
+			}
+			if got.Stats != nil {
+				t.Errorf("Stats = %+v, want nil", got.Stats)
+			}
+		})
+	}
+}
+
+func TestRedisStoreTiers(t *testing.T) {
+	ctx := context.Background()
+	at := time.Unix(time.Now().Unix(), 0)
+
+	for _, layout := range []string{PriceLayoutHash, PriceLayoutLegacy} {
+		t.Run(layout, func(t *testing.T) {
+			s, mr := newTestRedisStore(t, layout)
+			if err := s.PutPrices(ctx, "kucoin", map[string]float64{"BTCUSDT": 60000}, nil, at.Add(-2*time.Minute)); err != nil {
+				t.Fatalf("PutPrices: %v", err)
+			}
+			mr.FastForward(2 * time.Minute)
+
+			got, err := s.GetPrice(ctx, "kucoin", "BTCUSDT")
+			if err != nil {
+				t.Fatalf("GetPrice: %v", err)
+			}
+			if got.Tier != TierLong {
+				t.Errorf("Tier = %q, want %q", got.Tier, TierLong)
+			}
+
+			mr.FastForward(time.Hour)
+			if _, err := s.GetPrice(ctx, "kucoin", "BTCUSDT"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetPrice after expiry error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+// TestRedisStoreLegacyFallback checks GetPrice and GetPrices both fall back
+// to the legacy keys, whatever layout the reader writes.
+func TestRedisStoreLegacyFallback(t *testing.T) {
+	ctx := context.Background()
+	at := time.Unix(time.Now().Unix(), 0)
+	stats := models.MarketStats{High: 61000}
+
+	for _, layout := range []string{PriceLayoutHash, PriceLayoutLegacy, PriceLayoutBoth} {
+		t.Run(layout, func(t *testing.T) {
+			s, mr := newTestRedisStore(t, layout)
+			writer := NewRedisStore(s.client, PriceLayoutLegacy, testExpirations)
+			if err := writer.PutPrices(ctx, "kucoin", map[string]float64{"BTCUSDT": 60000}, map[string]models.MarketStats{"BTCUSDT": stats}, at); err != nil {
+				t.Fatalf("PutPrices(legacy): %v", err)
+			}
+			if err := s.PutPrices(ctx, "kucoin", map[string]float64{"ETHUSDT": 3000}, nil, at); err != nil {
+				t.Fatalf("PutPrices: %v", err)
+			}
+			if layout != PriceLayoutLegacy && !mr.Exists(PriceHashKey("kucoin", "ETHUSDT")) {
+				t.Fatalf("%s layout did not write a hash", layout)
+			}
+
+			want := PricePoint{Price: 60000, Timestamp: at, Tier: TierShort, Stats: &stats}
+			got, err := s.GetPrice(ctx, "kucoin", "BTCUSDT")
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("GetPrice = %+v, %v; want %+v", got, err, want)
+			}
+			points, err := s.GetPrices(ctx, "kucoin", []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"})
+			if err != nil {
+				t.Fatalf("GetPrices: %v", err)
+			}
+			if len(points) != 2 || !reflect.DeepEqual(points["BTCUSDT"], want) || points["ETHUSDT"].Price != 3000 {
+				t.Errorf("GetPrices = %+v, want BTCUSDT %+v and ETHUSDT 3000", points, want)
+			}
+			if _, err := s.GetPrice(ctx, "kucoin", "SOLUSDT"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetPrice(SOLUSDT) error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestRedisStoreUsdtIrrRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestRedisStore(t, PriceLayoutHash)
+
+	computed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := models.MarketSourceResult{
+		MarketName:   "USDTIRT",
+		Source:       "nobitex",
+		Median:       612000,
+		WeightedMean: 611500.5,
+		Trades:       100,
+		ComputedAt:   computed,
+		WindowStart:  computed.Add(-30 * time.Minute),
+		WindowEnd:    computed,
+	}
+	if err := s.PutUsdtIrr(ctx, want); err != nil {
+		t.Fatalf("PutUsdtIrr: %v", err)
+	}
+	got, err := s.GetUsdtIrr(ctx, "nobitex")
+	if err != nil {
+		t.Fatalf("GetUsdtIrr: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUsdtIrr = %+v, want %+v", got, want)
+	}
+
+	// Payloads written before the versioned envelope are still readable, and
+	// their computation time is estimated from the TTL left on the key.
+	mr.Set(UsdtIrrKey("wallex"), `{"MarketName":"USDTIRT","Source":"wallex","WeightedMean":610000,"SumAmounts":7000}`)
+	mr.SetTTL(UsdtIrrKey("wallex"), 45*time.Minute)
+	legacy, err := s.GetUsdtIrr(ctx, "wallex")
+	if err != nil {
+		t.Fatalf("GetUsdtIrr(legacy): %v", err)
+	}
+	if legacy.WeightedMean != 610000 || legacy.SumAmounts != 7000 {
+		t.Errorf("GetUsdtIrr(legacy) = %+v", legacy)
+	}
+	if age := time.Since(legacy.ComputedAt); age < 15*time.Minute || age > 16*time.Minute {
+		t.Errorf("GetUsdtIrr(legacy) age = %v, want 15m", age)
+	}
+
+	// Without a TTL the rate is assumed as old as a readable rate can be.
+	mr.Set(UsdtIrrKey("ramzinex"), `{"MarketName":"USDTIRT","Source":"ramzinex","WeightedMean":610000}`)
+	persistent, err := s.GetUsdtIrr(ctx, "ramzinex")
+	if err != nil {
+		t.Fatalf("GetUsdtIrr(no TTL): %v", err)
+	}
+	if age := time.Since(persistent.ComputedAt); age < time.Hour || age > time.Hour+time.Minute {
+		t.Errorf("GetUsdtIrr(no TTL) age = %v, want 1h", age)
+	}
+
+	if _, err := s.GetUsdtIrr(ctx, "bitpin"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUsdtIrr(bitpin) error = %v, want ErrNotFound", err)
+	}
+}
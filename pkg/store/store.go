@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/models"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Price tiers. A price is short-term for Expirations.ShortTerm after it was
+// written and remains readable as long-term until Expirations.LongTerm.
+const (
+	TierShort = "short"
+	TierLong  = "long"
+)
+
+// Expirations bounds how long stored values stay in each tier.
+type Expirations struct {
+	ShortTerm time.Duration
+	LongTerm  time.Duration
+	// UsdtIrr is how long a USDT/IRR rate stays readable.
+	UsdtIrr time.Duration
+}
+
+// expirations holds the Expirations of a store. They can be replaced while
+// the store serves.
+type expirations struct {
+	current atomic.Pointer[Expirations]
+}
+
+func (e *expirations) exp() Expirations {
+	return *e.current.Load()
+}
+
+// SetExpirations replaces the expirations of the store.
+func (e *expirations) SetExpirations(exp Expirations) {
+	e.current.Store(&exp)
+}
+
+// ExpirationsFromConfig returns the expirations set in the store section.
+func ExpirationsFromConfig(cfg config.StoreConfig) Expirations {
+	return Expirations{
+		ShortTerm: cfg.ShortTermTTL,
+		LongTerm:  cfg.LongTermTTL,
+		UsdtIrr:   cfg.UsdtIrrTTL,
+	}
+}
+
+// Backends selectable with store.backend.
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+)
+
+// ErrNotFound is returned when a price or rate is not stored or has expired.
+var ErrNotFound = errors.New("price not available")
+
+// PricePoint is the latest stored price of a symbol on a source.
+type PricePoint struct {
+	Price     float64
+	Timestamp time.Time
+	Tier      string
+	// Stats are the 24h statistics written with the price, if any.
+	Stats *models.MarketStats
+}
+
+// PriceStore holds the latest prices and USDT/IRR rates written by the
+// ingestion jobs and read by the API.
+type PriceStore interface {
+	// PutPrices stores the prices of source, keyed by symbol, observed at at,
+	// along with the 24h statistics of the symbols in stats. Statistics
+	// previously stored for a symbol missing from stats are removed.
+	PutPrices(ctx context.Context, source string, prices map[string]float64, stats map[string]models.MarketStats, at time.Time) error
+	// GetPrice returns the latest price of symbol on source or ErrNotFound.
+	GetPrice(ctx context.Context, source, symbol string) (PricePoint, error)
+	// GetPrices returns the latest prices of symbols on source. Symbols
+	// without a price are missing from the result.
+	GetPrices(ctx context.Context, source string, symbols []string) (map[string]PricePoint, error)
+
+	// PutUsdtIrr stores the USDT/IRR rate computed for result.Source.
+	PutUsdtIrr(ctx context.Context, result models.MarketSourceResult) error
+	// GetUsdtIrr returns the USDT/IRR rate of source or ErrNotFound. Rates
+	// stored without a ComputedAt, by versions that did not record it, carry
+	// the time they were stored instead, estimated from the key TTL.
+	GetUsdtIrr(ctx context.Context, source string) (models.MarketSourceResult, error)
+
+	// Subscribe delivers the key prefix of every update (see PriceKey,
+	// SourcePrefix and UsdtIrrKey) until ctx is done.
+	Subscribe(ctx context.Context) (<-chan string, error)
+
+	// SetExpirations replaces the expirations. Values already stored keep
+	// the expiration they were written with where the backend enforces it.
+	SetExpirations(exp Expirations)
+
+	// Ping checks the backend is reachable.
+	Ping(ctx context.Context) error
+	// Name identifies the backend in logs and health checks.
+	Name() string
+}
+
+// PriceKey identifies the price of symbol on source in update notifications.
+func PriceKey(source, symbol string) string {
+	return SourcePrefix(source) + symbol
+}
+
+// SourcePrefix matches every price of source in update notifications.
+func SourcePrefix(source string) string {
+	return "price:" + source + ":"
+}
+
+// UsdtIrrKey identifies the USDT/IRR rate of source in update notifications.
+func UsdtIrrKey(source string) string {
+	return "usdtirr:" + source
+}
+
+// New returns the store selected by cfg.Store.Backend. rdb is only used by
+// the Redis backend.
+func New(cfg *config.Config, rdb redis.UniversalClient) (PriceStore, error) {
+	exp := ExpirationsFromConfig(cfg.Store)
+	switch cfg.Store.Backend {
+	case BackendRedis, "":
+		return NewRedisStore(rdb, cfg.Redis.PriceLayout, exp), nil
+	case BackendMemory:
+		return NewMemoryStore(exp), nil
+	default:
+		return nil, fmt.Errorf("unknown price store %q (expected %s or %s)", cfg.Store.Backend, BackendRedis, BackendMemory)
+	}
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook traces Redis commands in spans named after the command. Add it
+// to a client with AddHook.
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = startClient(ctx, "redis "+strings.ToLower(cmd.Name()),
+		semconv.DBSystemRedis, semconv.DBOperation(strings.ToLower(cmd.Name())))
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedis(ctx, cmd.Err())
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, _ = startClient(ctx, "redis pipeline",
+		semconv.DBSystemRedis, semconv.DBOperation("pipeline"), attribute.Int("db.redis.commands", len(cmds)))
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	endRedis(ctx, err)
+	return nil
+}
+
+func endRedis(ctx context.Context, err error) {
+	// A missing key is an answer, not a failure.
+	if err == redis.Nil {
+		err = nil
+	}
+	End(trace.SpanFromContext(ctx), err)
+}
+
+// MongoMonitor returns a command monitor tracing MongoDB commands in spans
+// named after the command.
+func MongoMonitor() *event.CommandMonitor {
+	// Spans are kept from the started event to the succeeded or failed one,
+	// which carry the same request ID.
+	var spans sync.Map
+	end := func(requestID int64, err error) {
+		if span, ok := spans.LoadAndDelete(requestID); ok {
+			End(span.(trace.Span), err)
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			_, span := startClient(ctx, "mongo "+e.CommandName,
+				semconv.DBSystemMongoDB, semconv.DBName(e.DatabaseName), semconv.DBOperation(e.CommandName))
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			end(e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			end(e.RequestID, commandError(e.Failure))
+		},
+	}
+}
+
+type commandError string
+
+func (e commandError) Error() string {
+	return string(e)
+}
+
+func startClient(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// HTTPHandler traces the requests served by next in a span named after the
+// method and route, continuing the trace of the caller if it sent one.
+func HTTPHandler(route string, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, route,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + route
+		}),
+		otelhttp.WithSpanOptions(trace.WithAttributes(semconv.HTTPRoute(route))),
+	)
+}
+
+// Transport traces the requests sent through base and propagates the trace
+// to the server.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// ServerOption traces the calls served by a gRPC server, continuing the
+// trace of the caller if it sent one.
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler())
+}
@@ -0,0 +1,109 @@
+// Package tracing sets up OpenTelemetry tracing and provides the adapters
+// that trace HTTP and gRPC handlers, Redis and MongoDB commands and outbound
+// exchange requests. Trace context is propagated in the W3C traceparent and
+// baggage headers. Without an OTLP endpoint spans are not recorded.
+package tracing
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"crypto_price/pkg/logging"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Protocols selectable with tracing.protocol.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+const instrumentationName = "crypto_price"
+
+// Setup exports spans to the OTLP endpoint of cfg and installs the W3C
+// propagators. The returned function flushes pending spans and stops the
+// exporter. Without an endpoint only the propagators are installed, so
+// incoming trace context is still passed on to outbound requests.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Endpoint == "" {
+		slog.Info("Tracing endpoint not provided, spans are not exported")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logging.Sampled(context.Background(), slog.LevelWarn, "tracing", "Tracing error", "error", err)
+	}))
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	provider := NewProvider(cfg, sdktrace.NewBatchSpanProcessor(exporter))
+	otel.SetTracerProvider(provider)
+	slog.Info("Tracing initialized", "endpoint", cfg.Endpoint, "protocol", cfg.Protocol)
+	return provider.Shutdown, nil
+}
+
+// NewProvider returns a tracer provider sampling root spans at the rate of
+// cfg and passing spans to processor. Child spans follow the decision of
+// their parent, including remote ones.
+func NewProvider(cfg config.TracingConfig, processor sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	res := resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRate))),
+	)
+}
+
+func newExporter(ctx context.Context, cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Protocol {
+	case ProtocolGRPC:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case ProtocolHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing protocol %q", cfg.Protocol)
+	}
+}
+
+// Start starts a span named name as a child of the span of ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, recording err as its error if set.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	"context"
+	"crypto_price/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestExporter installs a provider recording every span synchronously
+// into the returned exporter, along with the W3C propagators.
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	if _, err := Setup(context.Background(), config.TracingConfig{}); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(config.TracingConfig{ServiceName: "test", SampleRate: 1}, sdktrace.NewSimpleSpanProcessor(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+// findSpan returns the ended span named name.
+func findSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+		names = append(names, span.Name)
+	}
+	t.Fatalf("no span %q among %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+// assertChild checks span is a client span of the trace of parent.
+func assertChild(t *testing.T, span tracetest.SpanStub, parent trace.SpanContext) {
+	t.Helper()
+	if span.SpanKind != trace.SpanKindClient {
+		t.Errorf("%s kind = %v, want client", span.Name, span.SpanKind)
+	}
+	if span.SpanContext.TraceID() != parent.TraceID() {
+		t.Errorf("%s trace = %s, want %s", span.Name, span.SpanContext.TraceID(), parent.TraceID())
+	}
+	if span.Parent.SpanID() != parent.SpanID() {
+		t.Errorf("%s parent = %s, want %s", span.Name, span.Parent.SpanID(), parent.SpanID())
+	}
+}
+
+func TestHTTPHandlerContinuesTrace(t *testing.T) {
+	exporter := newTestExporter(t)
+
+	var handled trace.SpanContext
+	handler := HTTPHandler("/v1/price", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled = trace.SpanContextFromContext(r.Context())
+	}))
+	r := httptest.NewRequest("GET", "/v1/price?base=BTC", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	span := findSpan(t, exporter, "GET /v1/price")
+	if span.SpanKind != trace.SpanKindServer {
+		t.Errorf("kind = %v, want server", span.SpanKind)
+	}
+	if got := span.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace = %s, want the caller's", got)
+	}
+	if got := span.Parent.SpanID().String(); got != "00f067aa0ba902b7" || !span.Parent.IsRemote() {
+		t.Errorf("parent = %s (remote %t), want the caller's span", got, span.Parent.IsRemote())
+	}
+	if handled.SpanID() != span.SpanContext.SpanID() {
+		t.Errorf("handler context span = %s, want %s", handled.SpanID(), span.SpanContext.SpanID())
+	}
+}
+
+func TestRedisHookStartsClientSpans(t *testing.T) {
+	exporter := newTestExporter(t)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	client.AddHook(RedisHook{})
+	t.Cleanup(func() { client.Close() })
+
+	ctx, parent := Start(context.Background(), "parent")
+	if err := client.Set(ctx, "key", "value", 0).Err(); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	// A missing key is not an error.
+	client.Get(ctx, "missing")
+	if _, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Get(ctx, "key")
+		pipe.Get(ctx, "missing")
+		return nil
+	}); err != nil && err != redis.Nil {
+		t.Fatalf("Pipelined: %v", err)
+	}
+	parent.End()
+
+	for _, name := range []string{"redis set", "redis get", "redis pipeline"} {
+		span := findSpan(t, exporter, name)
+		assertChild(t, span, parent.SpanContext())
+		if span.Status.Code == codes.Error {
+			t.Errorf("%s status = %v, want no error", name, span.Status)
+		}
+	}
+}
+
+func TestMongoMonitorStartsClientSpans(t *testing.T) {
+	exporter := newTestExporter(t)
+	monitor := MongoMonitor()
+
+	ctx, parent := Start(context.Background(), "parent")
+	monitor.Started(ctx, &event.CommandStartedEvent{CommandName: "find", DatabaseName: "market", RequestID: 1})
+	monitor.Started(ctx, &event.CommandStartedEvent{CommandName: "insert", DatabaseName: "market", RequestID: 2})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1}})
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert", RequestID: 2}, Failure: "duplicate key"})
+	parent.End()
+
+	find := findSpan(t, exporter, "mongo find")
+	assertChild(t, find, parent.SpanContext())
+	if find.Status.Code == codes.Error {
+		t.Errorf("mongo find status = %v, want no error", find.Status)
+	}
+	insert := findSpan(t, exporter, "mongo insert")
+	assertChild(t, insert, parent.SpanContext())
+	if insert.Status.Code != codes.Error || insert.Status.Description != "duplicate key" {
+		t.Errorf("mongo insert status = %v, want the failure", insert.Status)
+	}
+}
+
+func TestTransportInjectsTraceparent(t *testing.T) {
+	exporter := newTestExporter(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	ctx, parent := Start(context.Background(), "parent")
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/v3/ticker/24hr", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: Transport(http.DefaultTransport)}).Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	var client tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		if span.SpanKind == trace.SpanKindClient {
+			client = span
+		}
+	}
+	assertChild(t, client, parent.SpanContext())
+	want := "00-" + client.SpanContext.TraceID().String() + "-" + client.SpanContext.SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+}